@@ -9,93 +9,183 @@ type DataframeAnalysis struct {
 	dataset                []MemsData
 	expectedTimeEngineWarm time.Time
 	engineStartedAt        time.Time
+	faultHistory           map[string]*FaultHistoryEntry
+	recentFrames           []MemsData
+	ambientPressure        float32
+	clock                  Clock
+	sessionStartedAt       time.Time
+	lastFrameAt            time.Time
+	profile                ThresholdProfile
+	warmUp                 *warmUpCurve
+	lambda                 *lambdaAnalyser
+	keyOn                  keyOnReadings
+	sensors                []*sensorMonitor
+	electrical             *electricalAnalyser
+	engineState            *engineStateMachine
+	findingOutcomes        map[string][]bool
+	Rules                  *RuleRegistry // diagnostic rules evaluated whilst the engine is running
+	KeyOnRules             *RuleRegistry // plausibility checks evaluated with the ignition on and the engine off
 	Analysis               AnalysisReport
 }
 
 type AnalysisReport struct {
+	EngineState              EngineState // operational state of the engine
+	EngineStateSince         time.Time   // time the engine entered the state
 	IsEngineRunning          bool
 	IsEngineWarming          bool
 	IsAtOperatingTemp        bool
 	IsEngineIdle             bool
 	IsEngineIdleFault        bool
 	IdleSpeedFault           bool
+	IdleErrorFault           bool
+	IdleHuntingFault         bool
+	MisfireFault             bool
 	IdleHotFault             bool
+	IsCruising               bool
 	IsClosedLoop             bool
+	IsClosedLoopExpected     bool
+	ClosedLoopFault          bool
 	IsThrottleActive         bool
 	BatteryFault             bool
 	MapFault                 bool
 	VacuumFault              bool
 	IdleAirControlFault      bool
+	IdleAirControlRangeFault bool
 	IdleAirControlJackFault  bool
 	O2SystemFault            bool
 	LambdaRangeFault         bool
 	LambdaOscillationFault   bool
+	LambdaLazyFault          bool
+	LambdaMixtureFault       bool
 	ThermostatFault          bool
+	ThermostatState          ThermostatState // estimated from the coolant temperature curve
+	ThermostatConfidence     float64         // confidence in the thermostat state, 0 to 1
 	CoilFault                bool
 	CrankshaftSensorFault    bool
 	CoolantTempSensorFault   bool
 	IntakeAirTempSensorFault bool
 	FuelPumpCircuitFault     bool
 	ThrottlePotCircuitFault  bool
-	IACPosition              int // intend to remove this from here
+	StuckSensorFault         bool
+	NoisySensorFault         bool
+	CrankingVoltageFault     bool
+	OverchargingFault        bool
+	ChargingLoadFault        bool
+	IACPosition              int                // intend to remove this from here
+	Lambda                   LambdaAnalysis     // lambda sensor performance in closed loop
+	RPMSpectrum              SignalSpectrum     // frequency analysis of the engine speed over the dataset
+	LambdaSpectrum           SignalSpectrum     // frequency analysis of the lambda voltage over the dataset
+	Sensors                  []SensorHealth     // health of the monitored sensors
+	StuckSensors             []string           // channels of the flat lined sensors
+	NoisySensors             []string           // channels of the sensors with implausible changes
+	Electrical               ElectricalAnalysis // cranking and charging voltages
+	Profile                  string
+	Outcomes                 []RuleOutcome // outcomes of the diagnostic rules
+	Findings                 []Finding     // faults found with their severity, confidence, evidence and remedy
+
+	// plausibility checks made with the ignition on and the engine off
+	MAPPlausibilityFault         bool
+	TemperaturePlausibilityFault bool
+	BatteryPlausibilityFault     bool
+	ThrottlePotPlausibilityFault bool
 }
 
 const (
-	timeFormat                         = "15:04:05.000"
-	secondsPerDegree                   = 11
-	minimumDatasetSize                 = 1
-	defaultIdleThrottleAngle           = 14
-	lowestBatteryVoltage               = 13
-	highestIdleMAPValue                = 45
-	highestIdleCoilTime                = 4
-	highestIdleRPM                     = 1300
-	lowestEngineWarmTemperature        = 78
-	engineOperatingTemp                = 80
-	engineNotRunningRPM                = 0
-	expectedDTC5Value                  = 255
-	maximumEngineRPM                   = 6000
-	maximumIdleBasePosition            = 250
-	maximumAirIntakeTemperature        = 80
-	maximumCoolantTemperature          = 120
-	maximumIdleOffset                  = 50
-	minimumIdleHot                     = 10
-	maximumIdleHot                     = 55
-	lowestIdleBasePosition             = 45
-	highestIdleBasePosition            = 55
-	invalidIACPosition                 = 0
-	invalidCASPosition                 = 0
-	lowestLambdaValue                  = 10
-	highestLambdaValue                 = 900
-	highestJackCount                   = 50
-	lambdaOscillationStandardDeviation = 100
-	highestIdleSpeedDeviation          = 150
+	timeFormat                  = "15:04:05.000"
+	minimumDatasetSize          = 1
+	engineNotRunningRPM         = 0
+	expectedDTC5Value           = 255
+	maximumEngineRPM            = 6000
+	maximumIdleBasePosition     = 250
+	maximumAirIntakeTemperature = 80
+	maximumCoolantTemperature   = 120
+	invalidIACPosition          = 0
+	invalidCASPosition          = 0
 )
 
+// NewDataframeAnalysis creates the analysis using the default threshold profile
 func NewDataframeAnalysis(datasetLength int) *DataframeAnalysis {
+	return NewDataframeAnalysisWithProfile(datasetLength, DefaultThresholdProfile)
+}
+
+// NewDataframeAnalysisWithProfile creates the analysis using the threshold profile
+func NewDataframeAnalysisWithProfile(datasetLength int, profile ThresholdProfile) *DataframeAnalysis {
 	df := &DataframeAnalysis{}
 	df.setDatasetLength(datasetLength)
+	df.faultHistory = make(map[string]*FaultHistoryEntry)
+	df.findingOutcomes = make(map[string][]bool)
+	df.clock = time.Now
+	df.Rules = diagnosticRules.clone()
+	df.KeyOnRules = keyOnRules.clone()
+	df.warmUp = newWarmUpCurve()
+	df.lambda = newLambdaAnalyser(lambdaAnalysisWindow)
+	df.sensors = newSensorMonitors()
+	df.electrical = newElectricalAnalyser()
+	df.engineState = newEngineStateMachine()
+	df.profile = profile
+
+	// record the profile used in the analysis report
+	df.Analysis.Profile = df.profile.Name
 
 	return df
 }
 
 func (df *DataframeAnalysis) Analyse(data MemsData) {
+	// timestamp dataframes that have not been read through the ecu reader
+	if data.Timestamp.IsZero() {
+		data = df.StampFrame(data)
+	}
+
 	if df.isValid(data) {
 		// analyse the current operational state
 		df.analyseOperationalStatus(data)
+		// move the engine state machine on and notify the state changes
+		df.analyseEngineState(data)
+		// measure the battery voltage whilst cranking and charging
+		df.analyseElectrical(data)
 
 		if df.Analysis.IsEngineRunning {
 			// add data to the dataset only if the engine is running
 			df.addToDataset(data)
-			// detect faults from the operational data
-			df.analyseOperationalFaults(data)
-			// set the expected time for the engine to reach operating temperature
-			if df.expectedTimeEngineWarm.IsZero() {
+			// set the expected time for a cold engine to reach operating temperature
+			// before the thermostat is checked, an engine started warm has no warm-up time
+			if df.expectedTimeEngineWarm.IsZero() && data.CoolantTemp < df.profile.EngineOperatingTemp {
 				df.expectedTimeEngineWarm = df.getExpectedEngineWarmTime(data)
 			}
+			// model the coolant temperature curve before the thermostat is checked
+			df.updateWarmUpModel(data)
+			df.Analysis.ThermostatState = df.warmUp.model.State
+			df.Analysis.ThermostatConfidence = df.warmUp.model.Confidence
+			// measure the lambda sensor switching in closed loop
+			df.lambda.add(data, df.profile)
+			df.Analysis.Lambda = df.lambda.analyse(df.profile)
+			// look for periodic oscillations in the engine speed and lambda
+			df.analyseSpectrum()
+			// look for flat lined and noisy sensors
+			df.monitorSensors(data)
+			// detect faults from the operational data
+			df.analyseOperationalFaults(data)
+			// the sensors are checked again when the engine stops, the key-on faults are only
+			// reported whilst the engine is off so the report agrees with the findings and fault history
+			df.keyOn = keyOnReadings{}
+			df.clearRules(df.KeyOnRules)
+		} else {
+			// check the sensors read the ambient conditions with the ignition on and the engine off
+			df.analysePlausibility(data)
+			// the sensors are monitored again when the engine starts
+			df.sensors = newSensorMonitors()
+			// the warm-up curve is modelled again when the engine starts
+			df.warmUp.engineStopped = true
 		}
 
 		// decode the ecu faults
 		df.analyseECUFaults(data)
+
+		// report the faults with their severity, confidence and remedy
+		df.analyseFindings(data)
+
+		// record the faults in the session fault history
+		df.updateFaultHistory(data)
 	}
 }
 
@@ -128,9 +218,9 @@ func (df *DataframeAnalysis) setDatasetLength(datasetLength int) {
 func (df *DataframeAnalysis) getExpectedEngineWarmTime(data MemsData) time.Time {
 	// the engine warms at around 11 seconds per degree
 	// given the current time and coolant temp, the estimated warm time to 80C can be calculated
-	currentTime, _ := time.Parse(timeFormat, data.Time)
-	degreesToWarm := engineOperatingTemp - data.CoolantTemp
-	secondsToWarm := time.Duration(degreesToWarm * secondsPerDegree)
+	currentTime := df.getTimestamp(data)
+	degreesToWarm := df.profile.EngineOperatingTemp - data.CoolantTemp
+	secondsToWarm := time.Duration(degreesToWarm * df.profile.SecondsPerDegree)
 	warmAt := currentTime.Add(time.Second * secondsToWarm)
 
 	return warmAt
@@ -138,8 +228,8 @@ func (df *DataframeAnalysis) getExpectedEngineWarmTime(data MemsData) time.Time
 
 func (df *DataframeAnalysis) getExpectedLambdaOscillationTime(data MemsData) time.Time {
 	// the lambda is expected to start oscillating 90 seconds after the engine has started
-	currentTime, _ := time.Parse(timeFormat, data.Time)
-	oscillationsExpectedAt := currentTime.Add(time.Second * 90)
+	currentTime := df.getTimestamp(data)
+	oscillationsExpectedAt := currentTime.Add(time.Second * time.Duration(df.profile.LambdaOscillationDelay))
 
 	return oscillationsExpectedAt
 }
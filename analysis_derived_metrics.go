@@ -0,0 +1,109 @@
+package rosco
+
+import (
+	"math"
+)
+
+const (
+	// MixtureState values
+	MixtureUnknown        = "unknown"
+	MixtureRich           = "rich"
+	MixtureLean           = "lean"
+	MixtureStoichiometric = "stoichiometric"
+)
+
+const (
+	// standard atmospheric pressure used until the key-on MAP baseline is read
+	standardAtmosphericPressure = 101.3
+	// lambda sensor voltage (mV) above which the mixture is rich and below which it is lean
+	richLambdaVoltage = 500
+	leanLambdaVoltage = 400
+	// lambda calculated from the air:fuel ratio outside this band is rich or lean
+	richLambda = 0.97
+	leanLambda = 1.03
+	// engine parameters used to estimate the fuel flow, the displacement is set by the threshold profile
+	volumetricEfficiency = 0.8
+	airGasConstant       = 287.05
+	petrolDensity        = 745.0 // grams per litre
+)
+
+// DeriveMetrics calculates the derived metrics from the decoded MemsData
+// returns the MemsData with the manifold vacuum, engine load, lambda, mixture state and estimated fuel flow
+func (df *DataframeAnalysis) DeriveMetrics(data MemsData) MemsData {
+	// the key-on MAP before the engine first starts is the ambient pressure baseline,
+	// the MAP of a stalled engine takes time to rise back to ambient so it is not used
+	if data.EngineRPM == engineNotRunningRPM && data.ManifoldAbsolutePressure > 0 && df.engineStartedAt.IsZero() {
+		df.ambientPressure = data.ManifoldAbsolutePressure
+	}
+
+	ambient := df.getAmbientPressure()
+
+	data.Lambda = roundTo2DecimalPoints(data.AirFuelRatio / stoichiometricAFR)
+	data.MixtureState = df.getMixtureState(data)
+
+	if data.EngineRPM > engineNotRunningRPM {
+		data.Vacuum = roundTo2DecimalPoints(ambient - data.ManifoldAbsolutePressure)
+		data.EngineLoad = roundTo2DecimalPoints(data.ManifoldAbsolutePressure / ambient * 100)
+		data.FuelFlow = roundTo2DecimalPoints(df.getEstimatedFuelFlow(data))
+	} else {
+		data.Vacuum = 0
+		data.EngineLoad = 0
+		data.FuelFlow = 0
+	}
+
+	return data
+}
+
+func (df *DataframeAnalysis) getAmbientPressure() float32 {
+	if df.ambientPressure > 0 {
+		return df.ambientPressure
+	}
+
+	return standardAtmosphericPressure
+}
+
+// getMixtureState uses the lambda sensor when the o2 system is active, otherwise the air:fuel ratio
+func (df *DataframeAnalysis) getMixtureState(data MemsData) string {
+	if df.isO2SystemActive(data) {
+		switch {
+		case data.LambdaVoltage > richLambdaVoltage:
+			return MixtureRich
+		case data.LambdaVoltage < leanLambdaVoltage:
+			return MixtureLean
+		default:
+			return MixtureStoichiometric
+		}
+	}
+
+	if data.AirFuelRatio <= 0 {
+		return MixtureUnknown
+	}
+
+	switch {
+	case data.Lambda < richLambda:
+		return MixtureRich
+	case data.Lambda > leanLambda:
+		return MixtureLean
+	default:
+		return MixtureStoichiometric
+	}
+}
+
+// getEstimatedFuelFlow estimates the fuel flow in litres per hour using the speed density method
+// the air mass is calculated from the MAP, RPM and intake air temperature
+func (df *DataframeAnalysis) getEstimatedFuelFlow(data MemsData) float32 {
+	afr := float64(data.AirFuelRatio)
+	if afr <= 0 {
+		afr = stoichiometricAFR
+	}
+
+	// a 4 stroke engine fills the displacement every 2 revolutions
+	airVolume := df.profile.EngineDisplacement / 1000 * volumetricEfficiency * float64(data.EngineRPM) / 120
+	intakeTemperature := float64(data.IntakeAirTemp) + 273.15
+	airMass := float64(data.ManifoldAbsolutePressure) * 1000 * airVolume / (airGasConstant * intakeTemperature) * 1000
+
+	fuelMass := airMass / afr
+	litresPerHour := fuelMass * 3600 / petrolDensity
+
+	return float32(math.Max(0, litresPerHour))
+}
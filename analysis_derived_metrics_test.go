@@ -0,0 +1,120 @@
+package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"testing"
+)
+
+func getDerivedMetricsFrame(rpm int, mapkPa float32) MemsData {
+	return MemsData{
+		EngineRPM:                rpm,
+		ManifoldAbsolutePressure: mapkPa,
+		IntakeAirTemp:            goodIntakeTemperature,
+		AirFuelRatio:             14.7,
+		LambdaStatus:             activeLambdaStatus,
+		LambdaVoltage:            goodLambdaValue,
+		CoolantTemp:              warmEngineTemperature,
+		IdleBasePosition:         goodIdleBasePosition,
+		DTC5:                     expectedDTC5,
+	}
+}
+
+func Test_derivedMetrics_VacuumUsesEngineOffBaseline(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+
+	// the engine off at key-on reads the ambient pressure
+	data := d.DeriveMetrics(getDerivedMetricsFrame(engineStopped, 98))
+	then.AssertThat(t, data.Vacuum, is.EqualTo(float32(0)))
+	then.AssertThat(t, data.EngineLoad, is.EqualTo(float32(0)))
+	then.AssertThat(t, data.FuelFlow, is.EqualTo(float32(0)))
+
+	data = d.DeriveMetrics(getDerivedMetricsFrame(850, 35))
+	then.AssertThat(t, data.Vacuum, is.EqualTo(float32(63)))
+	then.AssertThat(t, data.EngineLoad, is.EqualTo(float32(35.71)))
+}
+
+func Test_derivedMetrics_BaselineNotTakenAfterStall(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+
+	// the key-on MAP before the engine starts is the baseline
+	d.Analyse(d.DeriveMetrics(getDerivedMetricsFrame(engineStopped, 98)))
+	d.Analyse(d.DeriveMetrics(getDerivedMetricsFrame(850, 35)))
+
+	// the engine stalls, the MAP has not yet risen back to ambient
+	d.Analyse(d.DeriveMetrics(getDerivedMetricsFrame(engineStopped, 60)))
+
+	data := d.DeriveMetrics(getDerivedMetricsFrame(850, 35))
+	then.AssertThat(t, data.Vacuum, is.EqualTo(float32(63)))
+}
+
+func Test_derivedMetrics_VacuumWithoutBaseline(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+
+	// without an engine off reading the standard atmosphere is used
+	data := d.DeriveMetrics(getDerivedMetricsFrame(850, 35))
+	then.AssertThat(t, data.Vacuum, is.EqualTo(float32(66.3)))
+}
+
+func Test_derivedMetrics_Lambda(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+
+	data := getDerivedMetricsFrame(850, 35)
+	data.AirFuelRatio = 13.2
+	data = d.DeriveMetrics(data)
+	then.AssertThat(t, data.Lambda, is.EqualTo(float32(0.9)))
+}
+
+func Test_derivedMetrics_MixtureState(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+
+	// o2 system active uses the lambda voltage
+	data := getDerivedMetricsFrame(850, 35)
+	then.AssertThat(t, d.DeriveMetrics(data).MixtureState, is.EqualTo(MixtureStoichiometric))
+
+	data.LambdaVoltage = 800
+	then.AssertThat(t, d.DeriveMetrics(data).MixtureState, is.EqualTo(MixtureRich))
+
+	data.LambdaVoltage = 100
+	then.AssertThat(t, d.DeriveMetrics(data).MixtureState, is.EqualTo(MixtureLean))
+
+	// o2 system inactive uses the air:fuel ratio
+	data.LambdaStatus = inactiveLambdaStatus
+	data.AirFuelRatio = 13
+	then.AssertThat(t, d.DeriveMetrics(data).MixtureState, is.EqualTo(MixtureRich))
+
+	data.AirFuelRatio = 0
+	then.AssertThat(t, d.DeriveMetrics(data).MixtureState, is.EqualTo(MixtureUnknown))
+}
+
+func Test_derivedMetrics_FuelFlow(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+
+	idle := d.DeriveMetrics(getDerivedMetricsFrame(850, 35))
+	then.AssertThat(t, idle.FuelFlow, is.GreaterThan(float32(1)))
+	then.AssertThat(t, idle.FuelFlow, is.LessThan(float32(2)))
+
+	// fuel flow increases with load and speed
+	cruise := d.DeriveMetrics(getDerivedMetricsFrame(3000, 70))
+	then.AssertThat(t, cruise.FuelFlow, is.GreaterThan(idle.FuelFlow))
+
+	// the smaller engine of the mini uses less fuel at the same speed and load
+	mini := NewDataframeAnalysisWithProfile(20, MiniSPiProfile).DeriveMetrics(getDerivedMetricsFrame(850, 35))
+	then.AssertThat(t, mini.FuelFlow, is.LessThan(idle.FuelFlow))
+}
+
+func Test_derivedMetrics_LoggedAndAnalysed(t *testing.T) {
+	r := NewECUReaderInstance()
+	r.ecuReader = NewECUReader("testdata/full-warmup-working-lambda.fcr")
+	_, _ = r.connectToECU()
+
+	data, err := r.GetDataframes()
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, data.MixtureState, is.Not(is.EqualTo("")))
+
+	header := getMemsDataHeader(MetricUnits)
+	then.AssertThat(t, header, is.ValueContaining("vacuum,engine_load,lambda,mixture,fuel_flow"))
+
+	csv := convertMemsDataToCSVData(data, MetricUnits)
+	then.AssertThat(t, csv, is.ValueContaining(data.MixtureState))
+}
@@ -0,0 +1,262 @@
+package rosco
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	// the starter turns the engine below this speed
+	crankingRPM = 400
+	// the battery voltage is read over this period before the engine starts, the dataframes are read
+	// too slowly to see more than one or two frames whilst the starter is turning the engine
+	crankingWindow = 5 * time.Second
+	// the charging voltage is measured once the alternator has recovered the charge used by the starter
+	chargingSettleTime = 30 * time.Second
+	// period the charging voltage is averaged over
+	chargingWindow = 60 * time.Second
+	// coil charge times above this are misread dataframes
+	highestValidCoilTime = 20
+	// minimum number of samples to correlate the coil charge time with the battery voltage
+	minimumCoilTimeSamples = 10
+)
+
+// ElectricalAnalysis measures the battery and charging system from the battery voltage read by the ecu
+type ElectricalAnalysis struct {
+	// IsCranking is true whilst the starter is turning the engine
+	IsCranking bool `json:"IsCranking"`
+	// Starts is the number of times the engine has been started
+	Starts int `json:"Starts"`
+	// CrankingVoltage is the lowest battery voltage whilst the engine was cranked for the last start
+	CrankingVoltage float64 `json:"CrankingVoltage"`
+	// ChargingVoltage is the mean battery voltage once the engine is running and the battery has recovered
+	ChargingVoltage float64 `json:"ChargingVoltage"`
+	// HighestChargingVoltage is the highest battery voltage whilst charging
+	HighestChargingVoltage float64 `json:"HighestChargingVoltage"`
+	// LoadVoltageDrop is the fall in the battery voltage below the charging voltage, loads such as the
+	// radiator fans and aircon pull the voltage down until the alternator responds
+	LoadVoltageDrop float64 `json:"LoadVoltageDrop"`
+	// CoilTimeCorrelation is the correlation of the coil charge time with the battery voltage, -1 to 1
+	// the ecu lengthens the charge time as the voltage falls so a working ecu has a negative correlation
+	CoilTimeCorrelation float64 `json:"CoilTimeCorrelation"`
+	// CoilTimePerVolt is the change in the coil charge time in ms for each volt change in the battery voltage
+	CoilTimePerVolt float64 `json:"CoilTimePerVolt"`
+	// IsCrankingVoltageLow is true if the battery voltage fell too low whilst cranking
+	IsCrankingVoltageLow bool `json:"IsCrankingVoltageLow"`
+	// IsOvercharging is true if the charging voltage is too high
+	IsOvercharging bool `json:"IsOvercharging"`
+	// IsVoltageDroppingUnderLoad is true if the charging system cannot hold the voltage under load
+	IsVoltageDroppingUnderLoad bool `json:"IsVoltageDroppingUnderLoad"`
+}
+
+type voltageSample struct {
+	timestamp time.Time
+	voltage   float64
+}
+
+// electricalAnalyser tracks the battery voltage through cranking and charging
+type electricalAnalyser struct {
+	analysis  ElectricalAnalysis
+	running   bool
+	startedAt time.Time
+	cranking  []voltageSample
+	charging  []voltageSample
+	// sums for the correlation of the coil charge time with the battery voltage
+	coilSamples                                  float64
+	sumVoltage, sumCoilTime                      float64
+	sumVoltageSquared, sumCoilTimeSquared, sumVC float64
+}
+
+func newElectricalAnalyser() *electricalAnalyser {
+	return &electricalAnalyser{}
+}
+
+// add records the battery voltage of the dataframe, the dataframes are added with the engine running or stopped
+func (analyser *electricalAnalyser) add(data MemsData) {
+	voltage := roundTo(float64(data.BatteryVoltage), 2)
+
+	if data.EngineRPM < crankingRPM {
+		if data.EngineRPM == engineNotRunningRPM && analyser.running {
+			// the engine has stopped
+			analyser.running = false
+			analyser.charging = nil
+		}
+
+		if !analyser.running {
+			// the starter may be turning the engine before the ecu reads the engine speed
+			analyser.analysis.IsCranking = data.EngineRPM > engineNotRunningRPM
+			analyser.cranking = appendVoltageSample(analyser.cranking, voltageSample{data.Timestamp, voltage}, crankingWindow)
+			return
+		}
+	}
+
+	if !analyser.running {
+		analyser.start(data.Timestamp)
+	}
+
+	analyser.addCoilTime(data)
+
+	if data.Timestamp.Sub(analyser.startedAt) >= chargingSettleTime {
+		analyser.charging = appendVoltageSample(analyser.charging, voltageSample{data.Timestamp, voltage}, chargingWindow)
+		analyser.updateCharging(voltage)
+	}
+}
+
+// start records the lowest voltage whilst cranking when the engine starts
+func (analyser *electricalAnalyser) start(timestamp time.Time) {
+	analyser.running = true
+	analyser.startedAt = timestamp
+	analyser.analysis.IsCranking = false
+	analyser.analysis.Starts++
+	analyser.analysis.CrankingVoltage = 0
+
+	for i, sample := range analyser.cranking {
+		if i == 0 || sample.voltage < analyser.analysis.CrankingVoltage {
+			analyser.analysis.CrankingVoltage = sample.voltage
+		}
+	}
+
+	analyser.cranking = nil
+}
+
+func (analyser *electricalAnalyser) updateCharging(voltage float64) {
+	var sum float64
+
+	for _, sample := range analyser.charging {
+		sum += sample.voltage
+	}
+
+	analysis := &analyser.analysis
+	analysis.ChargingVoltage = roundTo(sum/float64(len(analyser.charging)), 2)
+	analysis.HighestChargingVoltage = math.Max(analysis.HighestChargingVoltage, voltage)
+	analysis.LoadVoltageDrop = roundTo(math.Max(0, analysis.ChargingVoltage-voltage), 2)
+}
+
+// addCoilTime adds the coil charge time and battery voltage to the correlation
+func (analyser *electricalAnalyser) addCoilTime(data MemsData) {
+	if data.CoilTime <= 0 || data.CoilTime > highestValidCoilTime {
+		return
+	}
+
+	v := float64(data.BatteryVoltage)
+	c := float64(data.CoilTime)
+
+	analyser.coilSamples++
+	analyser.sumVoltage += v
+	analyser.sumCoilTime += c
+	analyser.sumVoltageSquared += v * v
+	analyser.sumCoilTimeSquared += c * c
+	analyser.sumVC += v * c
+
+	if analyser.coilSamples < minimumCoilTimeSamples {
+		return
+	}
+
+	n := analyser.coilSamples
+	covariance := n*analyser.sumVC - analyser.sumVoltage*analyser.sumCoilTime
+	voltageVariance := n*analyser.sumVoltageSquared - analyser.sumVoltage*analyser.sumVoltage
+	coilTimeVariance := n*analyser.sumCoilTimeSquared - analyser.sumCoilTime*analyser.sumCoilTime
+
+	// the correlation is undefined if the voltage or charge time has not changed
+	if voltageVariance > 1e-9 && coilTimeVariance > 1e-9 {
+		analyser.analysis.CoilTimeCorrelation = roundTo(covariance/math.Sqrt(voltageVariance*coilTimeVariance), 3)
+		analyser.analysis.CoilTimePerVolt = roundTo(covariance/voltageVariance, 3)
+	}
+}
+
+// analyse returns the electrical analysis with the faults assessed against the profile
+func (analyser *electricalAnalyser) analyse(profile ThresholdProfile) ElectricalAnalysis {
+	analysis := analyser.analysis
+
+	analysis.IsCrankingVoltageLow = analysis.Starts > 0 && analysis.CrankingVoltage > 0 &&
+		analysis.CrankingVoltage < float64(profile.LowestCrankingVoltage)
+	analysis.IsOvercharging = len(analyser.charging) > 0 &&
+		analysis.ChargingVoltage > float64(profile.HighestChargingVoltage)
+	analysis.IsVoltageDroppingUnderLoad = len(analyser.charging) > 0 &&
+		analysis.LoadVoltageDrop > float64(profile.HighestLoadVoltageDrop)
+
+	return analysis
+}
+
+func appendVoltageSample(samples []voltageSample, sample voltageSample, window time.Duration) []voltageSample {
+	samples = append(samples, sample)
+
+	for len(samples) > 1 && sample.timestamp.Sub(samples[0].timestamp) > window {
+		samples = samples[1:]
+	}
+
+	return samples
+}
+
+// analyseElectrical measures the cranking and charging voltages
+func (df *DataframeAnalysis) analyseElectrical(data MemsData) {
+	df.electrical.add(data)
+	df.Analysis.Electrical = df.electrical.analyse(df.profile)
+}
+
+// the battery voltage falls below 9.6V whilst cranking if the battery is discharged or failing,
+// or the starter motor or its connections are drawing too much current
+func (df *DataframeAnalysis) isCrankingVoltageLow(data MemsData) bool {
+	return df.Analysis.Electrical.IsCrankingVoltageLow
+}
+
+// a failed alternator voltage regulator overcharges the battery
+func (df *DataframeAnalysis) isOvercharging(data MemsData) bool {
+	return df.Analysis.Electrical.IsOvercharging
+}
+
+// a weak alternator, slipping drive belt or poor connection cannot hold the voltage when the fans or aircon switch on
+func (df *DataframeAnalysis) isVoltageDroppingUnderLoad(data MemsData) bool {
+	return df.Analysis.Electrical.IsVoltageDroppingUnderLoad
+}
+
+// toMarkdown returns the electrical analysis as a Markdown table
+func (analysis ElectricalAnalysis) toMarkdown() string {
+	var sb strings.Builder
+
+	if analysis.Starts == 0 {
+		return "The engine was not started in the session.\n"
+	}
+
+	sb.WriteString("| Electrical | |\n|--------|--------|\n")
+	sb.WriteString(fmt.Sprintf("| Starts | %d |\n", analysis.Starts))
+
+	if analysis.CrankingVoltage > 0 {
+		sb.WriteString(fmt.Sprintf("| Cranking Voltage | %.1f V |\n", analysis.CrankingVoltage))
+	}
+
+	if analysis.ChargingVoltage > 0 {
+		sb.WriteString(fmt.Sprintf("| Charging Voltage | %.2f V |\n", analysis.ChargingVoltage))
+		sb.WriteString(fmt.Sprintf("| Highest Charging Voltage | %.1f V |\n", analysis.HighestChargingVoltage))
+	}
+
+	sb.WriteString(fmt.Sprintf("| Coil Time Correlation | %.2f |\n", analysis.CoilTimeCorrelation))
+	sb.WriteString(fmt.Sprintf("| Coil Time per Volt | %.2f ms |\n", analysis.CoilTimePerVolt))
+	sb.WriteString(fmt.Sprintf("| Battery | %s |\n", analysis.getCondition()))
+
+	return sb.String()
+}
+
+func (analysis ElectricalAnalysis) getCondition() string {
+	var conditions []string
+
+	if analysis.IsCrankingVoltageLow {
+		conditions = append(conditions, "low cranking voltage")
+	}
+
+	if analysis.IsOvercharging {
+		conditions = append(conditions, "overcharging")
+	}
+
+	if analysis.IsVoltageDroppingUnderLoad {
+		conditions = append(conditions, "voltage drops under load")
+	}
+
+	if len(conditions) == 0 {
+		return "ok"
+	}
+
+	return strings.Join(conditions, ", ")
+}
@@ -0,0 +1,177 @@
+package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	chargingVoltage = 14.0
+	coilTime        = 3.0
+)
+
+// electricalSession builds the dataframes of a session, a dataframe every half second
+type electricalSession struct {
+	start  time.Time
+	frames []MemsData
+}
+
+func newElectricalSession() *electricalSession {
+	return &electricalSession{start: time.Date(2022, 3, 4, 11, 0, 0, 0, time.UTC)}
+}
+
+// add adds dataframes at the engine speed and battery voltage for the duration
+func (session *electricalSession) add(duration time.Duration, rpm int, voltage float32) *electricalSession {
+	for elapsed := time.Duration(0); elapsed < duration; elapsed += 500 * time.Millisecond {
+		session.frames = append(session.frames, MemsData{
+			Timestamp:                session.start.Add(time.Duration(len(session.frames)) * 500 * time.Millisecond),
+			EngineRPM:                rpm,
+			BatteryVoltage:           voltage,
+			CoilTime:                 coilTime,
+			CoolantTemp:              warmEngineTemperature,
+			IntakeAirTemp:            goodIntakeTemperature,
+			ManifoldAbsolutePressure: standardAtmosphericPressure,
+			DTC5:                     expectedDTC5Value,
+		})
+	}
+
+	return session
+}
+
+func (session *electricalSession) analyse() *DataframeAnalysis {
+	d := NewDataframeAnalysis(20)
+
+	for _, data := range session.frames {
+		d.Analyse(data)
+	}
+
+	return d
+}
+
+func Test_electrical_Healthy(t *testing.T) {
+	d := newElectricalSession().
+		add(3*time.Second, engineStopped, 12.6).
+		add(time.Second, engineStopped, 10.5).
+		add(2*time.Minute, rpmIdle, chargingVoltage).
+		analyse()
+
+	electrical := d.Analysis.Electrical
+	then.AssertThat(t, electrical.Starts, is.EqualTo(1))
+	then.AssertThat(t, electrical.CrankingVoltage, is.EqualTo(10.5))
+	then.AssertThat(t, electrical.ChargingVoltage, is.EqualTo(chargingVoltage))
+	then.AssertThat(t, electrical.LoadVoltageDrop, is.EqualTo(0.0))
+	then.AssertThat(t, d.Analysis.CrankingVoltageFault, is.False())
+	then.AssertThat(t, d.Analysis.OverchargingFault, is.False())
+	then.AssertThat(t, d.Analysis.ChargingLoadFault, is.False())
+}
+
+func Test_electrical_Cranking(t *testing.T) {
+	session := newElectricalSession().
+		add(3*time.Second, engineStopped, 12.6).
+		add(500*time.Millisecond, 200, 9.2)
+
+	d := session.analyse()
+	then.AssertThat(t, d.Analysis.Electrical.IsCranking, is.True())
+	then.AssertThat(t, d.Analysis.Electrical.Starts, is.EqualTo(0))
+
+	d = session.add(10*time.Second, rpmIdle, 12.8).analyse()
+	then.AssertThat(t, d.Analysis.Electrical.IsCranking, is.False())
+	then.AssertThat(t, d.Analysis.Electrical.Starts, is.EqualTo(1))
+	then.AssertThat(t, d.Analysis.Electrical.CrankingVoltage, is.EqualTo(9.2))
+	then.AssertThat(t, d.Analysis.Electrical.IsCrankingVoltageLow, is.True())
+	then.AssertThat(t, d.Analysis.CrankingVoltageFault, is.True())
+
+	// a low voltage long before the engine was started is not cranking
+	d = newElectricalSession().
+		add(time.Second, engineStopped, 9.2).
+		add(20*time.Second, engineStopped, 12.6).
+		add(10*time.Second, rpmIdle, 12.8).
+		analyse()
+	then.AssertThat(t, d.Analysis.Electrical.CrankingVoltage, is.EqualTo(12.6))
+	then.AssertThat(t, d.Analysis.CrankingVoltageFault, is.False())
+}
+
+func Test_electrical_Restarted(t *testing.T) {
+	d := newElectricalSession().
+		add(3*time.Second, engineStopped, 10.2).
+		add(time.Minute, rpmIdle, chargingVoltage).
+		add(3*time.Second, engineStopped, 11.5).
+		add(10*time.Second, rpmIdle, 12.8).
+		analyse()
+
+	then.AssertThat(t, d.Analysis.Electrical.Starts, is.EqualTo(2))
+	then.AssertThat(t, d.Analysis.Electrical.CrankingVoltage, is.EqualTo(11.5))
+	// the charging voltage is measured again once the battery has recovered
+	then.AssertThat(t, d.Analysis.Electrical.IsOvercharging, is.False())
+}
+
+func Test_electrical_ChargingSettleTime(t *testing.T) {
+	d := newElectricalSession().
+		add(chargingSettleTime, rpmIdle, 16).
+		analyse()
+
+	then.AssertThat(t, d.Analysis.Electrical.ChargingVoltage, is.EqualTo(0.0))
+	then.AssertThat(t, d.Analysis.OverchargingFault, is.False())
+}
+
+func Test_electrical_Overcharging(t *testing.T) {
+	d := newElectricalSession().
+		add(2*time.Minute, rpmIdle, 15.6).
+		analyse()
+
+	then.AssertThat(t, d.Analysis.Electrical.ChargingVoltage, is.EqualTo(15.6))
+	then.AssertThat(t, d.Analysis.Electrical.IsOvercharging, is.True())
+	then.AssertThat(t, d.Analysis.OverchargingFault, is.True())
+}
+
+func Test_electrical_VoltageDropUnderLoad(t *testing.T) {
+	// the radiator fans switch on
+	session := newElectricalSession().
+		add(2*time.Minute, rpmIdle, chargingVoltage).
+		add(500*time.Millisecond, rpmIdle, 12.9)
+
+	d := session.analyse()
+	then.AssertThat(t, d.Analysis.Electrical.LoadVoltageDrop, is.GreaterThan(float64(KSeriesProfile.HighestLoadVoltageDrop)))
+	then.AssertThat(t, d.Analysis.ChargingLoadFault, is.True())
+
+	// the alternator responds to the load
+	d = session.add(time.Second, rpmIdle, 13.8).analyse()
+	then.AssertThat(t, d.Analysis.Electrical.LoadVoltageDrop, is.LessThan(float64(KSeriesProfile.HighestLoadVoltageDrop)))
+	then.AssertThat(t, d.Analysis.ChargingLoadFault, is.False())
+}
+
+func Test_electrical_CoilTimeCorrelation(t *testing.T) {
+	analyser := newElectricalAnalyser()
+	start := time.Date(2022, 3, 4, 11, 0, 0, 0, time.UTC)
+
+	// the ecu lengthens the coil charge time by 0.5ms for each volt the battery voltage falls
+	for i := 0; i < 20; i++ {
+		voltage := 12 + float64(i%5)*0.5
+
+		analyser.add(MemsData{
+			Timestamp:      start.Add(time.Duration(i) * time.Second),
+			EngineRPM:      rpmIdle,
+			BatteryVoltage: float32(voltage),
+			CoilTime:       float32(coilTime + (14-voltage)*0.5),
+		})
+	}
+
+	analysis := analyser.analyse(DefaultThresholdProfile)
+	then.AssertThat(t, math.Abs(analysis.CoilTimeCorrelation+1), is.LessThan(0.01))
+	then.AssertThat(t, math.Abs(analysis.CoilTimePerVolt+0.5), is.LessThan(0.01))
+}
+
+func Test_electrical_SessionReport(t *testing.T) {
+	report, err := NewSessionAnalyser().AnalyseScenario("testdata/nofaults.fcr")
+	then.AssertThat(t, err, is.Nil())
+
+	then.AssertThat(t, report.Electrical.Starts, is.EqualTo(1))
+	then.AssertThat(t, report.Electrical.CrankingVoltage, is.EqualTo(11.8))
+	then.AssertThat(t, report.Electrical.ChargingVoltage, is.GreaterThan(13.0))
+	then.AssertThat(t, report.Electrical.CoilTimeCorrelation, is.LessThan(0.0))
+	then.AssertThat(t, strings.Contains(report.ToMarkdown(), "| Battery | ok |"), is.True())
+}
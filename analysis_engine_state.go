@@ -0,0 +1,205 @@
+package rosco
+
+import (
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// EngineState is the operational state of the engine
+type EngineState string
+
+const (
+	// EngineStateOff the ignition is off
+	EngineStateOff EngineState = "off"
+	// EngineStateKeyOn the ignition is on and the engine has not been started
+	EngineStateKeyOn EngineState = "key-on"
+	// EngineStateCranking the starter is turning the engine
+	EngineStateCranking EngineState = "cranking"
+	// EngineStateWarmUpIdle the engine is idling below operating temperature
+	EngineStateWarmUpIdle EngineState = "warm-up-idle"
+	// EngineStateWarmIdle the engine is idling at operating temperature
+	EngineStateWarmIdle EngineState = "warm-idle"
+	// EngineStateCruise the warm engine is held at a steady speed off idle
+	EngineStateCruise EngineState = "cruise"
+	// EngineStateOffIdle the engine is above idle and is not cruising, accelerating or on the overrun
+	EngineStateOffIdle EngineState = "off-idle"
+	// EngineStateAcceleration the throttle is open and the engine speed is rising
+	EngineStateAcceleration EngineState = "acceleration"
+	// EngineStateOverrun the throttle is closed above idle speed, the ecu cuts the fuel whilst decelerating
+	EngineStateOverrun EngineState = "overrun"
+	// EngineStateStalled the engine has stopped with the ignition on
+	EngineStateStalled EngineState = "stalled"
+)
+
+const (
+	// with the throttle closed the engine is idling below this speed
+	overrunRPM = 1800
+	// the engine is accelerating when the engine speed rises faster than this
+	accelerationRPMRate = 250
+	// the engine is decelerating when the engine speed falls faster than this
+	decelerationRPMRate = 250
+	// the engine speed is not compared across a larger gap between dataframes
+	maximumStateInterval = 2 * time.Second
+	// number of transitions kept, the callbacks receive every transition
+	maximumEngineStateTransitions = 100
+)
+
+// EngineStateTransition records the change from one engine state to another
+type EngineStateTransition struct {
+	From      EngineState `json:"From"`
+	To        EngineState `json:"To"`
+	Timestamp time.Time   `json:"Timestamp"`
+	// Duration is the time spent in the previous state
+	Duration time.Duration `json:"Duration"`
+}
+
+// EngineStateCallback receives the engine state transitions
+type EngineStateCallback func(transition EngineStateTransition)
+
+// engineStateMachine derives the engine state from the dataframes
+type engineStateMachine struct {
+	state       EngineState
+	since       time.Time
+	previous    MemsData
+	transitions []EngineStateTransition
+	callbacks   []EngineStateCallback
+}
+
+func newEngineStateMachine() *engineStateMachine {
+	return &engineStateMachine{state: EngineStateOff}
+}
+
+// isRunning returns true if the state is one of the running states
+func (state EngineState) isRunning() bool {
+	switch state {
+	case EngineStateWarmUpIdle, EngineStateWarmIdle, EngineStateCruise, EngineStateOffIdle, EngineStateAcceleration, EngineStateOverrun:
+		return true
+	default:
+		return false
+	}
+}
+
+// OnEngineStateChange registers a callback that is called when the engine changes state
+func (df *DataframeAnalysis) OnEngineStateChange(callback EngineStateCallback) {
+	if callback != nil {
+		df.engineState.callbacks = append(df.engineState.callbacks, callback)
+	}
+}
+
+// GetEngineStateTransitions returns the most recent engine state transitions in the order they occurred
+func (df *DataframeAnalysis) GetEngineStateTransitions() []EngineStateTransition {
+	transitions := make([]EngineStateTransition, len(df.engineState.transitions))
+	copy(transitions, df.engineState.transitions)
+
+	return transitions
+}
+
+// analyseEngineState moves the engine state machine on from the dataframe
+func (df *DataframeAnalysis) analyseEngineState(data MemsData) {
+	machine := df.engineState
+	timestamp := df.getTimestamp(data)
+
+	machine.setState(df.getEngineState(data), timestamp)
+	machine.previous = data
+
+	df.Analysis.EngineState = machine.state
+	df.Analysis.EngineStateSince = machine.since
+}
+
+// getEngineState returns the state of the engine in the dataframe
+func (df *DataframeAnalysis) getEngineState(data MemsData) EngineState {
+	current := df.engineState.state
+
+	if data.EngineRPM == engineNotRunningRPM {
+		if !data.IgnitionSwitch {
+			return EngineStateOff
+		}
+
+		// the engine stays stalled until it is cranked or the ignition is turned off
+		if current.isRunning() || current == EngineStateStalled {
+			return EngineStateStalled
+		}
+
+		return EngineStateKeyOn
+	}
+
+	// the engine is not running until the starter has turned it up to speed
+	if data.EngineRPM < crankingRPM && !current.isRunning() {
+		return EngineStateCranking
+	}
+
+	rate := df.getEngineRPMRate(data)
+	throttleClosed := data.ThrottleAngle <= df.profile.DefaultIdleThrottleAngle
+
+	if throttleClosed && data.EngineRPM <= overrunRPM {
+		if df.isEngineWarm(data) {
+			return EngineStateWarmIdle
+		}
+
+		return EngineStateWarmUpIdle
+	}
+
+	// the engine speed is held above idle with the throttle barely open,
+	// the engine is on the overrun once the speed falls with the throttle closed
+	if throttleClosed && (rate < -decelerationRPMRate || (current == EngineStateOverrun && rate <= 0)) {
+		return EngineStateOverrun
+	}
+
+	if rate > accelerationRPMRate {
+		return EngineStateAcceleration
+	}
+
+	// the engine is only cruising when warm and the engine speed has been steady over the dataset
+	if df.isCruising(data) {
+		return EngineStateCruise
+	}
+
+	return EngineStateOffIdle
+}
+
+// getEngineRPMRate returns the change in engine speed per second since the previous dataframe,
+// the rate is zero as the engine starts
+func (df *DataframeAnalysis) getEngineRPMRate(data MemsData) float64 {
+	previous := df.engineState.previous
+	interval := data.Timestamp.Sub(previous.Timestamp)
+
+	if !df.engineState.state.isRunning() || interval <= 0 || interval > maximumStateInterval {
+		return 0
+	}
+
+	return float64(data.EngineRPM-previous.EngineRPM) / interval.Seconds()
+}
+
+// setState records the transition and calls the registered callbacks if the state has changed
+func (machine *engineStateMachine) setState(state EngineState, timestamp time.Time) {
+	if state == machine.state && !machine.since.IsZero() {
+		return
+	}
+
+	transition := EngineStateTransition{From: machine.state, To: state, Timestamp: timestamp}
+
+	if !machine.since.IsZero() {
+		transition.Duration = timestamp.Sub(machine.since)
+	}
+
+	machine.state = state
+	machine.since = timestamp
+
+	// the state the analysis starts in is not a transition
+	if transition.From == transition.To {
+		return
+	}
+
+	machine.transitions = append(machine.transitions, transition)
+
+	// drop the oldest transition
+	if len(machine.transitions) > maximumEngineStateTransitions {
+		machine.transitions = machine.transitions[1:]
+	}
+
+	log.Debugf("engine state %s -> %s after %s", transition.From, transition.To, transition.Duration)
+
+	for _, callback := range machine.callbacks {
+		callback(transition)
+	}
+}
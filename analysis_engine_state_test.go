@@ -0,0 +1,229 @@
+package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"testing"
+	"time"
+)
+
+const openThrottleAngle = 30
+
+// engineStateSession builds the dataframes of a drive, a dataframe every half second
+type engineStateSession struct {
+	start       time.Time
+	frames      []MemsData
+	coolant     int
+	ignition    bool
+	transitions []EngineStateTransition
+}
+
+func newEngineStateSession() *engineStateSession {
+	return &engineStateSession{
+		start:    time.Date(2022, 3, 4, 11, 0, 0, 0, time.UTC),
+		coolant:  warmEngineTemperature,
+		ignition: true,
+	}
+}
+
+// ramp adds dataframes with the engine speed changing steadily over the duration
+func (session *engineStateSession) ramp(duration time.Duration, fromRPM int, toRPM int, throttleAngle int) *engineStateSession {
+	for elapsed := time.Duration(0); elapsed < duration; elapsed += 500 * time.Millisecond {
+		session.frames = append(session.frames, MemsData{
+			Timestamp:                session.start.Add(time.Duration(len(session.frames)) * 500 * time.Millisecond),
+			EngineRPM:                fromRPM + int(float64(toRPM-fromRPM)*float64(elapsed)/float64(duration)),
+			ThrottleAngle:            throttleAngle,
+			IgnitionSwitch:           session.ignition,
+			BatteryVoltage:           goodBattery,
+			CoolantTemp:              session.coolant,
+			IntakeAirTemp:            goodIntakeTemperature,
+			ManifoldAbsolutePressure: goodIdleMap,
+			DTC5:                     expectedDTC5Value,
+		})
+	}
+
+	return session
+}
+
+// hold adds dataframes at a steady engine speed
+func (session *engineStateSession) hold(duration time.Duration, rpm int, throttleAngle int) *engineStateSession {
+	return session.ramp(duration, rpm, rpm, throttleAngle)
+}
+
+func (session *engineStateSession) analyse() *DataframeAnalysis {
+	d := NewDataframeAnalysis(20)
+	session.transitions = nil
+	d.OnEngineStateChange(func(transition EngineStateTransition) {
+		session.transitions = append(session.transitions, transition)
+	})
+
+	for _, data := range session.frames {
+		d.Analyse(data)
+	}
+
+	return d
+}
+
+func getEngineStates(transitions []EngineStateTransition) []EngineState {
+	var states []EngineState
+
+	for _, transition := range transitions {
+		states = append(states, transition.To)
+	}
+
+	return states
+}
+
+func Test_engineState_KeyOn(t *testing.T) {
+	d := newEngineStateSession().
+		hold(2*time.Second, engineStopped, idleThrottleAngle).
+		analyse()
+
+	then.AssertThat(t, d.Analysis.EngineState, is.EqualTo(EngineStateKeyOn))
+	then.AssertThat(t, d.Analysis.EngineStateSince, is.EqualTo(time.Date(2022, 3, 4, 11, 0, 0, 0, time.UTC)))
+}
+
+func Test_engineState_Drive(t *testing.T) {
+	session := newEngineStateSession()
+	session.coolant = coldEngineTemperature
+
+	d := session.
+		hold(2*time.Second, engineStopped, idleThrottleAngle).
+		hold(500*time.Millisecond, 200, idleThrottleAngle).
+		hold(10*time.Second, rpmIdle, idleThrottleAngle).
+		ramp(5*time.Second, rpmIdle, 3500, openThrottleAngle).
+		hold(10*time.Second, 3500, openThrottleAngle).
+		ramp(5*time.Second, 3500, 2000, idleThrottleAngle).
+		hold(10*time.Second, rpmIdle, idleThrottleAngle).
+		analyse()
+
+	then.AssertThat(t, getEngineStates(session.transitions), is.EqualTo([]EngineState{
+		EngineStateKeyOn,
+		EngineStateCranking,
+		EngineStateWarmUpIdle,
+		// the engine speed rises after the throttle is opened, the cold engine is not cruising
+		EngineStateOffIdle,
+		EngineStateAcceleration,
+		EngineStateOffIdle,
+		EngineStateOverrun,
+		EngineStateWarmUpIdle,
+	}))
+
+	// the transitions are timestamped with the time spent in the previous state
+	cranking := session.transitions[1]
+	then.AssertThat(t, cranking.From, is.EqualTo(EngineStateKeyOn))
+	then.AssertThat(t, cranking.Timestamp, is.EqualTo(session.start.Add(2*time.Second)))
+	then.AssertThat(t, cranking.Duration, is.EqualTo(2*time.Second))
+
+	then.AssertThat(t, d.GetEngineStateTransitions(), is.EqualTo(session.transitions))
+	then.AssertThat(t, d.Analysis.EngineState, is.EqualTo(EngineStateWarmUpIdle))
+}
+
+func Test_engineState_WarmDrive(t *testing.T) {
+	session := newEngineStateSession().
+		hold(10*time.Second, rpmIdle, idleThrottleAngle).
+		ramp(5*time.Second, rpmIdle, 3000, openThrottleAngle).
+		hold(20*time.Second, 3000, openThrottleAngle).
+		hold(10*time.Second, rpmIdle, idleThrottleAngle)
+
+	d := NewDataframeAnalysis(20)
+	var states []EngineState
+
+	// the engine is only in the cruise state when the analysis reports the engine is cruising
+	for _, data := range session.frames {
+		d.Analyse(data)
+		if d.Analysis.EngineState == EngineStateCruise {
+			then.AssertThat(t, d.Analysis.IsCruising, is.True().Reason(data.Timestamp.String()))
+		}
+
+		if len(states) == 0 || states[len(states)-1] != d.Analysis.EngineState {
+			states = append(states, d.Analysis.EngineState)
+		}
+	}
+
+	then.AssertThat(t, states, is.EqualTo([]EngineState{
+		EngineStateWarmIdle,
+		// the engine speed is steady at idle as the throttle is opened
+		EngineStateCruise,
+		EngineStateAcceleration,
+		// the engine speed is held until it has been steady over the dataset
+		EngineStateOffIdle,
+		EngineStateCruise,
+		EngineStateWarmIdle,
+	}))
+}
+
+func Test_engineState_WarmIdle(t *testing.T) {
+	d := newEngineStateSession().
+		hold(10*time.Second, rpmIdle, idleThrottleAngle).
+		analyse()
+
+	then.AssertThat(t, d.Analysis.EngineState, is.EqualTo(EngineStateWarmIdle))
+}
+
+func Test_engineState_HeldAboveIdle(t *testing.T) {
+	// the engine speed is held with the throttle barely open, the engine is not on the overrun
+	session := newEngineStateSession()
+	d := session.
+		hold(20*time.Second, 2200, idleThrottleAngle).
+		analyse()
+
+	then.AssertThat(t, getEngineStates(session.transitions), is.EqualTo([]EngineState{EngineStateOffIdle, EngineStateCruise}))
+	then.AssertThat(t, d.Analysis.EngineState, is.EqualTo(EngineStateCruise))
+	then.AssertThat(t, d.Analysis.IsCruising, is.True())
+}
+
+func Test_engineState_Stalled(t *testing.T) {
+	session := newEngineStateSession().
+		hold(10*time.Second, rpmIdle, idleThrottleAngle).
+		hold(5*time.Second, engineStopped, idleThrottleAngle)
+
+	d := session.analyse()
+	then.AssertThat(t, d.Analysis.EngineState, is.EqualTo(EngineStateStalled))
+	then.AssertThat(t, d.Analysis.EngineStateSince, is.EqualTo(session.start.Add(10*time.Second)))
+
+	// the engine is restarted
+	d = session.
+		hold(500*time.Millisecond, 250, idleThrottleAngle).
+		hold(5*time.Second, rpmIdle, idleThrottleAngle).
+		analyse()
+	then.AssertThat(t, getEngineStates(session.transitions), is.EqualTo([]EngineState{
+		EngineStateWarmIdle,
+		EngineStateStalled,
+		EngineStateCranking,
+		EngineStateWarmIdle,
+	}))
+}
+
+func Test_engineState_Off(t *testing.T) {
+	session := newEngineStateSession().
+		hold(10*time.Second, rpmIdle, idleThrottleAngle)
+
+	session.ignition = false
+	d := session.
+		hold(5*time.Second, engineStopped, idleThrottleAngle).
+		analyse()
+
+	then.AssertThat(t, d.Analysis.EngineState, is.EqualTo(EngineStateOff))
+	then.AssertThat(t, getEngineStates(session.transitions), is.EqualTo([]EngineState{EngineStateWarmIdle, EngineStateOff}))
+	then.AssertThat(t, session.transitions[1].Duration, is.EqualTo(10*time.Second))
+}
+
+func Test_engineState_TransitionsLimited(t *testing.T) {
+	session := newEngineStateSession()
+
+	// the engine stalls and is restarted more often than the transitions are kept
+	for i := 0; i < maximumEngineStateTransitions; i++ {
+		session.
+			hold(500*time.Millisecond, rpmIdle, idleThrottleAngle).
+			hold(500*time.Millisecond, engineStopped, idleThrottleAngle).
+			hold(500*time.Millisecond, 250, idleThrottleAngle)
+	}
+
+	d := session.analyse()
+	transitions := d.GetEngineStateTransitions()
+
+	then.AssertThat(t, len(session.transitions), is.GreaterThan(maximumEngineStateTransitions))
+	then.AssertThat(t, len(transitions), is.EqualTo(maximumEngineStateTransitions))
+	then.AssertThat(t, transitions[len(transitions)-1], is.EqualTo(session.transitions[len(session.transitions)-1]))
+}
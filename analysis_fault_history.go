@@ -0,0 +1,122 @@
+package rosco
+
+import (
+	"sort"
+	"time"
+)
+
+// FaultHistoryEntry records the occurrences of a fault over the session
+type FaultHistoryEntry struct {
+	Name        string
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	Occurrences int
+	ActiveTime  time.Duration
+	Active      bool
+	// FreezeFrame is the dataframe in which the fault first appeared
+	FreezeFrame MemsData
+	// PreFaultFrames are the dataframes received immediately before the fault first appeared
+	PreFaultFrames []MemsData
+}
+
+const (
+	// number of frames captured before a fault first appears
+	freezeFramePreFaultFrames = 5
+)
+
+// GetFaultHistory returns the history of every fault seen in the session, ordered by first occurrence
+func (df *DataframeAnalysis) GetFaultHistory() []FaultHistoryEntry {
+	var history []FaultHistoryEntry
+
+	for _, entry := range df.faultHistory {
+		history = append(history, *entry)
+	}
+
+	sort.SliceStable(history, func(i, j int) bool {
+		if history[i].FirstSeen.Equal(history[j].FirstSeen) {
+			return history[i].Name < history[j].Name
+		}
+
+		return history[i].FirstSeen.Before(history[j].FirstSeen)
+	})
+
+	return history
+}
+
+// GetFaultHistoryEntry returns the history for the named fault
+func (df *DataframeAnalysis) GetFaultHistoryEntry(name string) (FaultHistoryEntry, bool) {
+	if entry, ok := df.faultHistory[name]; ok {
+		return *entry, true
+	}
+
+	return FaultHistoryEntry{}, false
+}
+
+func (df *DataframeAnalysis) updateFaultHistory(data MemsData) {
+	currentTime := df.getTimestamp(data)
+
+	snapshot := data
+	snapshot.Analytics = df.Analysis
+
+	active := df.getActiveFaults(data)
+
+	for name := range active {
+		entry, seen := df.faultHistory[name]
+
+		if !seen {
+			// first occurrence, capture the freeze frame and the frames leading up to the fault
+			entry = &FaultHistoryEntry{
+				Name:           name,
+				FirstSeen:      currentTime,
+				FreezeFrame:    snapshot,
+				PreFaultFrames: append([]MemsData{}, df.recentFrames...),
+			}
+
+			df.faultHistory[name] = entry
+		}
+
+		if entry.Active {
+			entry.ActiveTime += currentTime.Sub(entry.LastSeen)
+		} else {
+			entry.Occurrences++
+			entry.Active = true
+		}
+
+		entry.LastSeen = currentTime
+	}
+
+	// clear the faults that are no longer active
+	for name, entry := range df.faultHistory {
+		if !active[name] {
+			entry.Active = false
+		}
+	}
+
+	df.addToRecentFrames(snapshot)
+}
+
+// getActiveFaults returns the names of the ecu faults and the triggered rules in the current frame
+// operational faults are evaluated whilst the engine is running and plausibility checks whilst the engine is off
+func (df *DataframeAnalysis) getActiveFaults(data MemsData) map[string]bool {
+	active := make(map[string]bool)
+
+	for _, fault := range DecodeFaultCodes(data) {
+		active[fault.Name] = true
+	}
+
+	for _, outcome := range df.Analysis.Outcomes {
+		if outcome.Triggered {
+			active[outcome.Name] = true
+		}
+	}
+
+	return active
+}
+
+func (df *DataframeAnalysis) addToRecentFrames(data MemsData) {
+	df.recentFrames = append(df.recentFrames, data)
+
+	if len(df.recentFrames) > freezeFramePreFaultFrames {
+		df.recentFrames = df.recentFrames[1:]
+	}
+}
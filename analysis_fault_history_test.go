@@ -0,0 +1,111 @@
+package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"testing"
+	"time"
+)
+
+func getFaultHistoryFrame(timestamp string, battery float32, dtc0 uint8) MemsData {
+	return MemsData{
+		Time:                     timestamp,
+		EngineRPM:                rpmIdle,
+		CoolantTemp:              warmEngineTemperature,
+		IntakeAirTemp:            goodIntakeTemperature,
+		IdleBasePosition:         goodIdleBasePosition,
+		DTC0:                     dtc0,
+		DTC3:                     unsupportedDTCValue,
+		DTC5:                     expectedDTC5,
+		BatteryVoltage:           battery,
+		ThrottleAngle:            idleThrottleAngle,
+		ManifoldAbsolutePressure: goodIdleMap,
+		CoilTime:                 goodCoilTime,
+		LambdaStatus:             activeLambdaStatus,
+		LambdaVoltage:            goodLambdaValue,
+		IdleHot:                  goodIdleHot,
+		CrankshaftPositionSensor: goodCASPosition,
+	}
+}
+
+func Test_faultHistory_NoFaults(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+
+	d.Analyse(getFaultHistoryFrame("12:00:00.000", goodBattery, 0))
+	then.AssertThat(t, len(d.GetFaultHistory()), is.EqualTo(0))
+}
+
+func Test_faultHistory_IntermittentFault(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+
+	d.Analyse(getFaultHistoryFrame("12:00:00.000", goodBattery, 0))
+	d.Analyse(getFaultHistoryFrame("12:00:01.000", goodBattery, 0))
+	// battery drops for 2 seconds
+	d.Analyse(getFaultHistoryFrame("12:00:02.000", lowBattery, 0))
+	d.Analyse(getFaultHistoryFrame("12:00:03.000", lowBattery, 0))
+	d.Analyse(getFaultHistoryFrame("12:00:04.000", lowBattery, 0))
+	d.Analyse(getFaultHistoryFrame("12:00:05.000", goodBattery, 0))
+	// and again for a single frame
+	d.Analyse(getFaultHistoryFrame("12:00:06.000", lowBattery, 0))
+	d.Analyse(getFaultHistoryFrame("12:00:07.000", goodBattery, 0))
+
+	entry, found := d.GetFaultHistoryEntry("BatteryFault")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, entry.Occurrences, is.EqualTo(2))
+	then.AssertThat(t, entry.ActiveTime, is.EqualTo(time.Second*2))
+	then.AssertThat(t, entry.Active, is.False())
+	then.AssertThat(t, entry.FirstSeen.Format(timeFormat), is.EqualTo("12:00:02.000"))
+	then.AssertThat(t, entry.LastSeen.Format(timeFormat), is.EqualTo("12:00:06.000"))
+
+	// the freeze frame is the frame in which the fault first appeared
+	then.AssertThat(t, entry.FreezeFrame.Time, is.EqualTo("12:00:02.000"))
+	then.AssertThat(t, entry.FreezeFrame.BatteryVoltage, is.EqualTo(float32(lowBattery)))
+	then.AssertThat(t, entry.FreezeFrame.Analytics.BatteryFault, is.True())
+	then.AssertThat(t, len(entry.PreFaultFrames), is.EqualTo(2))
+	then.AssertThat(t, entry.PreFaultFrames[1].Time, is.EqualTo("12:00:01.000"))
+}
+
+func Test_faultHistory_PreFaultFramesLimited(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+
+	for i := 0; i < 10; i++ {
+		d.Analyse(getFaultHistoryFrame("12:00:00.000", goodBattery, 0))
+	}
+
+	d.Analyse(getFaultHistoryFrame("12:00:01.000", goodBattery, CoolantSensorFaultCode))
+
+	entry, found := d.GetFaultHistoryEntry("CoolantTempSensor")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, len(entry.PreFaultFrames), is.EqualTo(freezeFramePreFaultFrames))
+}
+
+func Test_faultHistory_OperationalFaultsIgnoredWhenEngineStopped(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+
+	data := getFaultHistoryFrame("12:00:00.000", lowBattery, AirSensorFaultCode)
+	data.EngineRPM = engineStopped
+	// with the engine stopped the manifold is at the barometric pressure
+	data.ManifoldAbsolutePressure = standardAtmosphericPressure
+	d.Analyse(data)
+
+	history := d.GetFaultHistory()
+	then.AssertThat(t, len(history), is.EqualTo(1))
+	then.AssertThat(t, history[0].Name, is.EqualTo("IntakeAirTempSensor"))
+}
+
+func Test_faultHistory_Scenario(t *testing.T) {
+	r := NewECUReaderInstance()
+	r.ecuReader = NewECUReader("testdata/full-warmup-working-lambda.fcr")
+	_, _ = r.connectToECU()
+
+	for i := 0; i < 300; i++ {
+		_, _ = r.GetDataframes()
+	}
+
+	// the crankshaft position sensor occasionally reads 0 during the warm up
+	entry, found := r.Diagnostics.GetFaultHistoryEntry("CrankshaftSensorFault")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, entry.Occurrences, is.GreaterThan(1))
+	then.AssertThat(t, entry.FreezeFrame.CrankshaftPositionSensor, is.EqualTo(0))
+	then.AssertThat(t, len(entry.PreFaultFrames), is.EqualTo(freezeFramePreFaultFrames))
+}
@@ -0,0 +1,107 @@
+package rosco
+
+const (
+	// the confidence in a finding is the proportion of its recent evaluations in which it was found
+	findingConfidenceEvaluations = 10
+	// a finding evaluated fewer times than this has a reduced confidence
+	minimumFindingEvaluations = 3
+	// ecu fault codes are reported by the ecu
+	faultCodeConfidence = 1.0
+)
+
+// Evidence is a value that shows the finding
+type Evidence struct {
+	Name  string  `json:"Name"`
+	Value float64 `json:"Value"`
+	Unit  string  `json:"Unit"`
+}
+
+// Finding is a fault found in the current dataframe by a diagnostic rule or decoded from the ecu fault codes
+type Finding struct {
+	Name     string   `json:"Name"`
+	Severity Severity `json:"Severity"`
+	// Confidence that the fault is present, 0 to 1
+	Confidence float64 `json:"Confidence"`
+	// Explanation describes what was found
+	Explanation string `json:"Explanation"`
+	// Remedy describes the checks and repairs from the knowledge base
+	Remedy string `json:"Remedy"`
+	// Evidence are the values that show the fault
+	Evidence []Evidence `json:"Evidence"`
+}
+
+// analyseFindings reports the triggered rules and ecu fault codes as findings
+func (df *DataframeAnalysis) analyseFindings(data MemsData) {
+	df.Analysis.Findings = nil
+
+	for _, outcome := range df.Analysis.Outcomes {
+		confidence := df.recordFindingOutcome(outcome.Name, outcome.Triggered)
+
+		if outcome.Triggered {
+			df.Analysis.Findings = append(df.Analysis.Findings, df.newFinding(outcome.Name, outcome.Severity, outcome.Explanation, confidence, data))
+		}
+	}
+
+	for _, fault := range DecodeFaultCodes(data) {
+		df.Analysis.Findings = append(df.Analysis.Findings, df.newFinding(fault.Name, SeverityFault, fault.Description, faultCodeConfidence, data))
+	}
+}
+
+// recordFindingOutcome records the outcome of the rule and returns the confidence in the finding
+func (df *DataframeAnalysis) recordFindingOutcome(name string, triggered bool) float64 {
+	outcomes := append(df.findingOutcomes[name], triggered)
+
+	if len(outcomes) > findingConfidenceEvaluations {
+		outcomes = outcomes[1:]
+	}
+
+	df.findingOutcomes[name] = outcomes
+
+	var count int
+	for _, outcome := range outcomes {
+		if outcome {
+			count++
+		}
+	}
+
+	evaluations := len(outcomes)
+	if evaluations < minimumFindingEvaluations {
+		evaluations = minimumFindingEvaluations
+	}
+
+	return roundTo(float64(count)/float64(evaluations), 2)
+}
+
+func (df *DataframeAnalysis) newFinding(name string, severity Severity, explanation string, confidence float64, data MemsData) Finding {
+	finding := Finding{
+		Name:        name,
+		Severity:    severity,
+		Confidence:  confidence,
+		Explanation: explanation,
+	}
+
+	entry, ok := knowledgeBase[name]
+	if !ok {
+		return finding
+	}
+
+	finding.Remedy = entry.remedy
+
+	for _, name := range entry.channels {
+		if metadata, ok := GetChannel(name); ok {
+			finding.Evidence = append(finding.Evidence, Evidence{Name: name, Value: roundTo(metadata.GetValue(data), 2), Unit: metadata.Unit})
+		}
+	}
+
+	if entry.measurements != nil {
+		finding.Evidence = append(finding.Evidence, entry.measurements(df.Analysis)...)
+	}
+
+	if entry.confidence != nil {
+		if measured := entry.confidence(df.Analysis); measured > 0 {
+			finding.Confidence = measured
+		}
+	}
+
+	return finding
+}
@@ -0,0 +1,156 @@
+package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"strings"
+	"testing"
+)
+
+func getFinding(d *DataframeAnalysis, name string) (Finding, bool) {
+	for _, finding := range d.Analysis.Findings {
+		if finding.Name == name {
+			return finding, true
+		}
+	}
+
+	return Finding{}, false
+}
+
+func getEvidence(finding Finding, name string) (Evidence, bool) {
+	for _, evidence := range finding.Evidence {
+		if evidence.Name == name {
+			return evidence, true
+		}
+	}
+
+	return Evidence{}, false
+}
+
+func Test_findings_KnowledgeBase(t *testing.T) {
+	var names []string
+
+	for _, rule := range append(getBuiltInRules(), getKeyOnRules()...) {
+		names = append(names, rule.Name())
+	}
+
+	for _, fault := range GetFaultCodeDefinitions() {
+		names = append(names, fault.Name)
+	}
+
+	for _, name := range names {
+		remedy, found := GetRemedy(name)
+		then.AssertThat(t, found, is.True().Reason(name))
+		then.AssertThat(t, remedy, is.Not(is.Empty()))
+	}
+
+	for name, entry := range knowledgeBase {
+		for _, channel := range entry.channels {
+			_, found := GetChannel(channel)
+			then.AssertThat(t, found, is.True().Reason(name+" "+channel))
+		}
+	}
+}
+
+func Test_findings_NoFaults(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+	d.Analyse(getFaultHistoryFrame("12:00:00.000", goodBattery, 0))
+
+	then.AssertThat(t, len(d.Analysis.Findings), is.EqualTo(0))
+}
+
+func Test_findings_Finding(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+	d.Analyse(getFaultHistoryFrame("12:00:00.000", lowBattery, 0))
+
+	// the boolean is kept for compatibility
+	then.AssertThat(t, d.Analysis.BatteryFault, is.True())
+
+	finding, found := getFinding(d, "BatteryFault")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, finding.Severity, is.EqualTo(SeverityWarning))
+	then.AssertThat(t, finding.Explanation, is.Not(is.Empty()))
+	then.AssertThat(t, strings.Contains(finding.Remedy, "alternator"), is.True())
+
+	evidence, found := getEvidence(finding, "BatteryVoltage")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, evidence.Value, is.EqualTo(lowBattery))
+	then.AssertThat(t, evidence.Unit, is.EqualTo("V"))
+}
+
+func Test_findings_Confidence(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+
+	// the confidence grows as the fault persists
+	d.Analyse(getFaultHistoryFrame("12:00:00.000", lowBattery, 0))
+	finding, _ := getFinding(d, "BatteryFault")
+	then.AssertThat(t, finding.Confidence, is.EqualTo(0.33))
+
+	d.Analyse(getFaultHistoryFrame("12:00:01.000", lowBattery, 0))
+	d.Analyse(getFaultHistoryFrame("12:00:02.000", lowBattery, 0))
+	finding, _ = getFinding(d, "BatteryFault")
+	then.AssertThat(t, finding.Confidence, is.EqualTo(1.0))
+
+	// an intermittent fault has a lower confidence
+	for _, timestamp := range []string{"12:00:03.000", "12:00:04.000", "12:00:05.000", "12:00:06.000", "12:00:07.000", "12:00:08.000"} {
+		d.Analyse(getFaultHistoryFrame(timestamp, goodBattery, 0))
+	}
+
+	d.Analyse(getFaultHistoryFrame("12:00:09.000", lowBattery, 0))
+	finding, _ = getFinding(d, "BatteryFault")
+	then.AssertThat(t, finding.Confidence, is.EqualTo(0.4))
+}
+
+func Test_findings_FaultCode(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+	d.Analyse(getFaultHistoryFrame("12:00:00.000", goodBattery, CoolantSensorFaultCode))
+
+	finding, found := getFinding(d, "CoolantTempSensor")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, finding.Severity, is.EqualTo(SeverityFault))
+	then.AssertThat(t, finding.Confidence, is.EqualTo(faultCodeConfidence))
+	then.AssertThat(t, finding.Explanation, is.EqualTo("coolant temperature sensor circuit fault"))
+	then.AssertThat(t, finding.Remedy, is.Not(is.Empty()))
+
+	evidence, _ := getEvidence(finding, "DTC0")
+	then.AssertThat(t, evidence.Value, is.EqualTo(float64(CoolantSensorFaultCode)))
+}
+
+func Test_findings_Measurements(t *testing.T) {
+	d := newElectricalSession().
+		add(3*chargingSettleTime, rpmIdle, 15.6).
+		analyse()
+
+	finding, found := getFinding(d, "OverchargingFault")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, finding.Severity, is.EqualTo(SeverityFault))
+
+	evidence, found := getEvidence(finding, "ChargingVoltage")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, evidence.Value, is.EqualTo(15.6))
+}
+
+func Test_findings_CustomRule(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+	_ = d.Rules.Register(NewDiagnosticRule("CustomFault", SeverityInfo, "custom rule", func(context RuleContext) bool { return true }))
+	d.Analyse(getFaultHistoryFrame("12:00:00.000", goodBattery, 0))
+
+	// rules without a knowledge base entry have no remedy
+	finding, found := getFinding(d, "CustomFault")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, finding.Severity, is.EqualTo(SeverityInfo))
+	then.AssertThat(t, finding.Remedy, is.EqualTo(""))
+	then.AssertThat(t, len(finding.Evidence), is.EqualTo(0))
+}
+
+func Test_findings_SessionReport(t *testing.T) {
+	report, err := NewSessionAnalyser().AnalyseScenario("testdata/nofaults.fcr")
+	then.AssertThat(t, err, is.Nil())
+
+	for _, fault := range report.Faults {
+		remedy, _ := GetRemedy(fault.Name)
+		then.AssertThat(t, fault.Remedy, is.EqualTo(remedy))
+	}
+
+	then.AssertThat(t, strings.Contains(report.ToMarkdown(), "## Remedies"), is.True())
+}
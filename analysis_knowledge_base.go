@@ -0,0 +1,265 @@
+package rosco
+
+// knowledgeBaseEntry is the guidance for a diagnostic finding
+type knowledgeBaseEntry struct {
+	// remedy describes the checks and repairs for the finding
+	remedy string
+	// channels are the dataframe channels shown as evidence for the finding
+	channels []string
+	// measurements are the values measured by the analysis shown as evidence for the finding
+	measurements func(report AnalysisReport) []Evidence
+	// confidence returns the confidence in the finding measured by the analysis, 0 if not measured
+	confidence func(report AnalysisReport) float64
+}
+
+// knowledgeBase holds the guidance for the diagnostic rules and ecu fault codes, keyed by name
+var knowledgeBase = map[string]knowledgeBaseEntry{
+	"BatteryFault": {
+		remedy:   "Check the battery terminals and earth straps are clean and tight, then check the alternator output and drive belt tension. A battery that will not hold a charge should be load tested.",
+		channels: []string{"BatteryVoltage", "EngineRPM"},
+	},
+	"CoilFault": {
+		remedy:   "The ecu is charging the coil for longer than expected with a good battery voltage. Check the coil resistance, the coil connector and the HT leads, and check the coil supply voltage with the engine running.",
+		channels: []string{"CoilTime", "BatteryVoltage"},
+	},
+	"MapFault": {
+		remedy:   "Check the MAP sensor vacuum pipe from the inlet manifold to the ecu for splits, kinks and a blocked or full fuel trap. Check the throttle is closing fully at idle.",
+		channels: []string{"ManifoldAbsolutePressure", "EngineRPM", "ThrottleAngle"},
+	},
+	"VacuumFault": {
+		remedy:   "Check for air leaks around the inlet manifold gasket, throttle body, brake servo hose and breather hoses, and check the MAP sensor vacuum pipe and fuel trap.",
+		channels: []string{"ManifoldAbsolutePressure", "Vacuum", "AirFuelRatio"},
+	},
+	"O2SystemFault": {
+		remedy:   "Check the lambda sensor connector and wiring, and the lambda heater relay and fuse.",
+		channels: []string{"LambdaStatus", "LambdaVoltage"},
+	},
+	"IsEngineIdleFault": {
+		remedy:   "The idle base position is calculated from the coolant temperature, check the coolant temperature sensor reads the engine temperature. A high position on a warm engine or a low position on a cold engine causes poor idle speed control.",
+		channels: []string{"IdleBasePosition", "CoolantTemp"},
+	},
+	"IdleHotFault": {
+		remedy:   "Idle hot outside the 10 to 50 range shows the ecu is compensating for the idle air flow. Check for air leaks, clean the throttle body and check the stepper motor moves freely. Reset the throttle adaptations once repaired.",
+		channels: []string{"IdleHot", "IACPosition", "CoolantTemp"},
+	},
+	"IdleAirControlFault": {
+		remedy:   "The stepper motor is not responding to the idle speed offset. Check the stepper motor connector and wiring, remove the stepper and check it moves freely, and clean the air passage in the throttle body.",
+		channels: []string{"IACPosition", "IdleSpeedOffset"},
+	},
+	"IdleAirControlRangeFault": {
+		remedy:   "A high stepper position shows the ecu is opening the stepper to compensate for a restricted air path, clean the throttle body and air passages. A low position shows the ecu is closing the stepper to compensate for an air leak, check the inlet hoses and gaskets.",
+		channels: []string{"IACPosition", "CoolantTemp"},
+	},
+	"IdleAirControlJackFault": {
+		remedy:   "The ecu has re-learnt the stepper position many times. Check the throttle cable adjustment allows the throttle to close fully, check the throttle pot and the stepper motor.",
+		channels: []string{"JackCount", "ThrottleAngle", "IACPosition"},
+	},
+	"IdleSpeedFault": {
+		remedy:   "The ecu is not in control of the idle speed. Check for air leaks, a sticking stepper motor and the throttle closing fully.",
+		channels: []string{"IdleBasePosition", "EngineRPM"},
+	},
+	"IdleErrorFault": {
+		remedy:   "The engine speed is not reaching the idle set point. Check for air leaks, clean the throttle body and check the stepper motor.",
+		channels: []string{"IdleSpeedDeviation", "IdleSetPoint", "EngineRPM"},
+	},
+	"IdleHuntingFault": {
+		remedy:   "The ecu is over correcting the idle speed. Check for air leaks, a sticking stepper motor or a dirty throttle body.",
+		channels: []string{"EngineRPM", "IACPosition"},
+		measurements: func(report AnalysisReport) []Evidence {
+			return []Evidence{
+				{Name: "HuntingFrequency", Value: report.RPMSpectrum.DominantFrequency, Unit: "Hz"},
+				{Name: "HuntingAmplitude", Value: report.RPMSpectrum.DominantAmplitude, Unit: "RPM"},
+			}
+		},
+	},
+	"MisfireFault": {
+		remedy:   "Check the spark plugs, HT leads, coil and injectors. A misfire on a cold engine that clears as it warms may be a failing coil or leads.",
+		channels: []string{"EngineRPM", "CoilTime"},
+		measurements: func(report AnalysisReport) []Evidence {
+			return []Evidence{
+				{Name: "RPMRoughness", Value: report.RPMSpectrum.Roughness, Unit: "RPM"},
+				{Name: "HighFrequencyRatio", Value: report.RPMSpectrum.HighFrequencyRatio},
+			}
+		},
+	},
+	"LambdaRangeFault": {
+		remedy:   "The lambda voltage is outside the sensor range, check the lambda sensor wiring for a short or open circuit.",
+		channels: []string{"LambdaVoltage"},
+	},
+	"LambdaOscillationFault": {
+		remedy:   "The lambda voltage is not switching between rich and lean. Check the lambda heater relay and fuse, the sensor wiring and for exhaust leaks before the sensor, then replace the sensor.",
+		channels: []string{"LambdaVoltage", "ClosedLoop"},
+	},
+	"LambdaLazyFault": {
+		remedy:   "A slow switching lambda sensor is worn or contaminated by oil, coolant or silicone. Check for exhaust leaks before the sensor and replace the sensor.",
+		channels: []string{"LambdaVoltage"},
+		measurements: func(report AnalysisReport) []Evidence {
+			return []Evidence{
+				{Name: "SwitchingFrequency", Value: report.Lambda.SwitchingFrequency, Unit: "Hz"},
+				{Name: "RichToLeanTime", Value: float64(report.Lambda.RichToLeanTime.Milliseconds()), Unit: "ms"},
+				{Name: "LeanToRichTime", Value: float64(report.Lambda.LeanToRichTime.Milliseconds()), Unit: "ms"},
+			}
+		},
+	},
+	"LambdaMixtureFault": {
+		remedy:   "A rich mixture may be a leaking injector, high fuel pressure or a faulty coolant temperature sensor. A lean mixture may be an air leak, low fuel pressure or a blocked injector.",
+		channels: []string{"LambdaVoltage", "ShortTermFuelTrim", "LongTermFuelTrim"},
+		measurements: func(report AnalysisReport) []Evidence {
+			return []Evidence{
+				{Name: "AverageLambdaVoltage", Value: report.Lambda.AverageVoltage, Unit: "mV"},
+			}
+		},
+	},
+	"ClosedLoopFault": {
+		remedy:   "The ecu has not entered closed loop on a warm engine. Check the lambda sensor and heater, and the coolant temperature sensor reads the engine temperature.",
+		channels: []string{"ClosedLoop", "CoolantTemp", "LambdaVoltage"},
+	},
+	"ThermostatFault": {
+		remedy:   "A thermostat stuck open warms the engine slowly and plateaus below the operating temperature, a thermostat stuck closed overheats the engine. Check the coolant level and bleed the cooling system before replacing the thermostat. A slow warm-up can also be a faulty coolant temperature sensor.",
+		channels: []string{"CoolantTemp", "IntakeAirTemp"},
+		measurements: func(report AnalysisReport) []Evidence {
+			return []Evidence{
+				{Name: "ThermostatConfidence", Value: report.ThermostatConfidence},
+			}
+		},
+		confidence: func(report AnalysisReport) float64 {
+			// the confidence of the warm-up model when it shows the thermostat stuck
+			if report.ThermostatState == ThermostatStuckOpen || report.ThermostatState == ThermostatStuckClosed {
+				return report.ThermostatConfidence
+			}
+			return 0
+		},
+	},
+	"CrankshaftSensorFault": {
+		remedy:   "Check the crankshaft position sensor connector and wiring, and the gap between the sensor and the flywheel.",
+		channels: []string{"CrankshaftPositionSensor", "EngineRPM"},
+	},
+	"StuckSensorFault": {
+		remedy:   "A sensor reading that does not change is a disconnected or failed sensor. Check the connector and wiring of the sensors listed in the analysis report.",
+		channels: []string{"CoolantTemp", "IntakeAirTemp", "ManifoldAbsolutePressure", "ThrottlePotSensor", "LambdaVoltage"},
+	},
+	"NoisySensorFault": {
+		remedy:   "A sensor reading that jumps faster than the sensor can respond is an intermittent connection. Check the connector for corrosion and the wiring for chafing of the sensors listed in the analysis report.",
+		channels: []string{"CoolantTemp", "IntakeAirTemp", "ManifoldAbsolutePressure"},
+	},
+	"CrankingVoltageFault": {
+		remedy:   "Charge and load test the battery, then check the starter motor current draw and the battery and earth connections.",
+		channels: []string{"BatteryVoltage"},
+		measurements: func(report AnalysisReport) []Evidence {
+			return []Evidence{
+				{Name: "CrankingVoltage", Value: report.Electrical.CrankingVoltage, Unit: "V"},
+			}
+		},
+	},
+	"OverchargingFault": {
+		remedy:   "The alternator voltage regulator has failed, overcharging boils the battery electrolyte. Replace the regulator or alternator.",
+		channels: []string{"BatteryVoltage", "EngineRPM"},
+		measurements: func(report AnalysisReport) []Evidence {
+			return []Evidence{
+				{Name: "ChargingVoltage", Value: report.Electrical.ChargingVoltage, Unit: "V"},
+			}
+		},
+	},
+	"ChargingLoadFault": {
+		remedy:   "The charging system cannot hold the voltage when the fans or aircon switch on. Check the alternator drive belt tension, the alternator output and the battery and earth connections.",
+		channels: []string{"BatteryVoltage", "AirconSwitch", "EngineRPM"},
+		measurements: func(report AnalysisReport) []Evidence {
+			return []Evidence{
+				{Name: "ChargingVoltage", Value: report.Electrical.ChargingVoltage, Unit: "V"},
+				{Name: "LoadVoltageDrop", Value: report.Electrical.LoadVoltageDrop, Unit: "V"},
+			}
+		},
+	},
+	"MAPPlausibilityFault": {
+		remedy:   "With the engine off the MAP sensor should read the barometric pressure. Check the vacuum pipe and fuel trap are not blocked, then check the MAP sensor in the ecu.",
+		channels: []string{"ManifoldAbsolutePressure"},
+	},
+	"TemperaturePlausibilityFault": {
+		remedy:   "On a cold engine the coolant and intake air temperatures should agree. Check the coolant and intake air temperature sensors and their wiring.",
+		channels: []string{"CoolantTemp", "IntakeAirTemp"},
+	},
+	"BatteryPlausibilityFault": {
+		remedy:   "A charged battery rests at 12.4 to 12.9V. Charge and load test the battery, a voltage above this with the engine off is a fault in the ecu supply or the voltage reading.",
+		channels: []string{"BatteryVoltage"},
+	},
+	"ThrottlePotPlausibilityFault": {
+		remedy:   "Check the throttle cable adjustment allows the throttle to close fully and the throttle pot is secure. Reset the throttle adaptations once adjusted.",
+		channels: []string{"ThrottlePotSensor", "ThrottleAngle"},
+	},
+
+	// ecu fault codes
+	"CoolantTempSensor": {
+		remedy:   "Check the coolant temperature sensor connector and wiring for an open or short circuit, and the sensor resistance against the temperature.",
+		channels: []string{"CoolantTemp", "DTC0"},
+	},
+	"IntakeAirTempSensor": {
+		remedy:   "Check the intake air temperature sensor connector and wiring for an open or short circuit, and the sensor resistance against the temperature.",
+		channels: []string{"IntakeAirTemp", "DTC0"},
+	},
+	"FuelPumpCircuit": {
+		remedy:   "Check the fuel pump relay, the inertia switch and the fuel pump wiring.",
+		channels: []string{"DTC1"},
+	},
+	"ThrottlePotCircuit": {
+		remedy:   "Check the throttle pot connector and wiring, and the throttle pot voltage sweeps smoothly as the throttle is opened.",
+		channels: []string{"ThrottlePotSensor", "DTC1"},
+	},
+	"MAPSensor": {
+		remedy:   "Check the MAP sensor vacuum pipe and fuel trap. The MAP sensor is internal to the ecu.",
+		channels: []string{"ManifoldAbsolutePressure", "DTC1"},
+	},
+	"TurboOverboost": {
+		remedy:   "Check the wastegate actuator and its hose, and the boost control valve and wiring.",
+		channels: []string{"ManifoldAbsolutePressure", "DTC0"},
+	},
+	"AmbientTempSensor": {
+		remedy:   "Check the ambient temperature sensor connector and wiring for an open or short circuit.",
+		channels: []string{"AmbientTemp", "DTC0"},
+	},
+	"FuelRailTempSensor": {
+		remedy:   "Check the fuel rail temperature sensor connector and wiring for an open or short circuit.",
+		channels: []string{"FuelTemp", "DTC0"},
+	},
+	"Knock": {
+		remedy:   "Check the fuel is the correct octane, the ignition timing and for carbon build up. Check the knock sensor is tightened to the correct torque.",
+		channels: []string{"IgnitionAdvance", "DTC0"},
+	},
+	"CoolantTempGauge": {
+		remedy:   "Check the coolant temperature gauge wiring from the ecu to the instrument pack.",
+		channels: []string{"DTC1"},
+	},
+	"AirConClutch": {
+		remedy:   "Check the aircon clutch relay, fuse and wiring.",
+		channels: []string{"AirconSwitch", "DTC1"},
+	},
+	"PurgeValve": {
+		remedy:   "Check the carbon canister purge valve connector and wiring, and the valve is not stuck open.",
+		channels: []string{"CarbonCanisterPurgeValve", "DTC1"},
+	},
+	"BoostValve": {
+		remedy:   "Check the boost control valve connector, wiring and hoses.",
+		channels: []string{"ManifoldAbsolutePressure", "DTC1"},
+	},
+	"LambdaHeaterRelay": {
+		remedy:   "Check the lambda heater relay, fuse and the heater wiring to the lambda sensor.",
+		channels: []string{"DTC2"},
+	},
+	"SecondaryTriggerSync": {
+		remedy:   "Check the camshaft position sensor connector and wiring, and the timing belt has not jumped a tooth.",
+		channels: []string{"DTC2", "EngineRPM"},
+	},
+	"Fan1Control": {
+		remedy:   "Check the radiator fan 1 relay, fuse and wiring, and test the fan with the actuator test.",
+		channels: []string{"DTC2", "CoolantTemp"},
+	},
+	"PrimaryTriggerSync": {
+		remedy:   "Check the crankshaft position sensor connector and wiring, and the gap between the sensor and the flywheel.",
+		channels: []string{"DTC3", "EngineRPM"},
+	},
+}
+
+// GetRemedy returns the remedy for the named diagnostic rule or ecu fault code from the knowledge base
+func GetRemedy(name string) (string, bool) {
+	entry, ok := knowledgeBase[name]
+	return entry.remedy, ok
+}
@@ -0,0 +1,246 @@
+package rosco
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// lambda voltage at the stoichiometric mixture, higher voltages are rich and lower voltages lean
+	lambdaStoichiometricVoltage = 450
+	// a transition from lean to rich completes above this voltage
+	lambdaRichVoltage = 600
+	// a transition from rich to lean completes below this voltage
+	lambdaLeanVoltage = 300
+	// period of closed loop operation analysed in the live analysis
+	lambdaAnalysisWindow = 60 * time.Second
+	// minimum period of closed loop operation required to assess the sensor
+	lambdaMinimumAnalysisTime = 20 * time.Second
+	// samples further apart than this are not treated as continuous
+	lambdaMaximumSampleGap = 2 * time.Second
+)
+
+// LambdaAnalysis measures the performance of the lambda sensor whilst the ecu is in closed loop
+// a healthy sensor switches between rich and lean several times a second with a mean voltage around 450mV
+type LambdaAnalysis struct {
+	// Samples is the number of closed loop lambda voltage samples analysed
+	Samples int `json:"Samples"`
+	// Duration of closed loop operation analysed
+	Duration time.Duration `json:"Duration"`
+	// SwitchingFrequency is the number of rich-lean cycles per second
+	SwitchingFrequency float64 `json:"SwitchingFrequency"`
+	// CrossCountsPerSecond is the number of times per second the voltage crosses 450mV
+	CrossCountsPerSecond float64 `json:"CrossCountsPerSecond"`
+	// RichToLeanTime is the average time taken to switch from above 600mV to below 300mV
+	RichToLeanTime time.Duration `json:"RichToLeanTime"`
+	// LeanToRichTime is the average time taken to switch from below 300mV to above 600mV
+	LeanToRichTime time.Duration `json:"LeanToRichTime"`
+	// RichPercentage of the time the voltage is above 450mV
+	RichPercentage float64 `json:"RichPercentage"`
+	// LeanPercentage of the time the voltage is below 450mV
+	LeanPercentage float64 `json:"LeanPercentage"`
+	// AverageVoltage is the mean lambda voltage in mV
+	AverageVoltage float64 `json:"AverageVoltage"`
+	// IsLazy is true if the sensor is switching slowly
+	IsLazy bool `json:"IsLazy"`
+	// IsRichBiased is true if the mean voltage shows the mixture is running rich
+	IsRichBiased bool `json:"IsRichBiased"`
+	// IsLeanBiased is true if the mean voltage shows the mixture is running lean
+	IsLeanBiased bool `json:"IsLeanBiased"`
+}
+
+type lambdaSample struct {
+	timestamp time.Time
+	voltage   int
+}
+
+// lambdaAnalyser records the lambda voltage whilst the ecu is in closed loop
+// the samples are limited to the window, a zero window keeps every sample
+type lambdaAnalyser struct {
+	window  time.Duration
+	samples []lambdaSample
+}
+
+func newLambdaAnalyser(window time.Duration) *lambdaAnalyser {
+	return &lambdaAnalyser{window: window}
+}
+
+// add records the lambda voltage of the dataframe if the ecu is in closed loop
+// voltages outside the sensor range are ignored
+func (analyser *lambdaAnalyser) add(data MemsData, profile ThresholdProfile) {
+	if !data.ClosedLoop || data.LambdaVoltage < profile.LowestLambdaValue || data.LambdaVoltage > profile.HighestLambdaValue {
+		return
+	}
+
+	// a replayed scenario restarts from the beginning when it reaches the end
+	if n := len(analyser.samples); n > 0 && data.Timestamp.Before(analyser.samples[n-1].timestamp) {
+		analyser.samples = nil
+	}
+
+	analyser.samples = append(analyser.samples, lambdaSample{timestamp: data.Timestamp, voltage: data.LambdaVoltage})
+
+	if analyser.window > 0 {
+		start := 0
+		for start < len(analyser.samples) && data.Timestamp.Sub(analyser.samples[start].timestamp) > analyser.window {
+			start++
+		}
+		analyser.samples = analyser.samples[start:]
+	}
+}
+
+// analyse measures the switching of the recorded samples
+func (analyser *lambdaAnalyser) analyse(profile ThresholdProfile) LambdaAnalysis {
+	var analysis LambdaAnalysis
+	var sum float64
+	var crossings, richToLeanCount, leanToRichCount int
+	var rich, richToLean, leanToRich time.Duration
+	var lastRichAt, lastLeanAt time.Time
+
+	analysis.Samples = len(analyser.samples)
+
+	for i, sample := range analyser.samples {
+		sum += float64(sample.voltage)
+
+		if i > 0 {
+			previous := analyser.samples[i-1]
+			interval := sample.timestamp.Sub(previous.timestamp)
+
+			if interval > lambdaMaximumSampleGap {
+				// a gap in closed loop operation, restart the transition timing
+				lastRichAt, lastLeanAt = time.Time{}, time.Time{}
+			} else {
+				analysis.Duration += interval
+
+				// the interval is spent in the state of the previous sample
+				if previous.voltage >= lambdaStoichiometricVoltage {
+					rich += interval
+				}
+
+				if (previous.voltage >= lambdaStoichiometricVoltage) != (sample.voltage >= lambdaStoichiometricVoltage) {
+					crossings++
+				}
+			}
+		}
+
+		// time from leaving rich to arriving lean, and leaving lean to arriving rich
+		if sample.voltage > lambdaRichVoltage {
+			if !lastLeanAt.IsZero() {
+				leanToRich += sample.timestamp.Sub(lastLeanAt)
+				leanToRichCount++
+			}
+			lastRichAt = sample.timestamp
+			lastLeanAt = time.Time{}
+		} else if sample.voltage < lambdaLeanVoltage {
+			if !lastRichAt.IsZero() {
+				richToLean += sample.timestamp.Sub(lastRichAt)
+				richToLeanCount++
+			}
+			lastLeanAt = sample.timestamp
+			lastRichAt = time.Time{}
+		}
+	}
+
+	if analysis.Samples == 0 {
+		return analysis
+	}
+
+	analysis.AverageVoltage = roundTo(sum/float64(analysis.Samples), 1)
+
+	if analysis.Duration > 0 {
+		seconds := analysis.Duration.Seconds()
+		analysis.CrossCountsPerSecond = roundTo(float64(crossings)/seconds, 2)
+		analysis.SwitchingFrequency = roundTo(float64(crossings)/2/seconds, 2)
+		analysis.RichPercentage = roundTo(float64(rich)/float64(analysis.Duration)*100, 1)
+		analysis.LeanPercentage = roundTo(100-analysis.RichPercentage, 1)
+	}
+
+	if richToLeanCount > 0 {
+		analysis.RichToLeanTime = (richToLean / time.Duration(richToLeanCount)).Round(time.Millisecond)
+	}
+
+	if leanToRichCount > 0 {
+		analysis.LeanToRichTime = (leanToRich / time.Duration(leanToRichCount)).Round(time.Millisecond)
+	}
+
+	// too little closed loop operation to assess the sensor
+	if analysis.Duration < lambdaMinimumAnalysisTime {
+		return analysis
+	}
+
+	highestTransitionTime := time.Duration(profile.HighestLambdaTransitionTime) * time.Millisecond
+
+	analysis.IsLazy = analysis.SwitchingFrequency < profile.LowestLambdaSwitchingFrequency ||
+		analysis.RichToLeanTime > highestTransitionTime ||
+		analysis.LeanToRichTime > highestTransitionTime
+
+	analysis.IsRichBiased = analysis.AverageVoltage > float64(profile.HighestLambdaAverageVoltage)
+	analysis.IsLeanBiased = analysis.AverageVoltage < float64(profile.LowestLambdaAverageVoltage)
+
+	return analysis
+}
+
+// a lazy lambda sensor switches slowly, the ecu is unable to correct the mixture quickly
+// indicates a worn or contaminated sensor
+func (df *DataframeAnalysis) isLambdaLazy(data MemsData) bool {
+	return df.isLambdaSensorReady(data) && df.Analysis.Lambda.IsLazy
+}
+
+// the mean lambda voltage in closed loop should be around 450mV, a bias indicates the ecu is
+// unable to correct the mixture, check for air leaks, fuel pressure and injectors
+func (df *DataframeAnalysis) isLambdaMixtureBiased(data MemsData) bool {
+	return df.isLambdaSensorReady(data) && (df.Analysis.Lambda.IsRichBiased || df.Analysis.Lambda.IsLeanBiased)
+}
+
+// the lambda sensor is only assessed in closed loop once the heater has brought it up to temperature
+func (df *DataframeAnalysis) isLambdaSensorReady(data MemsData) bool {
+	if data.ClosedLoop && df.isEngineRunning(data) && !df.engineStartedAt.IsZero() {
+		currentTime := df.getTimestamp(data)
+		return currentTime.After(df.getExpectedLambdaOscillationTime(MemsData{Timestamp: df.engineStartedAt}))
+	}
+
+	return false
+}
+
+// toMarkdown returns the lambda analysis as a Markdown table
+func (analysis LambdaAnalysis) toMarkdown() string {
+	var sb strings.Builder
+
+	if analysis.Duration < lambdaMinimumAnalysisTime {
+		return "Not enough closed loop operation was recorded to assess the lambda sensor.\n"
+	}
+
+	sb.WriteString("| Lambda | |\n|--------|--------|\n")
+	sb.WriteString(fmt.Sprintf("| Closed Loop | %s (%d samples) |\n", humanizeDuration(analysis.Duration), analysis.Samples))
+	sb.WriteString(fmt.Sprintf("| Switching Frequency | %.2f Hz |\n", analysis.SwitchingFrequency))
+	sb.WriteString(fmt.Sprintf("| Cross Counts | %.2f per second |\n", analysis.CrossCountsPerSecond))
+	sb.WriteString(fmt.Sprintf("| Rich to Lean | %s |\n", analysis.RichToLeanTime))
+	sb.WriteString(fmt.Sprintf("| Lean to Rich | %s |\n", analysis.LeanToRichTime))
+	sb.WriteString(fmt.Sprintf("| Rich / Lean | %.1f%% / %.1f%% |\n", analysis.RichPercentage, analysis.LeanPercentage))
+	sb.WriteString(fmt.Sprintf("| Average Voltage | %.1f mV |\n", analysis.AverageVoltage))
+	sb.WriteString(fmt.Sprintf("| Sensor | %s |\n", analysis.getCondition()))
+
+	return sb.String()
+}
+
+func (analysis LambdaAnalysis) getCondition() string {
+	var conditions []string
+
+	if analysis.IsLazy {
+		conditions = append(conditions, "lazy")
+	}
+
+	if analysis.IsRichBiased {
+		conditions = append(conditions, "rich biased")
+	}
+
+	if analysis.IsLeanBiased {
+		conditions = append(conditions, "lean biased")
+	}
+
+	if len(conditions) == 0 {
+		return "ok"
+	}
+
+	return strings.Join(conditions, ", ")
+}
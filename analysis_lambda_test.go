@@ -0,0 +1,167 @@
+package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lambda dataframes are read every half second
+const lambdaFrameInterval = 500 * time.Millisecond
+
+// lambdaSignal closes the loop and sets the lambda voltage from a function of the sample number
+func lambdaSignal(lambdaVoltage func(sample int) int) func(sample int, elapsed time.Duration, data *MemsData) {
+	return func(sample int, elapsed time.Duration, data *MemsData) {
+		data.ClosedLoop = true
+		data.LambdaVoltage = lambdaVoltage(sample)
+	}
+}
+
+// squareWave alternates between the low and high voltage every number of samples
+func squareWave(low int, high int, samples int) func(sample int) int {
+	return func(sample int) int {
+		if (sample/samples)%2 == 0 {
+			return low
+		}
+		return high
+	}
+}
+
+func analyseLambdaFrames(frames []MemsData, window time.Duration) LambdaAnalysis {
+	analyser := newLambdaAnalyser(window)
+
+	for _, data := range frames {
+		analyser.add(data, DefaultThresholdProfile)
+	}
+
+	return analyser.analyse(DefaultThresholdProfile)
+}
+
+func Test_lambda_Healthy(t *testing.T) {
+	analysis := analyseLambdaFrames(getTestFrames(time.Minute, lambdaFrameInterval, lambdaSignal(squareWave(150, 750, 1))), 0)
+
+	then.AssertThat(t, analysis.Samples, is.EqualTo(121))
+	then.AssertThat(t, analysis.Duration, is.EqualTo(time.Minute))
+	then.AssertThat(t, analysis.CrossCountsPerSecond, is.EqualTo(2.0))
+	then.AssertThat(t, analysis.SwitchingFrequency, is.EqualTo(1.0))
+	then.AssertThat(t, analysis.RichToLeanTime, is.EqualTo(500*time.Millisecond))
+	then.AssertThat(t, analysis.LeanToRichTime, is.EqualTo(500*time.Millisecond))
+	then.AssertThat(t, analysis.RichPercentage, is.EqualTo(50.0))
+	then.AssertThat(t, analysis.LeanPercentage, is.EqualTo(50.0))
+	then.AssertThat(t, analysis.AverageVoltage, is.EqualTo(447.5))
+	then.AssertThat(t, analysis.IsLazy, is.False())
+	then.AssertThat(t, analysis.IsRichBiased, is.False())
+	then.AssertThat(t, analysis.IsLeanBiased, is.False())
+}
+
+func Test_lambda_LowSwitchingFrequency(t *testing.T) {
+	// switches every 4 seconds
+	analysis := analyseLambdaFrames(getTestFrames(time.Minute, lambdaFrameInterval, lambdaSignal(squareWave(150, 750, 8))), 0)
+
+	then.AssertThat(t, analysis.SwitchingFrequency, is.LessThan(KSeriesProfile.LowestLambdaSwitchingFrequency))
+	then.AssertThat(t, analysis.IsLazy, is.True())
+}
+
+func Test_lambda_SlowTransition(t *testing.T) {
+	// takes 2 seconds to move between rich and lean
+	voltages := []int{150, 300, 400, 500, 600, 750, 600, 500, 400, 300}
+	analysis := analyseLambdaFrames(getTestFrames(time.Minute, lambdaFrameInterval, lambdaSignal(func(sample int) int {
+		return voltages[sample%len(voltages)]
+	})), 0)
+
+	then.AssertThat(t, analysis.LeanToRichTime, is.EqualTo(2500*time.Millisecond))
+	then.AssertThat(t, analysis.RichToLeanTime, is.EqualTo(2500*time.Millisecond))
+	then.AssertThat(t, analysis.IsLazy, is.True())
+}
+
+func Test_lambda_Bias(t *testing.T) {
+	analysis := analyseLambdaFrames(getTestFrames(time.Minute, lambdaFrameInterval, lambdaSignal(squareWave(400, 850, 1))), 0)
+	then.AssertThat(t, analysis.IsRichBiased, is.True())
+	then.AssertThat(t, analysis.IsLeanBiased, is.False())
+
+	analysis = analyseLambdaFrames(getTestFrames(time.Minute, lambdaFrameInterval, lambdaSignal(squareWave(50, 500, 1))), 0)
+	then.AssertThat(t, analysis.IsRichBiased, is.False())
+	then.AssertThat(t, analysis.IsLeanBiased, is.True())
+}
+
+func Test_lambda_NotEnoughClosedLoop(t *testing.T) {
+	analysis := analyseLambdaFrames(getTestFrames(10*time.Second, lambdaFrameInterval, lambdaSignal(squareWave(150, 750, 40))), 0)
+
+	then.AssertThat(t, analysis.Samples, is.EqualTo(21))
+	then.AssertThat(t, analysis.IsLazy, is.False())
+	then.AssertThat(t, strings.HasPrefix(analysis.toMarkdown(), "Not enough"), is.True())
+}
+
+func Test_lambda_IgnoredSamples(t *testing.T) {
+	frames := getTestFrames(time.Minute, lambdaFrameInterval, lambdaSignal(squareWave(150, 750, 1)))
+
+	// open loop and out of range voltages are not analysed
+	frames[1].ClosedLoop = false
+	frames[2].LambdaVoltage = 1275
+
+	analysis := analyseLambdaFrames(frames, 0)
+	then.AssertThat(t, analysis.Samples, is.EqualTo(119))
+
+	// samples either side of a gap are not continuous
+	frames = getTestFrames(time.Minute, lambdaFrameInterval, lambdaSignal(squareWave(150, 750, 1)))
+	frames = append(frames[:40], frames[50:]...)
+
+	analysis = analyseLambdaFrames(frames, 0)
+	then.AssertThat(t, analysis.Duration, is.EqualTo(54500*time.Millisecond))
+	then.AssertThat(t, analysis.RichToLeanTime, is.EqualTo(500*time.Millisecond))
+}
+
+func Test_lambda_Window(t *testing.T) {
+	analysis := analyseLambdaFrames(getTestFrames(2*time.Minute, lambdaFrameInterval, lambdaSignal(squareWave(150, 750, 1))), lambdaAnalysisWindow)
+
+	then.AssertThat(t, analysis.Samples, is.EqualTo(121))
+	then.AssertThat(t, analysis.Duration, is.EqualTo(lambdaAnalysisWindow))
+}
+
+func Test_lambda_isLambdaLazy(t *testing.T) {
+	frames := getTestFrames(time.Minute, lambdaFrameInterval, lambdaSignal(squareWave(150, 750, 8)))
+	d := analyseTestFrames(NewDataframeAnalysis(20), frames)
+
+	data := frames[len(frames)-1]
+	then.AssertThat(t, d.Analysis.Lambda.IsLazy, is.True())
+
+	// the sensor is not assessed until the heater has warmed it
+	then.AssertThat(t, d.isLambdaLazy(data), is.False())
+
+	d.engineStartedAt = data.Timestamp.Add(-time.Duration(d.profile.LambdaOscillationDelay+1) * time.Second)
+	then.AssertThat(t, d.isLambdaLazy(data), is.True())
+
+	data.ClosedLoop = false
+	then.AssertThat(t, d.isLambdaLazy(data), is.False())
+}
+
+func Test_lambda_Recordings(t *testing.T) {
+	// the lambda sensor of both recordings switches about stoichiometric
+	for filename, d := range analyseRecordings(t) {
+		lambda := d.Analysis.Lambda
+
+		then.AssertThat(t, lambda.Samples, is.GreaterThan(0).Reason(filename))
+		then.AssertThat(t, lambda.SwitchingFrequency, is.GreaterThan(float64(0)).Reason(filename))
+		then.AssertThat(t, lambda.AverageVoltage, is.GreaterThan(float64(leanLambdaVoltage)).Reason(filename))
+		then.AssertThat(t, lambda.AverageVoltage, is.LessThan(float64(richLambdaVoltage)).Reason(filename))
+		then.AssertThat(t, lambda.IsRichBiased || lambda.IsLeanBiased, is.False().Reason(filename))
+	}
+}
+
+func Test_lambda_SessionReport(t *testing.T) {
+	report, err := NewSessionAnalyser().AnalyseScenario("testdata/full-warmup-working-lambda.fcr")
+	then.AssertThat(t, err, is.Nil())
+
+	// the lambda is measured whilst the ecu is in closed loop
+	then.AssertThat(t, report.Lambda.Duration.Seconds(), is.GreaterThan(float64(0)))
+	then.AssertThat(t, report.Lambda.SwitchingFrequency, is.GreaterThan(KSeriesProfile.LowestLambdaSwitchingFrequency))
+	then.AssertThat(t, report.Lambda.IsLazy, is.False())
+	then.AssertThat(t, report.Lambda.IsRichBiased || report.Lambda.IsLeanBiased, is.False())
+	then.AssertThat(t, strings.Contains(report.ToMarkdown(), "| Sensor | ok |"), is.True())
+
+	for _, fault := range report.Faults {
+		then.AssertThat(t, fault.Name, is.Not(is.EqualTo("LambdaLazyFault")))
+	}
+}
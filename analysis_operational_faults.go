@@ -5,32 +5,20 @@ import (
 	"time"
 )
 
+// analyseOperationalFaults evaluates the diagnostic rules and records the outcomes in the analysis report
 func (df *DataframeAnalysis) analyseOperationalFaults(data MemsData) {
-	df.Analysis.BatteryFault = df.isBatteryVoltageLow(data)
-	df.Analysis.CoilFault = df.isCoilFaulty(data)
-	df.Analysis.MapFault = df.isMAPHigh(data)
-	df.Analysis.O2SystemFault = !df.isO2SystemActive(data)
-	df.Analysis.IsEngineIdleFault = df.isEngineIdleFaulty(data)
-	df.Analysis.IdleHotFault = df.isHotIdleFaulty(data)
-	df.Analysis.IdleAirControlFault = df.isIACFaulty(data)
-	df.Analysis.VacuumFault = df.isVacuumFaulty(data)
-	df.Analysis.LambdaRangeFault = df.isLambdaOutOfRange(data)
-	df.Analysis.IdleAirControlJackFault = df.isJackCountHigh(data)
-	df.Analysis.CrankshaftSensorFault = df.isCrankshaftSensorFaulty(data)
-	df.Analysis.ThermostatFault = df.isThermostatFaulty(data)
-	df.Analysis.IdleSpeedFault = df.isIdleSpeedFaulty(data)
-	df.Analysis.LambdaOscillationFault = df.isLambdaFaulty(data)
+	df.Analysis.Outcomes = df.evaluateRules(df.Rules, data)
 }
 
 func (df *DataframeAnalysis) isBatteryVoltageLow(data MemsData) bool {
-	return data.BatteryVoltage < lowestBatteryVoltage
+	return data.BatteryVoltage < df.profile.LowestBatteryVoltage
 }
 
 func (df *DataframeAnalysis) isCoilFaulty(data MemsData) bool {
 	// battery must not be low as this will affect the coil timing
 	if df.isEngineRunning(data) {
 		return !df.isBatteryVoltageLow(data) &&
-			data.CoilTime > highestIdleCoilTime
+			data.CoilTime > df.profile.HighestIdleCoilTime
 	} else {
 		return false
 	}
@@ -39,7 +27,7 @@ func (df *DataframeAnalysis) isCoilFaulty(data MemsData) bool {
 func (df *DataframeAnalysis) isMAPHigh(data MemsData) bool {
 	// MAP value should be less than 45kPa when the engine is at idle
 	return df.isEngineIdle(data) &&
-		data.ManifoldAbsolutePressure > highestIdleMAPValue
+		data.ManifoldAbsolutePressure > df.profile.HighestIdleMAPValue
 }
 
 func (df *DataframeAnalysis) isO2SystemActive(data MemsData) bool {
@@ -53,12 +41,13 @@ func (df *DataframeAnalysis) isEngineIdleFaulty(data MemsData) bool {
 	// Idle run line position is calculated by the ECU using the engine coolant temperature sensor.
 
 	if df.isEngineIdle(data) {
-		if df.isEngineWarm(data) {
+		// the position only falls to the warm position once the engine reaches operating temperature
+		if data.CoolantTemp >= df.profile.EngineOperatingTemp {
 			// fault if > 50 when engine is warm
-			return data.IdleBasePosition > highestIdleBasePosition
+			return data.IdleBasePosition > df.profile.HighestIdleBasePosition
 		} else {
 			// fault if < 50 when engine is cold
-			return data.IdleBasePosition < lowestIdleBasePosition
+			return data.IdleBasePosition < df.profile.LowestIdleBasePosition
 		}
 	}
 
@@ -69,7 +58,7 @@ func (df *DataframeAnalysis) isHotIdleFaulty(data MemsData) bool {
 	// fault if idle hot is outside the range of 10 - 50
 	if df.isEngineIdle(data) {
 		if df.isEngineWarm(data) {
-			return data.IdleHot < minimumIdleHot || data.IdleHot > maximumIdleHot
+			return data.IdleHot < df.profile.MinimumIdleHot || data.IdleHot > df.profile.MaximumIdleHot
 		}
 	}
 
@@ -82,17 +71,17 @@ func (df *DataframeAnalysis) isHotIdleFaulty(data MemsData) bool {
 // IAC position invalid if the idle offset exceeds the max error, yet the IAC Position remains at 0
 func (df *DataframeAnalysis) isIACFaulty(data MemsData) bool {
 	return df.isEngineIdle(data) &&
-		data.IdleSpeedOffset > maximumIdleOffset && data.IACPosition == invalidIACPosition
+		data.IdleSpeedOffset > df.profile.MaximumIdleOffset && data.IACPosition == invalidIACPosition
 }
 
 func (df *DataframeAnalysis) isVacuumFaulty(data MemsData) bool {
 	return df.isEngineIdle(data) &&
-		data.ManifoldAbsolutePressure > highestIdleMAPValue
+		data.ManifoldAbsolutePressure > df.profile.HighestIdleMAPValue
 }
 
 func (df *DataframeAnalysis) isLambdaOutOfRange(data MemsData) bool {
 	return df.isEngineRunning(data) &&
-		data.LambdaVoltage < lowestLambdaValue || data.LambdaVoltage > highestLambdaValue
+		data.LambdaVoltage < df.profile.LowestLambdaValue || data.LambdaVoltage > df.profile.HighestLambdaValue
 }
 
 // the jack count indicates the number of times the ECU has had to re-learn
@@ -101,7 +90,7 @@ func (df *DataframeAnalysis) isLambdaOutOfRange(data MemsData) bool {
 // then there may be a problem with the stepper motor, throttle cable adjustment or the throttle pot.
 // The count is increased for each journey with no closed throttle, indicating a throttle adjustment problem.
 func (df *DataframeAnalysis) isJackCountHigh(data MemsData) bool {
-	return data.JackCount >= highestJackCount
+	return data.JackCount >= df.profile.HighestJackCount
 }
 
 func (df *DataframeAnalysis) isCrankshaftSensorFaulty(data MemsData) bool {
@@ -114,8 +103,8 @@ func (df *DataframeAnalysis) isCrankshaftSensorFaulty(data MemsData) bool {
 func (df *DataframeAnalysis) isLambdaFaulty(data MemsData) bool {
 	if df.isEngineRunning(data) {
 		if !df.engineStartedAt.IsZero() {
-			currentTime, _ := time.Parse(timeFormat, data.Time)
-			startOscillationsAt := df.engineStartedAt.Add(time.Second * 90)
+			currentTime := df.getTimestamp(data)
+			startOscillationsAt := df.engineStartedAt.Add(time.Second * time.Duration(df.profile.LambdaOscillationDelay))
 			if currentTime.After(startOscillationsAt) {
 				return !df.isLambdaOscillating(data)
 			}
@@ -148,7 +137,7 @@ func (df *DataframeAnalysis) isLambdaOscillating(data MemsData) bool {
 			stddev = math.Sqrt(stddev / (count - 1))
 
 			// expect to see oscillations of at least +/-100mV (353mv - 535mv)
-			return stddev > lambdaOscillationStandardDeviation
+			return stddev > df.profile.LambdaOscillationStandardDeviation
 		}
 	}
 
@@ -157,10 +146,23 @@ func (df *DataframeAnalysis) isLambdaOscillating(data MemsData) bool {
 
 func (df *DataframeAnalysis) isThermostatFaulty(data MemsData) bool {
 	if df.isEngineRunning(data) {
-		currentTime, _ := time.Parse(timeFormat, data.Time)
+		model := df.GetWarmUpModel()
+
+		// the warm-up curve shows the thermostat stuck open or closed
+		if model.isThermostatStuck() {
+			return true
+		}
+
+		// the thermostat has opened in the expected range
+		if model.State == ThermostatOpen {
+			return false
+		}
+
+		// until the thermostat opens, compare to the linear estimate of the warm-up time
+		currentTime := df.getTimestamp(data)
 
 		return currentTime.After(df.expectedTimeEngineWarm) &&
-			data.CoolantTemp < lowestEngineWarmTemperature
+			data.CoolantTemp < df.profile.LowestEngineWarmTemperature
 	} else {
 		return false
 	}
@@ -182,9 +184,50 @@ func (df *DataframeAnalysis) isIdleSpeedFaulty(data MemsData) bool {
 
 			mean = sum / count
 
-			return mean > highestIdleSpeedDeviation
+			return mean > df.profile.HighestIdleSpeedDeviation
+		}
+	}
+
+	return false
+}
+
+// the idle error is the difference between the idle set point and the engine speed,
+// an idle error that remains high at idle indicates the ecu is unable to control the idle speed.
+// The idle error is high for a few seconds as the engine returns to idle, so the error must
+// remain above the maximum for every frame in the dataset
+// the ecu holds a fast idle above the idle set point whilst the engine warms up, so the idle error is only checked on a warm engine
+// need a full dataset for this analysis
+func (df *DataframeAnalysis) isIdleErrorFaulty(data MemsData) bool {
+	if df.isEngineIdle(data) && df.isEngineWarm(data) {
+		if len(df.dataset) == df.datasetLength {
+			for i := 0; i < df.datasetLength; i++ {
+				if df.dataset[i].ThrottleAngle > df.profile.DefaultIdleThrottleAngle ||
+					df.dataset[i].IdleSpeedDeviation < df.profile.MaximumIdleError {
+					return false
+				}
+			}
+
+			return true
 		}
 	}
 
 	return false
 }
+
+// on a warm engine at idle the stepper motor is expected to be within its working range,
+// a high position indicates the ecu is opening the stepper to compensate for a restricted air path
+// and a low position that it is closing the stepper to compensate for an air leak
+// an invalid position is reported as an idle air control fault
+func (df *DataframeAnalysis) isIACRangeFaulty(data MemsData) bool {
+	if df.isEngineIdle(data) && df.isEngineWarm(data) && data.IACPosition != invalidIACPosition {
+		return data.IACPosition < df.profile.LowestIACPosition ||
+			data.IACPosition > df.profile.HighestIACPosition
+	}
+
+	return false
+}
+
+// the ecu should be in closed loop when expected
+func (df *DataframeAnalysis) isClosedLoopFaulty(data MemsData) bool {
+	return df.isClosedLoopExpected(data) && !df.isLoopClosed(data)
+}
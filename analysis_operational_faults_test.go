@@ -18,8 +18,8 @@ func Test_analyseOperationalFaults(t *testing.T) {
 		LambdaStatus:             inactiveLambdaStatus,
 		CoolantTemp:              coldEngineTemperature,
 		IdleBasePosition:         goodIdleBasePosition,
-		LambdaVoltage:            highestLambdaValue + 1,
-		JackCount:                highestJackCount - 1,
+		LambdaVoltage:            KSeriesProfile.HighestLambdaValue + 1,
+		JackCount:                KSeriesProfile.HighestJackCount - 1,
 		CrankshaftPositionSensor: goodCASPosition,
 	}
 
@@ -41,10 +41,10 @@ func Test_analyseOperationalFaults(t *testing.T) {
 		CoilTime:                 highCoilTime,
 		CoolantTemp:              warmEngineTemperature,
 		IdleHot:                  lowIdleHot,
-		IdleSpeedOffset:          maximumIdleOffset + 1,
+		IdleSpeedOffset:          KSeriesProfile.MaximumIdleOffset + 1,
 		IACPosition:              invalidIACPosition,
 		LambdaVoltage:            goodLambdaValue,
-		JackCount:                highestJackCount,
+		JackCount:                KSeriesProfile.HighestJackCount,
 		CrankshaftPositionSensor: invalidCASPosition,
 	}
 
@@ -220,6 +220,28 @@ func Test_isEngineIdleFaulty(t *testing.T) {
 
 	result = d.isEngineIdleFaulty(data)
 	then.AssertThat(t, result, is.True())
+
+	// warm but below operating temp., the position has not fallen to the warm position
+	data = MemsData{
+		EngineRPM:        engineRunning,
+		CoolantTemp:      KSeriesProfile.LowestEngineWarmTemperature,
+		IdleBasePosition: KSeriesProfile.HighestIdleBasePosition + 5,
+	}
+
+	result = d.isEngineIdleFaulty(data)
+	then.AssertThat(t, result, is.False())
+}
+
+func Test_isEngineIdleFaulty_ReferenceRecording(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+
+	// the full warm-up recording ends at 78 - 79°C with the idle base position at 58 - 61 steps
+	for _, data := range getRecordedDataframes(t, "testdata/full-warmup-working-lambda.fcr") {
+		if data.DTC5 == expectedDTC5Value {
+			d.Analyse(data)
+			then.AssertThat(t, d.Analysis.IsEngineIdleFault, is.False().Reason(data.Time))
+		}
+	}
 }
 
 func Test_isHotIdleFaulty(t *testing.T) {
@@ -292,7 +314,7 @@ func Test_isLambdaOutofRange(t *testing.T) {
 
 	data := MemsData{
 		EngineRPM:     engineRunning,
-		LambdaVoltage: highestLambdaValue + 1,
+		LambdaVoltage: KSeriesProfile.HighestLambdaValue + 1,
 	}
 
 	result := d.isLambdaOutOfRange(data)
@@ -300,7 +322,7 @@ func Test_isLambdaOutofRange(t *testing.T) {
 
 	data = MemsData{
 		EngineRPM:     engineRunning,
-		LambdaVoltage: lowestLambdaValue - 1,
+		LambdaVoltage: KSeriesProfile.LowestLambdaValue - 1,
 	}
 
 	result = d.isLambdaOutOfRange(data)
@@ -319,14 +341,14 @@ func Test_isJackCountHigh(t *testing.T) {
 	d := NewDataframeAnalysis(1)
 
 	data := MemsData{
-		JackCount: highestJackCount - 1,
+		JackCount: KSeriesProfile.HighestJackCount - 1,
 	}
 
 	result := d.isJackCountHigh(data)
 	then.AssertThat(t, result, is.False())
 
 	data = MemsData{
-		JackCount: highestJackCount,
+		JackCount: KSeriesProfile.HighestJackCount,
 	}
 
 	result = d.isJackCountHigh(data)
@@ -363,7 +385,7 @@ func Test_isLambdaFaulty(t *testing.T) {
 	data := MemsData{
 		Time:             "12:00:00.000",
 		EngineRPM:        engineRunning,
-		LambdaVoltage:    lowestLambdaValue,
+		LambdaVoltage:    KSeriesProfile.LowestLambdaValue,
 		CoolantTemp:      warmEngineTemperature,
 		IntakeAirTemp:    goodIntakeTemperature,
 		IdleBasePosition: goodIdleBasePosition,
@@ -389,7 +411,7 @@ func Test_isLambdaFaulty(t *testing.T) {
 	data = MemsData{
 		Time:             "12:00:02.000",
 		EngineRPM:        engineRunning,
-		LambdaVoltage:    highestLambdaValue,
+		LambdaVoltage:    KSeriesProfile.HighestLambdaValue,
 		CoolantTemp:      warmEngineTemperature,
 		IntakeAirTemp:    goodIntakeTemperature,
 		IdleBasePosition: goodIdleBasePosition,
@@ -493,7 +515,7 @@ func Test_isLambdaFaulty(t *testing.T) {
 	data = MemsData{
 		Time:             "12:00:00.000",
 		EngineRPM:        engineRunning,
-		LambdaVoltage:    lowestLambdaValue,
+		LambdaVoltage:    KSeriesProfile.LowestLambdaValue,
 		CoolantTemp:      warmEngineTemperature,
 		IntakeAirTemp:    goodIntakeTemperature,
 		IdleBasePosition: goodIdleBasePosition,
@@ -519,7 +541,7 @@ func Test_isLambdaFaulty(t *testing.T) {
 	data = MemsData{
 		Time:             "12:01:31.000",
 		EngineRPM:        engineRunning,
-		LambdaVoltage:    highestLambdaValue,
+		LambdaVoltage:    KSeriesProfile.HighestLambdaValue,
 		CoolantTemp:      warmEngineTemperature,
 		IntakeAirTemp:    goodIntakeTemperature,
 		IdleBasePosition: goodIdleBasePosition,
@@ -537,7 +559,7 @@ func Test_isLambdaOscillating(t *testing.T) {
 
 	data := MemsData{
 		EngineRPM:     engineRunning,
-		LambdaVoltage: lowestLambdaValue,
+		LambdaVoltage: KSeriesProfile.LowestLambdaValue,
 	}
 
 	d.addToDataset(data)
@@ -551,7 +573,7 @@ func Test_isLambdaOscillating(t *testing.T) {
 
 	data = MemsData{
 		EngineRPM:     engineRunning,
-		LambdaVoltage: highestLambdaValue,
+		LambdaVoltage: KSeriesProfile.HighestLambdaValue,
 	}
 
 	d.addToDataset(data)
@@ -614,7 +636,7 @@ func Test_isThermostatFaulty(t *testing.T) {
 	data = MemsData{
 		Time:        "12:00:00.000",
 		EngineRPM:   engineRunning,
-		CoolantTemp: lowestEngineWarmTemperature - 1,
+		CoolantTemp: KSeriesProfile.LowestEngineWarmTemperature - 1,
 	}
 
 	d.addToDataset(data)
@@ -643,13 +665,28 @@ func Test_isThermostatFaulty(t *testing.T) {
 	data = MemsData{
 		Time:        "12:01:51.000",
 		EngineRPM:   engineRunning,
-		CoolantTemp: lowestEngineWarmTemperature - 1,
+		CoolantTemp: KSeriesProfile.LowestEngineWarmTemperature - 1,
 	}
 
 	result = d.isThermostatFaulty(data)
 	then.AssertThat(t, result, is.True())
 }
 
+func Test_isThermostatFaulty_EngineStarted(t *testing.T) {
+	d := NewDataframeAnalysis(2)
+
+	// the expected warm time is set before the thermostat is checked on the first running dataframe
+	d.Analyse(MemsData{
+		Timestamp:     time.Date(2022, 3, 4, 12, 0, 0, 0, time.UTC),
+		EngineRPM:     rpmIdle,
+		CoolantTemp:   coldEngineTemperature,
+		IntakeAirTemp: goodIntakeTemperature,
+		DTC5:          expectedDTC5Value,
+	})
+
+	then.AssertThat(t, d.Analysis.ThermostatFault, is.False())
+}
+
 func Test_isIdleSpeedFaulty(t *testing.T) {
 	d := NewDataframeAnalysis(1)
 
@@ -677,7 +714,134 @@ func convertDataframeStringToMemsData(dataframe7d string, dataframe80 string) Me
 	d7d := r.convertHexStringToByteArray(dataframe7d)
 	d80 := r.convertHexStringToByteArray(dataframe80)
 	ecu := NewECUReaderInstance()
-	df80, _ := ecu.createDataframe80(d80)
-	df7d, _ := ecu.createDataframe7D(d7d)
-	return ecu.createMemsDataframe(df80, df7d)
+	return ecu.createMemsDataframe(d80, d7d)
+}
+
+func Test_isIdleErrorFaulty(t *testing.T) {
+	d := NewDataframeAnalysis(3)
+
+	data := MemsData{
+		EngineRPM:          rpmIdle,
+		ThrottleAngle:      idleThrottleAngle,
+		CoolantTemp:        warmEngineTemperature,
+		IdleSpeedDeviation: KSeriesProfile.MaximumIdleError,
+	}
+
+	// the idle error is high as the engine returns to idle
+	d.addToDataset(MemsData{EngineRPM: rpmCruising, ThrottleAngle: activeThrottleAngle, IdleSpeedDeviation: 1000})
+	d.addToDataset(data)
+	d.addToDataset(data)
+	then.AssertThat(t, d.isIdleErrorFaulty(data), is.False())
+
+	// the idle error remains high at idle
+	d.addToDataset(data)
+	then.AssertThat(t, d.isIdleErrorFaulty(data), is.True())
+
+	// the ecu holds a fast idle whilst the engine warms up
+	data.CoolantTemp = coldEngineTemperature
+	then.AssertThat(t, d.isIdleErrorFaulty(data), is.False())
+
+	data.CoolantTemp = warmEngineTemperature
+	data.IdleSpeedDeviation = KSeriesProfile.MaximumIdleError - 1
+	d.addToDataset(data)
+	then.AssertThat(t, d.isIdleErrorFaulty(data), is.False())
+}
+
+func Test_isIdleErrorFaulty_ReferenceRecording(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+
+	// the nofaults recording idles above the idle set point as the engine warms from 56 to 74°C
+	for _, data := range getRecordedDataframes(t, "testdata/nofaults.fcr") {
+		if data.DTC5 == expectedDTC5Value {
+			d.Analyse(data)
+			then.AssertThat(t, d.Analysis.IdleErrorFault, is.False().Reason(data.Time))
+		}
+	}
+}
+
+func Test_isIACRangeFaulty(t *testing.T) {
+	d := NewDataframeAnalysis(1)
+
+	data := MemsData{
+		EngineRPM:     rpmIdle,
+		CoolantTemp:   warmEngineTemperature,
+		ThrottleAngle: idleThrottleAngle,
+		IACPosition:   KSeriesProfile.HighestIACPosition,
+	}
+
+	then.AssertThat(t, d.isIACRangeFaulty(data), is.False())
+
+	data.IACPosition = KSeriesProfile.HighestIACPosition + 1
+	then.AssertThat(t, d.isIACRangeFaulty(data), is.True())
+
+	data.IACPosition = KSeriesProfile.LowestIACPosition - 1
+	then.AssertThat(t, d.isIACRangeFaulty(data), is.True())
+
+	// an invalid position is an idle air control fault
+	data.IACPosition = invalidIACPosition
+	then.AssertThat(t, d.isIACRangeFaulty(data), is.False())
+
+	// the stepper is further open on a cold engine
+	data.IACPosition = KSeriesProfile.HighestIACPosition + 1
+	data.CoolantTemp = coldEngineTemperature
+	then.AssertThat(t, d.isIACRangeFaulty(data), is.False())
+}
+
+func Test_isIACRangeFaulty_ReferenceRecording(t *testing.T) {
+	d := NewDataframeAnalysis(1)
+	var warmIdleFrames int
+
+	// the full warm-up recording idles warm at the end with the stepper at around 50 - 60 steps
+	for _, data := range getRecordedDataframes(t, "testdata/full-warmup-working-lambda.fcr") {
+		if data.DTC5 == expectedDTC5Value && d.isEngineIdle(data) && d.isEngineWarm(data) {
+			warmIdleFrames++
+			then.AssertThat(t, d.isIACRangeFaulty(data), is.False().Reason(data.Time))
+		}
+	}
+
+	then.AssertThat(t, warmIdleFrames, is.GreaterThan(0))
+}
+
+func Test_isClosedLoopFaulty(t *testing.T) {
+	d := NewDataframeAnalysis(1)
+
+	data := MemsData{
+		Time:          "12:00:00.000",
+		EngineRPM:     rpmIdle,
+		CoolantTemp:   warmEngineTemperature,
+		ThrottleAngle: idleThrottleAngle,
+	}
+
+	d.isEngineRunning(data)
+
+	data.Time = "12:01:31.000"
+	then.AssertThat(t, d.isClosedLoopFaulty(data), is.True())
+
+	data.ClosedLoop = true
+	then.AssertThat(t, d.isClosedLoopFaulty(data), is.False())
+}
+
+func Test_analyseOperationalFaults_Scenario(t *testing.T) {
+	r := NewECUReaderInstance()
+	r.ecuReader = NewECUReader("testdata/full-warmup-working-lambda.fcr")
+	_, _ = r.connectToECU()
+
+	var closedLoopExpected, idleErrorFault, closedLoopFault, iacRangeFault, cruising bool
+
+	for i := 0; i < r.Responder.Playbook.Count; i++ {
+		if data, err := r.GetDataframes(); err == nil {
+			closedLoopExpected = closedLoopExpected || data.Analytics.IsClosedLoopExpected
+			idleErrorFault = idleErrorFault || data.Analytics.IdleErrorFault
+			closedLoopFault = closedLoopFault || data.Analytics.ClosedLoopFault
+			iacRangeFault = iacRangeFault || data.Analytics.IdleAirControlRangeFault
+			cruising = cruising || data.Analytics.IsCruising
+		}
+	}
+
+	// the engine warms up at idle in closed loop, revving the engine whilst cold is not cruising
+	then.AssertThat(t, closedLoopExpected, is.True())
+	then.AssertThat(t, closedLoopFault, is.False())
+	then.AssertThat(t, idleErrorFault, is.False())
+	then.AssertThat(t, iacRangeFault, is.False())
+	then.AssertThat(t, cruising, is.False())
 }
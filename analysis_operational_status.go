@@ -1,6 +1,6 @@
 package rosco
 
-import "time"
+import "math"
 
 func (df *DataframeAnalysis) analyseOperationalStatus(data MemsData) {
 	df.Analysis.IsEngineRunning = df.isEngineRunning(data)
@@ -9,6 +9,8 @@ func (df *DataframeAnalysis) analyseOperationalStatus(data MemsData) {
 	df.Analysis.IsEngineIdle = df.isEngineIdle(data)
 	df.Analysis.IsClosedLoop = df.isLoopClosed(data)
 	df.Analysis.IsThrottleActive = df.isThrottleActive(data)
+	df.Analysis.IsCruising = df.isCruising(data)
+	df.Analysis.IsClosedLoopExpected = df.isClosedLoopExpected(data)
 }
 
 func (df *DataframeAnalysis) isEngineRunning(data MemsData) bool {
@@ -16,18 +18,18 @@ func (df *DataframeAnalysis) isEngineRunning(data MemsData) bool {
 
 	// set the engine start time
 	if engineRunning && df.engineStartedAt.IsZero() {
-		df.engineStartedAt, _ = time.Parse(timeFormat, data.Time)
+		df.engineStartedAt = df.getTimestamp(data)
 	}
 
 	return engineRunning
 }
 
 func (df *DataframeAnalysis) isEngineWarming(data MemsData) bool {
-	return data.CoolantTemp < lowestEngineWarmTemperature
+	return data.CoolantTemp < df.profile.LowestEngineWarmTemperature
 }
 
 func (df *DataframeAnalysis) isEngineWarm(data MemsData) bool {
-	return data.CoolantTemp >= lowestEngineWarmTemperature
+	return data.CoolantTemp >= df.profile.LowestEngineWarmTemperature
 }
 
 func (df *DataframeAnalysis) isEngineIdle(data MemsData) bool {
@@ -35,7 +37,7 @@ func (df *DataframeAnalysis) isEngineIdle(data MemsData) bool {
 	// and the angle of the throttle pot indicates the throttle is off
 	// later MEMS ECUs use the throttle pot to determine the idle position
 	return df.isEngineRunning(data) &&
-		data.ThrottleAngle <= defaultIdleThrottleAngle
+		data.ThrottleAngle <= df.profile.DefaultIdleThrottleAngle
 }
 
 func (df *DataframeAnalysis) isLoopClosed(data MemsData) bool {
@@ -43,5 +45,47 @@ func (df *DataframeAnalysis) isLoopClosed(data MemsData) bool {
 }
 
 func (df *DataframeAnalysis) isThrottleActive(data MemsData) bool {
-	return data.ThrottleAngle > defaultIdleThrottleAngle || data.EngineRPM > highestIdleRPM
+	return data.ThrottleAngle > df.profile.DefaultIdleThrottleAngle || data.EngineRPM > df.profile.HighestIdleRPM
+}
+
+// the engine is cruising when warm with the throttle active and the RPM steady,
+// the RPM standard deviation over the dataset window is within 5% of the mean
+// need a full dataset for this analysis
+func (df *DataframeAnalysis) isCruising(data MemsData) bool {
+	var sum, mean, stddev, count float64
+
+	if df.isEngineRunning(data) && df.isEngineWarm(data) && df.isThrottleActive(data) {
+		if len(df.dataset) == df.datasetLength {
+			count = float64(df.datasetLength)
+
+			for i := 0; i < df.datasetLength; i++ {
+				sum += float64(df.dataset[i].EngineRPM)
+			}
+
+			mean = sum / count
+
+			for i := 0; i < df.datasetLength; i++ {
+				stddev += math.Pow(float64(df.dataset[i].EngineRPM)-mean, 2)
+			}
+
+			stddev = math.Sqrt(stddev / count)
+
+			return stddev <= mean*df.profile.CruisingRPMDeviation
+		}
+	}
+
+	return false
+}
+
+// the ecu is expected to be in closed loop once the engine is warm and the lambda sensor is oscillating,
+// whilst idling or cruising. Under acceleration the ecu enriches the mixture in open loop
+func (df *DataframeAnalysis) isClosedLoopExpected(data MemsData) bool {
+	if df.isEngineRunning(data) && df.isEngineWarm(data) {
+		if df.isEngineIdle(data) || df.isCruising(data) {
+			currentTime := df.getTimestamp(data)
+			return currentTime.After(df.getExpectedLambdaOscillationTime(MemsData{Timestamp: df.engineStartedAt}))
+		}
+	}
+
+	return false
 }
@@ -208,3 +208,71 @@ func Test_isThrottleActive(t *testing.T) {
 	result = d.isThrottleActive(data)
 	then.AssertThat(t, result, is.True())
 }
+
+func Test_isCruising(t *testing.T) {
+	d := NewDataframeAnalysis(5)
+
+	data := MemsData{
+		EngineRPM:     rpmCruising,
+		CoolantTemp:   warmEngineTemperature,
+		ThrottleAngle: activeThrottleAngle,
+	}
+
+	// need a full dataset
+	then.AssertThat(t, d.isCruising(data), is.False())
+
+	// steady rpm
+	for _, rpm := range []int{2480, 2500, 2520, 2510, 2490} {
+		data.EngineRPM = rpm
+		d.addToDataset(data)
+	}
+
+	then.AssertThat(t, d.isCruising(data), is.True())
+
+	// throttle released
+	data.ThrottleAngle = idleThrottleAngle
+	data.EngineRPM = rpmIdle
+	then.AssertThat(t, d.isCruising(data), is.False())
+
+	// accelerating
+	data.ThrottleAngle = activeThrottleAngle
+	for _, rpm := range []int{2500, 2800, 3100, 3400, 3700} {
+		data.EngineRPM = rpm
+		d.addToDataset(data)
+	}
+
+	then.AssertThat(t, d.isCruising(data), is.False())
+
+	// cold engine
+	d = NewDataframeAnalysis(1)
+	data = MemsData{EngineRPM: rpmCruising, CoolantTemp: coldEngineTemperature, ThrottleAngle: activeThrottleAngle}
+	d.addToDataset(data)
+	then.AssertThat(t, d.isCruising(data), is.False())
+}
+
+func Test_isClosedLoopExpected(t *testing.T) {
+	d := NewDataframeAnalysis(1)
+
+	data := MemsData{
+		Time:          "12:00:00.000",
+		EngineRPM:     rpmIdle,
+		CoolantTemp:   warmEngineTemperature,
+		ThrottleAngle: idleThrottleAngle,
+	}
+
+	// engine has just started
+	then.AssertThat(t, d.isClosedLoopExpected(data), is.False())
+
+	data.Time = "12:01:31.000"
+	then.AssertThat(t, d.isClosedLoopExpected(data), is.True())
+
+	// cold engine
+	data.CoolantTemp = coldEngineTemperature
+	then.AssertThat(t, d.isClosedLoopExpected(data), is.False())
+
+	// accelerating
+	data.CoolantTemp = warmEngineTemperature
+	data.ThrottleAngle = activeThrottleAngle
+	data.EngineRPM = rpmCruising
+	then.AssertThat(t, d.isClosedLoopExpected(data), is.False())
+}
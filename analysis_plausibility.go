@@ -0,0 +1,112 @@
+package rosco
+
+const (
+	// the coolant and intake air are at the same temperature when the engine is below this temperature
+	// and has not been started in the session
+	coldStartTemperature = 40
+	// minimum number of engine off dataframes before the sensors are checked
+	minimumKeyOnFrames = 5
+)
+
+// keyOnReadings are the range of the sensor readings since the engine stopped
+// cranking and pressing the throttle move the readings away from rest, so a sensor is implausible
+// if none of the readings are at rest
+type keyOnReadings struct {
+	frames                 int
+	lowestMAP              float32
+	highestMAP             float32
+	lowestBatteryVoltage   float32
+	highestBatteryVoltage  float32
+	lowestThrottlePotValue float32
+}
+
+// keyOnRules are the plausibility checks used by each new analysis
+var keyOnRules = NewRuleRegistry(getKeyOnRules()...)
+
+// getKeyOnRules returns the plausibility checks made with the ignition on and the engine off
+// with the engine off the sensors should read the ambient conditions, each rule reports its outcome in a field of the AnalysisReport
+func getKeyOnRules() []DiagnosticRule {
+	return []DiagnosticRule{
+		newReportRule("MAPPlausibilityFault", SeverityWarning, "engine off MAP is not close to the barometric pressure, check the MAP sensor, vacuum pipe and fuel trap",
+			func(report *AnalysisReport) *bool { return &report.MAPPlausibilityFault },
+			func(context RuleContext) bool { return context.analysis.isEngineOffMAPImplausible(context.Data) }),
+		newReportRule("TemperaturePlausibilityFault", SeverityWarning, "coolant and intake air temperatures disagree on a cold engine, check the coolant and air temperature sensors",
+			func(report *AnalysisReport) *bool { return &report.TemperaturePlausibilityFault },
+			func(context RuleContext) bool { return context.analysis.isColdTemperatureImplausible(context.Data) }),
+		newReportRule("BatteryPlausibilityFault", SeverityWarning, "battery voltage at rest is outside the expected range, check the battery condition and charge",
+			func(report *AnalysisReport) *bool { return &report.BatteryPlausibilityFault },
+			func(context RuleContext) bool { return context.analysis.isRestingVoltageImplausible(context.Data) }),
+		newReportRule("ThrottlePotPlausibilityFault", SeverityWarning, "throttle pot is not reading the closed position with the engine off, check the throttle pot and cable adjustment",
+			func(report *AnalysisReport) *bool { return &report.ThrottlePotPlausibilityFault },
+			func(context RuleContext) bool { return context.analysis.isClosedThrottleImplausible(context.Data) }),
+	}
+}
+
+// analysePlausibility checks the sensors read the ambient conditions whilst the ignition is on and the engine is off
+func (df *DataframeAnalysis) analysePlausibility(data MemsData) {
+	df.keyOn.add(data)
+
+	df.Analysis.Outcomes = df.evaluateRules(df.KeyOnRules, data)
+}
+
+// with the engine off the MAP sensor reads the barometric pressure
+func (df *DataframeAnalysis) isEngineOffMAPImplausible(data MemsData) bool {
+	return df.keyOn.frames >= minimumKeyOnFrames &&
+		(df.keyOn.highestMAP < df.profile.LowestEngineOffMAPValue || df.keyOn.lowestMAP > df.profile.HighestEngineOffMAPValue)
+}
+
+// on a cold engine that has stood, the coolant and intake air are both at the ambient temperature
+// only checked before the engine has been started as the intake air cools faster than the coolant
+func (df *DataframeAnalysis) isColdTemperatureImplausible(data MemsData) bool {
+	if df.engineStartedAt.IsZero() && data.CoolantTemp < coldStartTemperature {
+		return absInt(data.CoolantTemp-data.IntakeAirTemp) > df.profile.MaximumColdTemperatureVariance
+	}
+
+	return false
+}
+
+// a charged battery rests at 12.4 to 12.9V, the ignition loads reduce this slightly
+// a higher voltage with the engine off cannot be produced by the battery
+func (df *DataframeAnalysis) isRestingVoltageImplausible(data MemsData) bool {
+	return df.keyOn.frames >= minimumKeyOnFrames &&
+		(df.keyOn.highestBatteryVoltage < df.profile.LowestRestingBatteryVoltage || df.keyOn.lowestBatteryVoltage > df.profile.HighestRestingBatteryVoltage)
+}
+
+// the throttle pot reads below 1V with the throttle closed
+func (df *DataframeAnalysis) isClosedThrottleImplausible(data MemsData) bool {
+	return df.keyOn.frames >= minimumKeyOnFrames &&
+		df.keyOn.lowestThrottlePotValue > df.profile.HighestClosedThrottlePotVoltage
+}
+
+func (readings *keyOnReadings) add(data MemsData) {
+	if readings.frames == 0 {
+		*readings = keyOnReadings{
+			lowestMAP:              data.ManifoldAbsolutePressure,
+			highestMAP:             data.ManifoldAbsolutePressure,
+			lowestBatteryVoltage:   data.BatteryVoltage,
+			highestBatteryVoltage:  data.BatteryVoltage,
+			lowestThrottlePotValue: data.ThrottlePotSensor,
+		}
+	}
+
+	readings.lowestMAP = float32Min(readings.lowestMAP, data.ManifoldAbsolutePressure)
+	readings.highestMAP = float32Max(readings.highestMAP, data.ManifoldAbsolutePressure)
+	readings.lowestBatteryVoltage = float32Min(readings.lowestBatteryVoltage, data.BatteryVoltage)
+	readings.highestBatteryVoltage = float32Max(readings.highestBatteryVoltage, data.BatteryVoltage)
+	readings.lowestThrottlePotValue = float32Min(readings.lowestThrottlePotValue, data.ThrottlePotSensor)
+	readings.frames++
+}
+
+func float32Min(a float32, b float32) float32 {
+	if b < a {
+		return b
+	}
+	return a
+}
+
+func float32Max(a float32, b float32) float32 {
+	if b > a {
+		return b
+	}
+	return a
+}
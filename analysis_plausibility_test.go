@@ -0,0 +1,167 @@
+package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"testing"
+	"time"
+)
+
+const (
+	restingBattery       = 12.6
+	closedThrottlePot    = 0.6
+	ambientTemperature   = 18
+	barometricMAP        = 100
+	keyOnTestFrameCount  = minimumKeyOnFrames + 1
+	keyOnTestFrameLength = 500 * time.Millisecond
+)
+
+func getKeyOnFrame() MemsData {
+	return MemsData{
+		EngineRPM:                engineStopped,
+		CoolantTemp:              ambientTemperature + 2,
+		IntakeAirTemp:            ambientTemperature,
+		DTC5:                     expectedDTC5,
+		BatteryVoltage:           restingBattery,
+		ThrottlePotSensor:        closedThrottlePot,
+		ManifoldAbsolutePressure: barometricMAP,
+	}
+}
+
+// analyseKeyOnFrames analyses the frames each half a second apart
+func analyseKeyOnFrames(d *DataframeAnalysis, frames ...MemsData) {
+	start := time.Date(2022, 3, 4, 11, 0, 0, 0, time.UTC)
+
+	for i, data := range frames {
+		data.Timestamp = start.Add(time.Duration(i) * keyOnTestFrameLength)
+		d.Analyse(data)
+	}
+}
+
+func repeatFrame(data MemsData, count int) []MemsData {
+	var frames []MemsData
+
+	for i := 0; i < count; i++ {
+		frames = append(frames, data)
+	}
+
+	return frames
+}
+
+func Test_plausibility_Plausible(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+	analyseKeyOnFrames(d, repeatFrame(getKeyOnFrame(), keyOnTestFrameCount)...)
+
+	then.AssertThat(t, len(d.Analysis.Outcomes), is.EqualTo(len(getKeyOnRules())))
+	then.AssertThat(t, d.Analysis.MAPPlausibilityFault, is.False())
+	then.AssertThat(t, d.Analysis.TemperaturePlausibilityFault, is.False())
+	then.AssertThat(t, d.Analysis.BatteryPlausibilityFault, is.False())
+	then.AssertThat(t, d.Analysis.ThrottlePotPlausibilityFault, is.False())
+	then.AssertThat(t, len(d.GetFaultHistory()), is.EqualTo(0))
+}
+
+func Test_plausibility_isEngineOffMAPImplausible(t *testing.T) {
+	data := getKeyOnFrame()
+	data.ManifoldAbsolutePressure = 60
+
+	// not enough frames to check the sensor
+	d := NewDataframeAnalysis(20)
+	analyseKeyOnFrames(d, repeatFrame(data, minimumKeyOnFrames-1)...)
+	then.AssertThat(t, d.Analysis.MAPPlausibilityFault, is.False())
+
+	d = NewDataframeAnalysis(20)
+	analyseKeyOnFrames(d, repeatFrame(data, keyOnTestFrameCount)...)
+	then.AssertThat(t, d.Analysis.MAPPlausibilityFault, is.True())
+
+	entry, found := d.GetFaultHistoryEntry("MAPPlausibilityFault")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, entry.Occurrences, is.EqualTo(1))
+
+	// the MAP drops whilst cranking
+	frames := repeatFrame(getKeyOnFrame(), keyOnTestFrameCount)
+	frames[keyOnTestFrameCount-1].ManifoldAbsolutePressure = 85
+
+	d = NewDataframeAnalysis(20)
+	analyseKeyOnFrames(d, frames...)
+	then.AssertThat(t, d.Analysis.MAPPlausibilityFault, is.False())
+}
+
+func Test_plausibility_isColdTemperatureImplausible(t *testing.T) {
+	data := getKeyOnFrame()
+	data.IntakeAirTemp = data.CoolantTemp + KSeriesProfile.MaximumColdTemperatureVariance + 1
+
+	d := NewDataframeAnalysis(20)
+	analyseKeyOnFrames(d, data)
+	then.AssertThat(t, d.Analysis.TemperaturePlausibilityFault, is.True())
+
+	// a warm engine cools slower than the intake air
+	data.CoolantTemp = coldStartTemperature
+	d = NewDataframeAnalysis(20)
+	analyseKeyOnFrames(d, data)
+	then.AssertThat(t, d.Analysis.TemperaturePlausibilityFault, is.False())
+
+	// the engine has run in the session
+	data.CoolantTemp = ambientTemperature
+	d = NewDataframeAnalysis(20)
+	d.engineStartedAt = time.Date(2022, 3, 4, 10, 0, 0, 0, time.UTC)
+	analyseKeyOnFrames(d, data)
+	then.AssertThat(t, d.Analysis.TemperaturePlausibilityFault, is.False())
+}
+
+func Test_plausibility_isRestingVoltageImplausible(t *testing.T) {
+	data := getKeyOnFrame()
+	data.BatteryVoltage = 11
+
+	d := NewDataframeAnalysis(20)
+	analyseKeyOnFrames(d, repeatFrame(data, keyOnTestFrameCount)...)
+	then.AssertThat(t, d.Analysis.BatteryPlausibilityFault, is.True())
+
+	data.BatteryVoltage = 14
+	d = NewDataframeAnalysis(20)
+	analyseKeyOnFrames(d, repeatFrame(data, keyOnTestFrameCount)...)
+	then.AssertThat(t, d.Analysis.BatteryPlausibilityFault, is.True())
+}
+
+func Test_plausibility_isClosedThrottleImplausible(t *testing.T) {
+	data := getKeyOnFrame()
+	data.ThrottlePotSensor = 1.5
+
+	d := NewDataframeAnalysis(20)
+	analyseKeyOnFrames(d, repeatFrame(data, keyOnTestFrameCount)...)
+	then.AssertThat(t, d.Analysis.ThrottlePotPlausibilityFault, is.True())
+
+	// the throttle was pressed with the ignition on and released
+	frames := repeatFrame(data, keyOnTestFrameCount)
+	frames[0].ThrottlePotSensor = closedThrottlePot
+
+	d = NewDataframeAnalysis(20)
+	analyseKeyOnFrames(d, frames...)
+	then.AssertThat(t, d.Analysis.ThrottlePotPlausibilityFault, is.False())
+}
+
+func Test_plausibility_EngineRunning(t *testing.T) {
+	data := getKeyOnFrame()
+	data.ManifoldAbsolutePressure = 60
+
+	frames := repeatFrame(data, keyOnTestFrameCount)
+
+	running := getFaultHistoryFrame("", goodBattery, 0)
+	running.Time = ""
+	frames = append(frames, running)
+
+	d := NewDataframeAnalysis(20)
+	analyseKeyOnFrames(d, frames...)
+
+	// the key on checks are cleared when the engine starts, the report agrees with the findings and history
+	then.AssertThat(t, d.Analysis.IsEngineRunning, is.True())
+	then.AssertThat(t, d.Analysis.MAPPlausibilityFault, is.False())
+	then.AssertThat(t, d.keyOn.frames, is.EqualTo(0))
+
+	for _, finding := range d.Analysis.Findings {
+		then.AssertThat(t, finding.Name, is.Not(is.EqualTo("MAPPlausibilityFault")))
+	}
+
+	entry, found := d.GetFaultHistoryEntry("MAPPlausibilityFault")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, entry.Active, is.False())
+}
@@ -0,0 +1,191 @@
+package rosco
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// ThresholdProfile holds the diagnostic thresholds used by the analysis
+// engines are tuned differently, so the thresholds are selected for the engine being diagnosed
+type ThresholdProfile struct {
+	Name                               string  `json:"Name"`
+	Description                        string  `json:"Description"`
+	LowestBatteryVoltage               float32 `json:"LowestBatteryVoltage"`
+	HighestIdleMAPValue                float32 `json:"HighestIdleMAPValue"`
+	LowestEngineOffMAPValue            float32 `json:"LowestEngineOffMAPValue"`
+	HighestEngineOffMAPValue           float32 `json:"HighestEngineOffMAPValue"`
+	LowestRestingBatteryVoltage        float32 `json:"LowestRestingBatteryVoltage"`
+	HighestRestingBatteryVoltage       float32 `json:"HighestRestingBatteryVoltage"`
+	LowestCrankingVoltage              float32 `json:"LowestCrankingVoltage"`
+	HighestChargingVoltage             float32 `json:"HighestChargingVoltage"`
+	HighestLoadVoltageDrop             float32 `json:"HighestLoadVoltageDrop"`
+	MaximumColdTemperatureVariance     int     `json:"MaximumColdTemperatureVariance"`
+	HighestClosedThrottlePotVoltage    float32 `json:"HighestClosedThrottlePotVoltage"`
+	HighestIdleCoilTime                float32 `json:"HighestIdleCoilTime"`
+	HighestIdleRPM                     int     `json:"HighestIdleRPM"`
+	DefaultIdleThrottleAngle           int     `json:"DefaultIdleThrottleAngle"`
+	LowestEngineWarmTemperature        int     `json:"LowestEngineWarmTemperature"`
+	EngineOperatingTemp                int     `json:"EngineOperatingTemp"`
+	HighestThermostatOpeningTemp       int     `json:"HighestThermostatOpeningTemp"`
+	SecondsPerDegree                   int     `json:"SecondsPerDegree"`
+	MaximumIdleOffset                  int     `json:"MaximumIdleOffset"`
+	MaximumIdleError                   int     `json:"MaximumIdleError"`
+	LowestIACPosition                  int     `json:"LowestIACPosition"`
+	HighestIACPosition                 int     `json:"HighestIACPosition"`
+	CruisingRPMDeviation               float64 `json:"CruisingRPMDeviation"`
+	MinimumIdleHot                     int     `json:"MinimumIdleHot"`
+	MaximumIdleHot                     int     `json:"MaximumIdleHot"`
+	LowestIdleBasePosition             int     `json:"LowestIdleBasePosition"`
+	HighestIdleBasePosition            int     `json:"HighestIdleBasePosition"`
+	LowestLambdaValue                  int     `json:"LowestLambdaValue"`
+	HighestLambdaValue                 int     `json:"HighestLambdaValue"`
+	LambdaOscillationDelay             int     `json:"LambdaOscillationDelay"`
+	LambdaOscillationStandardDeviation float64 `json:"LambdaOscillationStandardDeviation"`
+	LowestLambdaSwitchingFrequency     float64 `json:"LowestLambdaSwitchingFrequency"`
+	HighestLambdaTransitionTime        int     `json:"HighestLambdaTransitionTime"`
+	LowestLambdaAverageVoltage         int     `json:"LowestLambdaAverageVoltage"`
+	HighestLambdaAverageVoltage        int     `json:"HighestLambdaAverageVoltage"`
+	HighestJackCount                   int     `json:"HighestJackCount"`
+	HighestIdleSpeedDeviation          float64 `json:"HighestIdleSpeedDeviation"`
+	HighestIdleHuntingAmplitude        float64 `json:"HighestIdleHuntingAmplitude"`
+	HighestRPMRoughness                float64 `json:"HighestRPMRoughness"`
+	// EngineDisplacement in litres is used to estimate the fuel flow
+	EngineDisplacement float64 `json:"EngineDisplacement"`
+}
+
+// KSeriesProfile thresholds for the Rover K-series engine, the default profile
+var KSeriesProfile = ThresholdProfile{
+	Name:                               "k-series",
+	Description:                        "Rover K-series MPi",
+	LowestBatteryVoltage:               13,
+	HighestIdleMAPValue:                45,
+	LowestEngineOffMAPValue:            90,
+	HighestEngineOffMAPValue:           106,
+	LowestRestingBatteryVoltage:        11.8,
+	HighestRestingBatteryVoltage:       13.2,
+	LowestCrankingVoltage:              9.6,
+	HighestChargingVoltage:             15,
+	HighestLoadVoltageDrop:             0.8,
+	MaximumColdTemperatureVariance:     15,
+	HighestClosedThrottlePotVoltage:    1.0,
+	HighestIdleCoilTime:                4,
+	HighestIdleRPM:                     1300,
+	DefaultIdleThrottleAngle:           14,
+	LowestEngineWarmTemperature:        78,
+	EngineOperatingTemp:                80,
+	HighestThermostatOpeningTemp:       100,
+	SecondsPerDegree:                   11,
+	MaximumIdleOffset:                  50,
+	MaximumIdleError:                   100,
+	LowestIACPosition:                  10,
+	HighestIACPosition:                 100,
+	CruisingRPMDeviation:               0.05,
+	MinimumIdleHot:                     10,
+	MaximumIdleHot:                     55,
+	LowestIdleBasePosition:             45,
+	HighestIdleBasePosition:            55,
+	LowestLambdaValue:                  10,
+	HighestLambdaValue:                 900,
+	LambdaOscillationDelay:             90,
+	LambdaOscillationStandardDeviation: 100,
+	LowestLambdaSwitchingFrequency:     0.2,
+	HighestLambdaTransitionTime:        1500,
+	LowestLambdaAverageVoltage:         350,
+	HighestLambdaAverageVoltage:        550,
+	HighestJackCount:                   50,
+	HighestIdleSpeedDeviation:          150,
+	HighestIdleHuntingAmplitude:        75,
+	HighestRPMRoughness:                40,
+	EngineDisplacement:                 1.8,
+}
+
+// MiniSPiProfile thresholds for the Mini single point injection engine
+var MiniSPiProfile = getMiniSPiProfile()
+
+// TurboProfile thresholds for turbocharged engines
+var TurboProfile = getTurboProfile()
+
+// the single point engine idles slower with a higher manifold pressure and takes longer to warm up
+func getMiniSPiProfile() ThresholdProfile {
+	profile := KSeriesProfile
+	profile.Name = "mini-spi"
+	profile.Description = "Rover Mini SPi"
+	profile.HighestIdleMAPValue = 50
+	profile.HighestIdleRPM = 1100
+	profile.SecondsPerDegree = 14
+	profile.LowestIdleBasePosition = 35
+	profile.HighestIdleBasePosition = 45
+	profile.LambdaOscillationDelay = 120
+	profile.EngineDisplacement = 1.3
+
+	return profile
+}
+
+// the manifold pressure and coil charge times are higher, the battery is loaded by the intercooler fan
+func getTurboProfile() ThresholdProfile {
+	profile := KSeriesProfile
+	profile.Name = "turbo"
+	profile.Description = "Rover turbocharged MPi"
+	profile.LowestBatteryVoltage = 12.8
+	profile.HighestIdleMAPValue = 55
+	profile.HighestIdleCoilTime = 4.5
+
+	return profile
+}
+
+// DefaultThresholdProfile is used when no profile is specified
+var DefaultThresholdProfile = KSeriesProfile
+
+// GetThresholdProfiles returns the built-in threshold profiles
+func GetThresholdProfiles() []ThresholdProfile {
+	return []ThresholdProfile{KSeriesProfile, MiniSPiProfile, TurboProfile}
+}
+
+// GetThresholdProfile returns the named built-in profile, k-series, mini-spi or turbo
+func GetThresholdProfile(name string) (ThresholdProfile, error) {
+	for _, profile := range GetThresholdProfiles() {
+		if strings.EqualFold(profile.Name, name) {
+			return profile, nil
+		}
+	}
+
+	return DefaultThresholdProfile, fmt.Errorf("threshold profile %s not supported", name)
+}
+
+// LoadThresholdProfile reads a threshold profile from a JSON file
+// thresholds missing from the file use the values from the default profile
+func LoadThresholdProfile(filename string) (ThresholdProfile, error) {
+	var err error
+	var data []byte
+
+	profile := DefaultThresholdProfile
+	profile.Name = ""
+	profile.Description = ""
+
+	if data, err = ioutil.ReadFile(filename); err != nil {
+		log.Errorf("error reading threshold profile %s (%s)", filename, err)
+		return DefaultThresholdProfile, err
+	}
+
+	if err = json.Unmarshal(data, &profile); err != nil {
+		log.Errorf("error parsing threshold profile %s (%s)", filename, err)
+		return DefaultThresholdProfile, err
+	}
+
+	if profile.Name == "" {
+		profile.Name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+
+	log.Infof("loaded threshold profile %s from %s", profile.Name, filename)
+
+	return profile, err
+}
+
+// isThresholdProfileFile returns true if the profile is a JSON file rather than a built-in profile name
+func isThresholdProfileFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".json")
+}
@@ -0,0 +1,77 @@
+package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"strings"
+	"testing"
+)
+
+func Test_profile_GetThresholdProfile(t *testing.T) {
+	profile, err := GetThresholdProfile("Mini-SPi")
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, profile.Name, is.EqualTo(MiniSPiProfile.Name))
+
+	profile, err = GetThresholdProfile("unknown")
+	then.AssertThat(t, err, is.Not(is.Nil()))
+	then.AssertThat(t, profile.Name, is.EqualTo(DefaultThresholdProfile.Name))
+}
+
+func Test_profile_LoadThresholdProfile(t *testing.T) {
+	profile, err := LoadThresholdProfile("testdata/profile.json")
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, profile.Name, is.EqualTo("MGF VVC"))
+	then.AssertThat(t, profile.HighestIdleRPM, is.EqualTo(1400))
+	then.AssertThat(t, profile.HighestIdleMAPValue, is.EqualTo(float32(50)))
+
+	// thresholds missing from the file use the default profile
+	then.AssertThat(t, profile.HighestJackCount, is.EqualTo(DefaultThresholdProfile.HighestJackCount))
+
+	_, err = LoadThresholdProfile("testdata/missing.json")
+	then.AssertThat(t, err, is.Not(is.Nil()))
+}
+
+func Test_profile_AnalysisUsesProfile(t *testing.T) {
+	data := MemsData{
+		EngineRPM:                rpmIdle,
+		ManifoldAbsolutePressure: 50,
+		BatteryVoltage:           goodBattery,
+	}
+
+	d := NewDataframeAnalysis(20)
+	then.AssertThat(t, d.Analysis.Profile, is.EqualTo(KSeriesProfile.Name))
+	then.AssertThat(t, d.isMAPHigh(data), is.True())
+
+	d = NewDataframeAnalysisWithProfile(20, TurboProfile)
+	then.AssertThat(t, d.Analysis.Profile, is.EqualTo(TurboProfile.Name))
+	then.AssertThat(t, d.isMAPHigh(data), is.False())
+}
+
+func Test_profile_SetThresholdProfile(t *testing.T) {
+	r := NewECUReaderInstance()
+
+	err := r.SetThresholdProfile("turbo")
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, r.Diagnostics.Analysis.Profile, is.EqualTo(TurboProfile.Name))
+
+	// the profile is kept when the diagnostics are reset
+	r.ResetDiagnostics()
+	then.AssertThat(t, r.Diagnostics.Analysis.Profile, is.EqualTo(TurboProfile.Name))
+
+	err = r.SetThresholdProfile("testdata/profile.json")
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, r.Diagnostics.Analysis.Profile, is.EqualTo("MGF VVC"))
+
+	err = r.SetThresholdProfile("unknown")
+	then.AssertThat(t, err, is.Not(is.Nil()))
+	then.AssertThat(t, r.Diagnostics.Analysis.Profile, is.EqualTo("MGF VVC"))
+}
+
+func Test_profile_RecordedInLog(t *testing.T) {
+	header := strings.Split(getMemsDataHeader(MetricUnits)+","+DiagnosticsCSVHeader, ",")
+	data := convertMemsDataToCSVData(MemsData{Analytics: AnalysisReport{Profile: "MGF VVC"}}, MetricUnits)
+
+	then.AssertThat(t, len(data), is.EqualTo(len(header)))
+	then.AssertThat(t, header[len(header)-1], is.EqualTo("profile"))
+	then.AssertThat(t, data[len(data)-1], is.EqualTo("MGF VVC"))
+}
@@ -0,0 +1,318 @@
+package rosco
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Severity of a diagnostic rule
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityFault   Severity = "fault"
+)
+
+// DiagnosticRule is a check evaluated by the analysis against every dataframe whilst the engine is running
+// rules can be added from outside the package with RegisterDiagnosticRule
+type DiagnosticRule interface {
+	// Name uniquely identifies the rule
+	Name() string
+	// Severity of the rule when triggered
+	Severity() Severity
+	// Evaluate returns true if the rule is triggered by the current dataframe and dataset window
+	Evaluate(context RuleContext) bool
+	// Explanation describes what the rule detects
+	Explanation() string
+}
+
+// RuleContext is the information available to a rule when it is evaluated
+// the dataset and status are shared with the analysis and must not be modified
+type RuleContext struct {
+	// Data is the current dataframe
+	Data MemsData
+	// Dataset is the window of recent dataframes received whilst the engine was running
+	Dataset []MemsData
+	// DatasetLength is the size of a full dataset window
+	DatasetLength int
+	// Profile is the threshold profile used by the analysis
+	Profile ThresholdProfile
+	// Status is the operational status of the engine for the current dataframe
+	Status                 AnalysisReport
+	EngineStartedAt        time.Time
+	ExpectedTimeEngineWarm time.Time
+	analysis               *DataframeAnalysis
+}
+
+// IsDatasetFull returns true if the dataset window is full
+func (context RuleContext) IsDatasetFull() bool {
+	return len(context.Dataset) >= context.DatasetLength
+}
+
+// RuleOutcome is the result of evaluating a rule
+type RuleOutcome struct {
+	Name        string
+	Severity    Severity
+	Triggered   bool
+	Explanation string
+}
+
+// NewDiagnosticRule creates a rule from the evaluation function
+func NewDiagnosticRule(name string, severity Severity, explanation string, evaluate func(context RuleContext) bool) DiagnosticRule {
+	return &diagnosticRule{name: name, severity: severity, explanation: explanation, evaluate: evaluate}
+}
+
+// newReportRule creates a built-in rule that reports its outcome in the field of the AnalysisReport
+func newReportRule(name string, severity Severity, explanation string, field func(report *AnalysisReport) *bool, evaluate func(context RuleContext) bool) DiagnosticRule {
+	return &diagnosticRule{name: name, severity: severity, explanation: explanation, field: field, evaluate: evaluate}
+}
+
+type diagnosticRule struct {
+	name        string
+	severity    Severity
+	explanation string
+	field       func(report *AnalysisReport) *bool
+	evaluate    func(context RuleContext) bool
+}
+
+func (rule *diagnosticRule) Name() string {
+	return rule.name
+}
+
+func (rule *diagnosticRule) Severity() Severity {
+	return rule.severity
+}
+
+func (rule *diagnosticRule) Evaluate(context RuleContext) bool {
+	return rule.evaluate(context)
+}
+
+func (rule *diagnosticRule) Explanation() string {
+	return rule.explanation
+}
+
+// RuleRegistry holds the rules evaluated by the analysis in the order they were registered
+type RuleRegistry struct {
+	mutex sync.RWMutex
+	rules []DiagnosticRule
+}
+
+// NewRuleRegistry creates a registry containing the rules
+func NewRuleRegistry(rules ...DiagnosticRule) *RuleRegistry {
+	registry := &RuleRegistry{}
+
+	for _, rule := range rules {
+		_ = registry.Register(rule)
+	}
+
+	return registry
+}
+
+// Register adds the rule to the registry, rule names must be unique
+func (registry *RuleRegistry) Register(rule DiagnosticRule) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	for _, r := range registry.rules {
+		if r.Name() == rule.Name() {
+			return fmt.Errorf("diagnostic rule %s is already registered", rule.Name())
+		}
+	}
+
+	registry.rules = append(registry.rules, rule)
+
+	return nil
+}
+
+// Unregister removes the named rule from the registry
+// returns false if the rule is not registered
+func (registry *RuleRegistry) Unregister(name string) bool {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	for i, r := range registry.rules {
+		if r.Name() == name {
+			registry.rules = append(registry.rules[:i:i], registry.rules[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get returns the named rule
+func (registry *RuleRegistry) Get(name string) (DiagnosticRule, bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	for _, r := range registry.rules {
+		if r.Name() == name {
+			return r, true
+		}
+	}
+
+	return nil, false
+}
+
+// Rules returns the registered rules
+func (registry *RuleRegistry) Rules() []DiagnosticRule {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	return append([]DiagnosticRule{}, registry.rules...)
+}
+
+func (registry *RuleRegistry) clone() *RuleRegistry {
+	return NewRuleRegistry(registry.Rules()...)
+}
+
+// diagnosticRules are the rules used by each new analysis
+var diagnosticRules = NewRuleRegistry(getBuiltInRules()...)
+
+// RegisterDiagnosticRule adds a rule to the rules used by each new analysis
+func RegisterDiagnosticRule(rule DiagnosticRule) error {
+	return diagnosticRules.Register(rule)
+}
+
+// UnregisterDiagnosticRule removes a rule from the rules used by each new analysis
+func UnregisterDiagnosticRule(name string) bool {
+	return diagnosticRules.Unregister(name)
+}
+
+// GetDiagnosticRules returns the rules used by each new analysis
+func GetDiagnosticRules() []DiagnosticRule {
+	return diagnosticRules.Rules()
+}
+
+// getBuiltInRules returns the operational fault checks, each rule reports its outcome in a field of the AnalysisReport
+func getBuiltInRules() []DiagnosticRule {
+	return []DiagnosticRule{
+		newReportRule("BatteryFault", SeverityWarning, "battery voltage is below the lowest expected charging voltage",
+			func(report *AnalysisReport) *bool { return &report.BatteryFault },
+			func(context RuleContext) bool { return context.analysis.isBatteryVoltageLow(context.Data) }),
+		newReportRule("CoilFault", SeverityFault, "coil charge time is too long with a good battery voltage",
+			func(report *AnalysisReport) *bool { return &report.CoilFault },
+			func(context RuleContext) bool { return context.analysis.isCoilFaulty(context.Data) }),
+		newReportRule("MapFault", SeverityFault, "manifold absolute pressure is too high at idle",
+			func(report *AnalysisReport) *bool { return &report.MapFault },
+			func(context RuleContext) bool { return context.analysis.isMAPHigh(context.Data) }),
+		newReportRule("O2SystemFault", SeverityWarning, "o2 system is not active",
+			func(report *AnalysisReport) *bool { return &report.O2SystemFault },
+			func(context RuleContext) bool { return !context.analysis.isO2SystemActive(context.Data) }),
+		newReportRule("IsEngineIdleFault", SeverityWarning, "idle base position is outside the expected range for the engine temperature",
+			func(report *AnalysisReport) *bool { return &report.IsEngineIdleFault },
+			func(context RuleContext) bool { return context.analysis.isEngineIdleFaulty(context.Data) }),
+		newReportRule("IdleHotFault", SeverityWarning, "idle hot is outside the expected range on a warm engine",
+			func(report *AnalysisReport) *bool { return &report.IdleHotFault },
+			func(context RuleContext) bool { return context.analysis.isHotIdleFaulty(context.Data) }),
+		newReportRule("IdleAirControlFault", SeverityFault, "idle air control valve is not responding to the idle speed offset",
+			func(report *AnalysisReport) *bool { return &report.IdleAirControlFault },
+			func(context RuleContext) bool { return context.analysis.isIACFaulty(context.Data) }),
+		newReportRule("VacuumFault", SeverityFault, "manifold vacuum is too low at idle, check for vacuum leaks",
+			func(report *AnalysisReport) *bool { return &report.VacuumFault },
+			func(context RuleContext) bool { return context.analysis.isVacuumFaulty(context.Data) }),
+		newReportRule("LambdaRangeFault", SeverityFault, "lambda voltage is outside the sensor range",
+			func(report *AnalysisReport) *bool { return &report.LambdaRangeFault },
+			func(context RuleContext) bool { return context.analysis.isLambdaOutOfRange(context.Data) }),
+		newReportRule("IdleAirControlJackFault", SeverityWarning, "jack count is high, check the stepper motor, throttle cable and throttle pot",
+			func(report *AnalysisReport) *bool { return &report.IdleAirControlJackFault },
+			func(context RuleContext) bool { return context.analysis.isJackCountHigh(context.Data) }),
+		newReportRule("CrankshaftSensorFault", SeverityFault, "crankshaft position sensor is not reading",
+			func(report *AnalysisReport) *bool { return &report.CrankshaftSensorFault },
+			func(context RuleContext) bool { return context.analysis.isCrankshaftSensorFaulty(context.Data) }),
+		newReportRule("ThermostatFault", SeverityWarning, "thermostat is stuck open or closed, or the engine has not reached operating temperature in the expected time",
+			func(report *AnalysisReport) *bool { return &report.ThermostatFault },
+			func(context RuleContext) bool { return context.analysis.isThermostatFaulty(context.Data) }),
+		newReportRule("IdleSpeedFault", SeverityWarning, "ecu is not in control of the idle speed",
+			func(report *AnalysisReport) *bool { return &report.IdleSpeedFault },
+			func(context RuleContext) bool { return context.analysis.isIdleSpeedFaulty(context.Data) }),
+		newReportRule("IdleErrorFault", SeverityWarning, "idle error is high, the ecu is unable to control the idle speed",
+			func(report *AnalysisReport) *bool { return &report.IdleErrorFault },
+			func(context RuleContext) bool { return context.analysis.isIdleErrorFaulty(context.Data) }),
+		newReportRule("IdleHuntingFault", SeverityWarning, "idle speed is hunting, check for air leaks, a sticking stepper motor or a dirty throttle body",
+			func(report *AnalysisReport) *bool { return &report.IdleHuntingFault },
+			func(context RuleContext) bool { return context.analysis.isIdleHunting(context.Data) }),
+		newReportRule("MisfireFault", SeverityWarning, "idle speed is erratic, a possible misfire, check the plugs, leads and coil",
+			func(report *AnalysisReport) *bool { return &report.MisfireFault },
+			func(context RuleContext) bool { return context.analysis.isMisfiring(context.Data) }),
+		newReportRule("IdleAirControlRangeFault", SeverityWarning, "idle air control position is outside the working range on a warm engine, check for air leaks or a restricted throttle body",
+			func(report *AnalysisReport) *bool { return &report.IdleAirControlRangeFault },
+			func(context RuleContext) bool { return context.analysis.isIACRangeFaulty(context.Data) }),
+		newReportRule("ClosedLoopFault", SeverityFault, "ecu is not in closed loop on a warm engine, check the lambda sensor",
+			func(report *AnalysisReport) *bool { return &report.ClosedLoopFault },
+			func(context RuleContext) bool { return context.analysis.isClosedLoopFaulty(context.Data) }),
+		newReportRule("LambdaOscillationFault", SeverityFault, "lambda voltage is not oscillating once the engine has warmed up",
+			func(report *AnalysisReport) *bool { return &report.LambdaOscillationFault },
+			func(context RuleContext) bool { return context.analysis.isLambdaFaulty(context.Data) }),
+		newReportRule("LambdaLazyFault", SeverityWarning, "lambda sensor is switching slowly in closed loop, the sensor may be worn or contaminated",
+			func(report *AnalysisReport) *bool { return &report.LambdaLazyFault },
+			func(context RuleContext) bool { return context.analysis.isLambdaLazy(context.Data) }),
+		newReportRule("LambdaMixtureFault", SeverityWarning, "average lambda voltage in closed loop shows the mixture is biased rich or lean, check for air leaks, fuel pressure and injectors",
+			func(report *AnalysisReport) *bool { return &report.LambdaMixtureFault },
+			func(context RuleContext) bool { return context.analysis.isLambdaMixtureBiased(context.Data) }),
+		newReportRule("StuckSensorFault", SeverityWarning, "a sensor reading has not changed whilst the engine speed varied, check the sensor and its wiring",
+			func(report *AnalysisReport) *bool { return &report.StuckSensorFault },
+			func(context RuleContext) bool { return context.analysis.isSensorStuck(context.Data) }),
+		newReportRule("NoisySensorFault", SeverityWarning, "a sensor reading is changing faster than the sensor can respond, check the connector and wiring for an intermittent connection",
+			func(report *AnalysisReport) *bool { return &report.NoisySensorFault },
+			func(context RuleContext) bool { return context.analysis.isSensorNoisy(context.Data) }),
+		newReportRule("CrankingVoltageFault", SeverityWarning, "battery voltage fell too low whilst cranking, check the battery condition, starter motor and connections",
+			func(report *AnalysisReport) *bool { return &report.CrankingVoltageFault },
+			func(context RuleContext) bool { return context.analysis.isCrankingVoltageLow(context.Data) }),
+		newReportRule("OverchargingFault", SeverityFault, "charging voltage is too high, check the alternator voltage regulator",
+			func(report *AnalysisReport) *bool { return &report.OverchargingFault },
+			func(context RuleContext) bool { return context.analysis.isOvercharging(context.Data) }),
+		newReportRule("ChargingLoadFault", SeverityWarning, "battery voltage drops under load from the fans or aircon, check the alternator output, drive belt and connections",
+			func(report *AnalysisReport) *bool { return &report.ChargingLoadFault },
+			func(context RuleContext) bool { return context.analysis.isVoltageDroppingUnderLoad(context.Data) }),
+	}
+}
+
+// evaluateRules evaluates the registered rules against the dataframe
+// the built-in rules set their field of the analysis report, the fields of rules that are not registered keep their last value
+func (df *DataframeAnalysis) evaluateRules(rules *RuleRegistry, data MemsData) []RuleOutcome {
+	var outcomes []RuleOutcome
+
+	context := df.getRuleContext(data)
+
+	for _, rule := range rules.Rules() {
+		triggered := rule.Evaluate(context)
+
+		outcomes = append(outcomes, RuleOutcome{
+			Name:        rule.Name(),
+			Severity:    rule.Severity(),
+			Triggered:   triggered,
+			Explanation: rule.Explanation(),
+		})
+
+		if r, ok := rule.(*diagnosticRule); ok && r.field != nil {
+			*r.field(&df.Analysis) = triggered
+		}
+	}
+
+	return outcomes
+}
+
+// clearRules clears the fields of the AnalysisReport set by the rules when they are no longer evaluated
+func (df *DataframeAnalysis) clearRules(rules *RuleRegistry) {
+	for _, rule := range rules.Rules() {
+		if r, ok := rule.(*diagnosticRule); ok && r.field != nil {
+			*r.field(&df.Analysis) = false
+		}
+	}
+}
+
+func (df *DataframeAnalysis) getRuleContext(data MemsData) RuleContext {
+	return RuleContext{
+		Data:                   data,
+		Dataset:                df.dataset,
+		DatasetLength:          df.datasetLength,
+		Profile:                df.profile,
+		Status:                 df.Analysis,
+		EngineStartedAt:        df.engineStartedAt,
+		ExpectedTimeEngineWarm: df.expectedTimeEngineWarm,
+		analysis:               df,
+	}
+}
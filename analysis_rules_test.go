@@ -0,0 +1,99 @@
+package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"reflect"
+	"testing"
+)
+
+func getRuleOutcome(outcomes []RuleOutcome, name string) (RuleOutcome, bool) {
+	for _, outcome := range outcomes {
+		if outcome.Name == name {
+			return outcome, true
+		}
+	}
+
+	return RuleOutcome{}, false
+}
+
+func Test_rules_BuiltInRulesSetReport(t *testing.T) {
+	d := NewDataframeAnalysis(1)
+
+	d.analyseOperationalFaults(MemsData{EngineRPM: engineRunning, BatteryVoltage: lowBattery})
+	then.AssertThat(t, len(d.Analysis.Outcomes), is.EqualTo(len(getBuiltInRules())))
+
+	outcome, found := getRuleOutcome(d.Analysis.Outcomes, "BatteryFault")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, outcome.Triggered, is.True())
+	then.AssertThat(t, outcome.Severity, is.EqualTo(SeverityWarning))
+	then.AssertThat(t, outcome.Explanation, is.Not(is.EqualTo("")))
+	then.AssertThat(t, d.Analysis.BatteryFault, is.True())
+}
+
+func Test_rules_BuiltInRulesReportField(t *testing.T) {
+	// each built-in rule reports its outcome in the report field of the same name
+	for _, rule := range append(getBuiltInRules(), getKeyOnRules()...) {
+		report := AnalysisReport{}
+		*rule.(*diagnosticRule).field(&report) = true
+
+		field := reflect.ValueOf(report).FieldByName(rule.Name())
+		then.AssertThat(t, field.IsValid(), is.True())
+		then.AssertThat(t, field.Bool(), is.True())
+	}
+}
+
+func Test_rules_RegisterCustomRule(t *testing.T) {
+	d := NewDataframeAnalysis(2)
+
+	// a rule using the dataset window
+	rule := NewDiagnosticRule("RisingCoolantTemp", SeverityInfo, "coolant temperature is rising", func(context RuleContext) bool {
+		return context.IsDatasetFull() && context.Dataset[len(context.Dataset)-1].CoolantTemp > context.Dataset[0].CoolantTemp
+	})
+
+	then.AssertThat(t, d.Rules.Register(rule), is.Nil())
+	then.AssertThat(t, d.Rules.Register(rule), is.Not(is.Nil()))
+
+	d.Analyse(MemsData{Time: "12:00:00.000", EngineRPM: rpmIdle, CoolantTemp: 40, DTC5: expectedDTC5Value})
+	outcome, _ := getRuleOutcome(d.Analysis.Outcomes, "RisingCoolantTemp")
+	then.AssertThat(t, outcome.Triggered, is.False())
+
+	d.Analyse(MemsData{Time: "12:00:01.000", EngineRPM: rpmIdle, CoolantTemp: 41, DTC5: expectedDTC5Value})
+	outcome, _ = getRuleOutcome(d.Analysis.Outcomes, "RisingCoolantTemp")
+	then.AssertThat(t, outcome.Triggered, is.True())
+
+	// triggered rules are recorded in the fault history
+	_, found := d.GetFaultHistoryEntry("RisingCoolantTemp")
+	then.AssertThat(t, found, is.True())
+
+	// other analyses are not affected
+	_, found = NewDataframeAnalysis(2).Rules.Get("RisingCoolantTemp")
+	then.AssertThat(t, found, is.False())
+}
+
+func Test_rules_UnregisterRule(t *testing.T) {
+	d := NewDataframeAnalysis(1)
+	then.AssertThat(t, d.Rules.Unregister("BatteryFault"), is.True())
+	then.AssertThat(t, d.Rules.Unregister("BatteryFault"), is.False())
+
+	d.analyseOperationalFaults(MemsData{EngineRPM: engineRunning, BatteryVoltage: lowBattery})
+	_, found := getRuleOutcome(d.Analysis.Outcomes, "BatteryFault")
+	then.AssertThat(t, found, is.False())
+	then.AssertThat(t, d.Analysis.BatteryFault, is.False())
+}
+
+func Test_rules_RegisterDiagnosticRule(t *testing.T) {
+	rule := NewDiagnosticRule("HighIdleRPM", SeverityWarning, "idle speed is high", func(context RuleContext) bool {
+		return context.Status.IsEngineIdle && context.Data.EngineRPM > context.Profile.HighestIdleRPM
+	})
+
+	then.AssertThat(t, RegisterDiagnosticRule(rule), is.Nil())
+	defer UnregisterDiagnosticRule("HighIdleRPM")
+
+	// new analyses use the registered rule
+	r := NewECUReaderInstance()
+	r.ResetDiagnostics()
+	_, found := r.Diagnostics.Rules.Get("HighIdleRPM")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, len(GetDiagnosticRules()), is.EqualTo(len(getBuiltInRules())+1))
+}
@@ -0,0 +1,177 @@
+package rosco
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// a sensor is flat lined if the reading has not changed for this time whilst the engine is running
+	flatLineDuration = 10 * time.Minute
+	// and the engine speed has varied by at least this amount, a working sensor responds to the change in load
+	minimumFlatLineRPMVariation = 500
+	// dataframes read closer together than this are not used to measure the rate of change
+	minimumRateInterval = 400 * time.Millisecond
+	// dataframes read further apart than this are not treated as continuous
+	maximumRateInterval = 2 * time.Second
+	// a sensor is noisy if it has this many implausible changes within the window,
+	// a single glitch that jumps away and back is two changes
+	noisySensorChanges = 3
+	noisySensorWindow  = 30 * time.Second
+)
+
+// sensorChannel is a MemsData channel monitored for flat lined and noisy readings
+type sensorChannel struct {
+	name string
+	// maximumRate is the highest plausible rate of change per second, 0 if the rate is not checked
+	maximumRate float64
+}
+
+// monitoredSensors are the channels checked whilst the engine is running
+var monitoredSensors = []sensorChannel{
+	// the coolant and intake air temperatures change slowly
+	{name: "CoolantTemp", maximumRate: 10},
+	{name: "IntakeAirTemp", maximumRate: 10},
+	// the MAP can swing across its full range when the throttle is snapped open but no faster
+	{name: "ManifoldAbsolutePressure", maximumRate: 200},
+	// the throttle opens as fast as the pedal is pressed
+	{name: "ThrottlePotSensor"},
+	// the lambda switches between rich and lean faster than the dataframes are read
+	{name: "LambdaVoltage"},
+}
+
+// SensorHealth is the state of a monitored sensor
+type SensorHealth struct {
+	// Channel is the name of the MemsData channel
+	Channel string `json:"Channel"`
+	// Value is the current reading
+	Value float64 `json:"Value"`
+	// UnchangedFor is the time the reading has not changed
+	UnchangedFor time.Duration `json:"UnchangedFor"`
+	// RPMVariation is the range of the engine speed whilst the reading has not changed
+	RPMVariation int `json:"RPMVariation"`
+	// RateOfChange of the reading per second
+	RateOfChange float64 `json:"RateOfChange"`
+	// ImplausibleChanges is the number of changes faster than the sensor can respond within the window
+	ImplausibleChanges int `json:"ImplausibleChanges"`
+	// IsFlatLined is true if the reading has not changed whilst the engine speed varied
+	IsFlatLined bool `json:"IsFlatLined"`
+	// IsNoisy is true if the reading is changing faster than is physically possible
+	IsNoisy bool `json:"IsNoisy"`
+}
+
+// sensorMonitor tracks the readings of a sensor whilst the engine is running
+type sensorMonitor struct {
+	channel            sensorChannel
+	metadata           ChannelMetadata
+	value              float64
+	changedAt          time.Time
+	lowestRPM          int
+	highestRPM         int
+	referenceValue     float64
+	referenceAt        time.Time
+	rate               float64
+	implausibleChanges []time.Time
+}
+
+func newSensorMonitors() []*sensorMonitor {
+	var monitors []*sensorMonitor
+
+	for _, channel := range monitoredSensors {
+		metadata, _ := GetChannel(channel.name)
+		monitors = append(monitors, &sensorMonitor{channel: channel, metadata: metadata})
+	}
+
+	return monitors
+}
+
+// monitorSensors updates the health of each sensor and reports the channels that are flat lined or noisy
+func (df *DataframeAnalysis) monitorSensors(data MemsData) {
+	df.Analysis.Sensors = nil
+	df.Analysis.StuckSensors = nil
+	df.Analysis.NoisySensors = nil
+
+	for _, monitor := range df.sensors {
+		health := monitor.add(data)
+		df.Analysis.Sensors = append(df.Analysis.Sensors, health)
+
+		if health.IsFlatLined {
+			df.Analysis.StuckSensors = append(df.Analysis.StuckSensors, health.Channel)
+		}
+
+		if health.IsNoisy {
+			df.Analysis.NoisySensors = append(df.Analysis.NoisySensors, health.Channel)
+		}
+	}
+}
+
+// a sensor that reads the same value whilst the engine speed varies is stuck or disconnected
+func (df *DataframeAnalysis) isSensorStuck(data MemsData) bool {
+	return len(df.Analysis.StuckSensors) > 0
+}
+
+// a sensor that jumps faster than it can respond has an intermittent connection or is failing
+func (df *DataframeAnalysis) isSensorNoisy(data MemsData) bool {
+	return len(df.Analysis.NoisySensors) > 0
+}
+
+func (monitor *sensorMonitor) add(data MemsData) SensorHealth {
+	value := monitor.metadata.GetValue(data)
+
+	if monitor.changedAt.IsZero() || value != monitor.value {
+		monitor.value = value
+		monitor.changedAt = data.Timestamp
+		monitor.lowestRPM = data.EngineRPM
+		monitor.highestRPM = data.EngineRPM
+	}
+
+	if data.EngineRPM < monitor.lowestRPM {
+		monitor.lowestRPM = data.EngineRPM
+	}
+
+	if data.EngineRPM > monitor.highestRPM {
+		monitor.highestRPM = data.EngineRPM
+	}
+
+	monitor.updateRate(value, data.Timestamp)
+
+	unchangedFor := data.Timestamp.Sub(monitor.changedAt)
+	variation := monitor.highestRPM - monitor.lowestRPM
+
+	return SensorHealth{
+		Channel:            monitor.channel.name,
+		Value:              roundTo(value, 2),
+		UnchangedFor:       unchangedFor,
+		RPMVariation:       variation,
+		RateOfChange:       roundTo(monitor.rate, 2),
+		ImplausibleChanges: len(monitor.implausibleChanges),
+		IsFlatLined:        unchangedFor >= flatLineDuration && variation >= minimumFlatLineRPMVariation,
+		IsNoisy:            len(monitor.implausibleChanges) >= noisySensorChanges,
+	}
+}
+
+// updateRate measures the rate of change from the reference reading and records implausible changes
+func (monitor *sensorMonitor) updateRate(value float64, timestamp time.Time) {
+	elapsed := timestamp.Sub(monitor.referenceAt)
+
+	if monitor.referenceAt.IsZero() || elapsed < 0 || elapsed > maximumRateInterval {
+		// not continuous with the reference reading
+		monitor.rate = 0
+		monitor.referenceValue = value
+		monitor.referenceAt = timestamp
+	} else if elapsed >= minimumRateInterval {
+		monitor.rate = (value - monitor.referenceValue) / elapsed.Seconds()
+
+		if monitor.channel.maximumRate > 0 && math.Abs(monitor.rate) > monitor.channel.maximumRate {
+			monitor.implausibleChanges = append(monitor.implausibleChanges, timestamp)
+		}
+
+		monitor.referenceValue = value
+		monitor.referenceAt = timestamp
+	}
+
+	// forget the changes outside the window
+	for len(monitor.implausibleChanges) > 0 && timestamp.Sub(monitor.implausibleChanges[0]) > noisySensorWindow {
+		monitor.implausibleChanges = monitor.implausibleChanges[1:]
+	}
+}
@@ -0,0 +1,167 @@
+package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"testing"
+	"time"
+)
+
+// varyingSensors varies the engine speed, throttle and lambda and slowly warms the engine,
+// so only the sensors changed by the update are flat lined or noisy
+func varyingSensors(update func(sample int, data *MemsData)) func(sample int, elapsed time.Duration, data *MemsData) {
+	return func(sample int, elapsed time.Duration, data *MemsData) {
+		data.EngineRPM = rpmIdle + (sample%10)*100
+		data.CoolantTemp = coldEngineTemperature + sample/60
+		data.IntakeAirTemp = goodIntakeTemperature + sample%2
+		data.ManifoldAbsolutePressure = float32(goodIdleMap + sample%5)
+		data.ThrottlePotSensor = float32(0.6 + float64(sample%10)*0.1)
+		data.LambdaVoltage = squareWave(150, 750, 1)(sample)
+
+		if update != nil {
+			update(sample, data)
+		}
+	}
+}
+
+func getSensorHealth(d *DataframeAnalysis, channel string) SensorHealth {
+	for _, health := range d.Analysis.Sensors {
+		if health.Channel == channel {
+			return health
+		}
+	}
+
+	return SensorHealth{}
+}
+
+func Test_sensorHealth_Healthy(t *testing.T) {
+	d := analyseTestFrames(NewDataframeAnalysis(20), getTestFrames(flatLineDuration+time.Minute, time.Second, varyingSensors(nil)))
+
+	then.AssertThat(t, len(d.Analysis.Sensors), is.EqualTo(len(monitoredSensors)))
+	then.AssertThat(t, len(d.Analysis.StuckSensors), is.EqualTo(0))
+	then.AssertThat(t, len(d.Analysis.NoisySensors), is.EqualTo(0))
+	then.AssertThat(t, d.Analysis.StuckSensorFault, is.False())
+	then.AssertThat(t, d.Analysis.NoisySensorFault, is.False())
+}
+
+func Test_sensorHealth_FlatLined(t *testing.T) {
+	stuckMAP := func(sample int, data *MemsData) {
+		data.ManifoldAbsolutePressure = goodIdleMap
+	}
+
+	d := analyseTestFrames(NewDataframeAnalysis(20), getTestFrames(flatLineDuration-time.Second, time.Second, varyingSensors(stuckMAP)))
+	then.AssertThat(t, d.Analysis.StuckSensorFault, is.False())
+
+	d = analyseTestFrames(NewDataframeAnalysis(20), getTestFrames(flatLineDuration, time.Second, varyingSensors(stuckMAP)))
+
+	health := getSensorHealth(d, "ManifoldAbsolutePressure")
+	then.AssertThat(t, health.UnchangedFor, is.EqualTo(flatLineDuration))
+	then.AssertThat(t, health.RPMVariation, is.EqualTo(900))
+	then.AssertThat(t, health.IsFlatLined, is.True())
+	then.AssertThat(t, d.Analysis.StuckSensors, is.EqualTo([]string{"ManifoldAbsolutePressure"}))
+	then.AssertThat(t, d.Analysis.StuckSensorFault, is.True())
+
+	entry, found := d.GetFaultHistoryEntry("StuckSensorFault")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, entry.Active, is.True())
+}
+
+func Test_sensorHealth_FlatLinedAtSteadySpeed(t *testing.T) {
+	// the throttle pot does not change at a steady idle
+	d := analyseTestFrames(NewDataframeAnalysis(20), getTestFrames(flatLineDuration+time.Minute, time.Second, varyingSensors(func(sample int, data *MemsData) {
+		data.EngineRPM = rpmIdle + (sample%2)*50
+		data.ThrottlePotSensor = 0.6
+	})))
+
+	health := getSensorHealth(d, "ThrottlePotSensor")
+	then.AssertThat(t, health.UnchangedFor, is.EqualTo(flatLineDuration+time.Minute))
+	then.AssertThat(t, health.IsFlatLined, is.False())
+	then.AssertThat(t, d.Analysis.StuckSensorFault, is.False())
+}
+
+func Test_sensorHealth_Noisy(t *testing.T) {
+	d := analyseTestFrames(NewDataframeAnalysis(20), getTestFrames(time.Minute, time.Second, varyingSensors(func(sample int, data *MemsData) {
+		if sample > 50 && sample%2 == 0 {
+			data.CoolantTemp += 30
+		}
+	})))
+
+	health := getSensorHealth(d, "CoolantTemp")
+	then.AssertThat(t, health.ImplausibleChanges, is.GreaterThanOrEqualTo(noisySensorChanges))
+	then.AssertThat(t, health.IsNoisy, is.True())
+	then.AssertThat(t, d.Analysis.NoisySensors, is.EqualTo([]string{"CoolantTemp"}))
+	then.AssertThat(t, d.Analysis.NoisySensorFault, is.True())
+}
+
+func Test_sensorHealth_Glitch(t *testing.T) {
+	// a single glitch jumps away and back
+	d := analyseTestFrames(NewDataframeAnalysis(20), getTestFrames(time.Minute, time.Second, varyingSensors(func(sample int, data *MemsData) {
+		if sample == 50 {
+			data.IntakeAirTemp += 30
+		}
+	})))
+
+	health := getSensorHealth(d, "IntakeAirTemp")
+	then.AssertThat(t, health.ImplausibleChanges, is.EqualTo(2))
+	then.AssertThat(t, health.IsNoisy, is.False())
+	then.AssertThat(t, d.Analysis.NoisySensorFault, is.False())
+}
+
+func Test_sensorHealth_NotContinuous(t *testing.T) {
+	// dataframes read too far apart are not used to measure the rate of change
+	frames := getTestFrames(time.Minute, time.Second, varyingSensors(func(sample int, data *MemsData) {
+		data.Timestamp = data.Timestamp.Add(time.Duration(sample) * 2 * time.Second)
+		data.CoolantTemp += (sample % 2) * 30
+	}))
+
+	d := analyseTestFrames(NewDataframeAnalysis(20), frames)
+	then.AssertThat(t, getSensorHealth(d, "CoolantTemp").RateOfChange, is.EqualTo(0.0))
+	then.AssertThat(t, d.Analysis.NoisySensorFault, is.False())
+}
+
+func Test_sensorHealth_EngineStopped(t *testing.T) {
+	frames := getTestFrames(flatLineDuration-time.Minute, time.Second, varyingSensors(func(sample int, data *MemsData) {
+		data.ManifoldAbsolutePressure = goodIdleMap
+	}))
+
+	// the engine stalls and is restarted
+	stalled := frames[len(frames)-1]
+	stalled.EngineRPM = engineStopped
+	stalled.Timestamp = stalled.Timestamp.Add(time.Second)
+	frames = append(frames, stalled)
+
+	restarted := getTestFrames(2*time.Minute, time.Second, varyingSensors(func(sample int, data *MemsData) {
+		data.Timestamp = stalled.Timestamp.Add(time.Duration(sample+1) * time.Second)
+		data.ManifoldAbsolutePressure = goodIdleMap
+	}))
+
+	d := analyseTestFrames(NewDataframeAnalysis(20), append(frames, restarted...))
+
+	then.AssertThat(t, getSensorHealth(d, "ManifoldAbsolutePressure").UnchangedFor, is.EqualTo(2*time.Minute))
+	then.AssertThat(t, d.Analysis.StuckSensorFault, is.False())
+}
+
+func Test_sensorHealth_Recordings(t *testing.T) {
+	// the sensors of both recordings are working
+	for filename, d := range analyseRecordings(t) {
+		then.AssertThat(t, len(d.Analysis.Sensors), is.GreaterThan(0).Reason(filename))
+
+		for _, health := range d.Analysis.Sensors {
+			then.AssertThat(t, health.IsFlatLined || health.IsNoisy, is.False().Reason(filename+" "+health.Channel))
+		}
+
+		_, stuck := d.GetFaultHistoryEntry("StuckSensorFault")
+		_, noisy := d.GetFaultHistoryEntry("NoisySensorFault")
+		then.AssertThat(t, stuck || noisy, is.False().Reason(filename))
+	}
+}
+
+func Test_sensorHealth_Scenario(t *testing.T) {
+	report, err := NewSessionAnalyser().AnalyseScenario("testdata/full-warmup-working-lambda.fcr")
+	then.AssertThat(t, err, is.Nil())
+
+	for _, fault := range report.Faults {
+		then.AssertThat(t, fault.Name, is.Not(is.EqualTo("StuckSensorFault")))
+		then.AssertThat(t, fault.Name, is.Not(is.EqualTo("NoisySensorFault")))
+	}
+}
@@ -0,0 +1,434 @@
+package rosco
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// maximum number of fault periods listed in the markdown report
+	markdownFaultPeriods = 5
+	// a fault must be active for this long in a period to count towards the verdict, shorter periods are
+	// transients such as throttle blips, misread dataframes and the battery recovering from the starter
+	persistentFaultTime = 15 * time.Second
+	// an intermittent fault counts towards the verdict once it has been active for this long over the session
+	intermittentFaultTime = 30 * time.Second
+)
+
+const (
+	// VerdictPass no persistent faults or warnings were found in the session
+	VerdictPass = "pass"
+	// VerdictAdvisory only persistent warnings were found in the session
+	VerdictAdvisory = "advisory"
+	// VerdictFail persistent faults were found in the session
+	VerdictFail = "fail"
+)
+
+// sessionReportChannels are the key channels summarised in the session report
+var sessionReportChannels = []string{
+	"EngineRPM",
+	"CoolantTemp",
+	"IntakeAirTemp",
+	"ManifoldAbsolutePressure",
+	"BatteryVoltage",
+	"ThrottleAngle",
+	"CoilTime",
+	"LambdaVoltage",
+	"IACPosition",
+	"IdleSpeedDeviation",
+	"FuelFlow",
+}
+
+// SessionReport summarises the analysis of a recorded session
+type SessionReport struct {
+	Scenario             string             `json:"Scenario"`
+	Profile              string             `json:"Profile"`
+	Units                string             `json:"Units"`
+	Frames               int                `json:"Frames"`
+	InvalidFrames        int                `json:"InvalidFrames"`
+	Start                time.Time          `json:"Start"`
+	End                  time.Time          `json:"End"`
+	Duration             time.Duration      `json:"Duration"`
+	ReachedOperatingTemp bool               `json:"ReachedOperatingTemp"`
+	WarmUpTime           time.Duration      `json:"WarmUpTime"`
+	RunningTime          time.Duration      `json:"RunningTime"`
+	IdleTime             time.Duration      `json:"IdleTime"`
+	CruisingTime         time.Duration      `json:"CruisingTime"`
+	WarmUp               WarmUpModel        `json:"WarmUp"`
+	Lambda               LambdaAnalysis     `json:"Lambda"`
+	Electrical           ElectricalAnalysis `json:"Electrical"`
+	Faults               []FaultTimeline    `json:"Faults"`
+	Channels             []ChannelSummary   `json:"Channels"`
+	Verdict              string             `json:"Verdict"`
+}
+
+// FaultTimeline records when a fault was active during the session
+type FaultTimeline struct {
+	Name        string        `json:"Name"`
+	Severity    Severity      `json:"Severity"`
+	Occurrences int           `json:"Occurrences"`
+	ActiveTime  time.Duration `json:"ActiveTime"`
+	Periods     []FaultPeriod `json:"Periods"`
+	// Remedy from the knowledge base
+	Remedy string `json:"Remedy"`
+}
+
+// FaultPeriod is a period of time in which the fault was active
+type FaultPeriod struct {
+	Start time.Time `json:"Start"`
+	End   time.Time `json:"End"`
+}
+
+// ChannelSummary is the minimum, maximum and mean value of a channel whilst the engine was running
+type ChannelSummary struct {
+	Name        string  `json:"Name"`
+	DisplayName string  `json:"DisplayName"`
+	Unit        string  `json:"Unit"`
+	Min         float64 `json:"Min"`
+	Max         float64 `json:"Max"`
+	Mean        float64 `json:"Mean"`
+	Samples     int     `json:"Samples"`
+}
+
+// SessionAnalyser runs the analysis over every frame of a recorded scenario
+type SessionAnalyser struct {
+	Profile       ThresholdProfile
+	Units         UnitSystem
+	DatasetLength int
+}
+
+// NewSessionAnalyser creates a session analyser using the default profile and metric units
+func NewSessionAnalyser() *SessionAnalyser {
+	return &SessionAnalyser{
+		Profile:       DefaultThresholdProfile,
+		Units:         MetricUnits,
+		DatasetLength: 20,
+	}
+}
+
+// sessionReportBuilder accumulates the session report as the frames are analysed
+type sessionReportBuilder struct {
+	report          SessionReport
+	diagnostics     *DataframeAnalysis
+	units           UnitSystem
+	previous        MemsData
+	engineStartedAt time.Time
+	faults          map[string]*FaultTimeline
+	channels        map[string]*ChannelSummary
+}
+
+// AnalyseScenario reads the .csv or .fcr scenario file and analyses every frame
+func (analyser *SessionAnalyser) AnalyseScenario(filename string) (SessionReport, error) {
+	var err error
+	var fileReader ResponderFileReader
+	var info ResponderFileInfo
+
+	if fileReader, err = NewResponderFileReader(filename); err != nil {
+		log.Errorf("error creating scenario reader (%s)", err)
+		return SessionReport{}, err
+	}
+
+	if info, err = fileReader.Load(); err != nil {
+		log.Errorf("error loading scenario %s (%s)", filename, err)
+		return SessionReport{}, err
+	}
+
+	report := analyser.AnalyseFrames(filepath.Base(filename), info.Description.Date, info.Data)
+	log.Infof("analysed scenario %s, %d frames, verdict %s", filename, report.Frames, report.Verdict)
+
+	return report, err
+}
+
+// AnalyseFrames analyses the raw dataframes of a recorded session
+// times recorded without a date are placed on the session date
+func (analyser *SessionAnalyser) AnalyseFrames(name string, date time.Time, frames []*RawData) SessionReport {
+	builder := &sessionReportBuilder{
+		diagnostics: NewDataframeAnalysisWithProfile(analyser.DatasetLength, analyser.Profile),
+		units:       analyser.Units,
+		faults:      make(map[string]*FaultTimeline),
+		channels:    make(map[string]*ChannelSummary),
+	}
+
+	builder.report.Scenario = name
+	builder.report.Profile = analyser.Profile.Name
+	builder.report.Units = analyser.Units.Name
+
+	var previous time.Time
+
+	for _, frame := range frames {
+		d80, _ := hex.DecodeString(frame.Dataframe80)
+		d7d, _ := hex.DecodeString(frame.Dataframe7d)
+
+		// recorded frames may be longer than the dataframe
+		d80 = truncateDataframe(d80, frame80Size+1)
+		d7d = truncateDataframe(d7d, frame7dSize+1)
+
+		if ValidateDataframe(frame80, d80) != nil || ValidateDataframe(frame7d, d7d) != nil {
+			builder.report.InvalidFrames++
+			continue
+		}
+
+		data := decodeMemsData(d80, d7d)
+		data.Time = frame.Time
+		data.Timestamp, _ = ConvertTimeFieldToDate(frame.Time)
+		data.Timestamp = getScenarioTimestamp(date, data.Timestamp, previous)
+		data.Dataframe80 = hex.EncodeToString(d80)
+		data.Dataframe7d = hex.EncodeToString(d7d)
+		previous = data.Timestamp
+
+		builder.addFrame(data)
+	}
+
+	return builder.build()
+}
+
+func (builder *sessionReportBuilder) addFrame(data MemsData) {
+	df := builder.diagnostics
+
+	data = df.DeriveMetrics(data)
+	data = df.StampFrame(data)
+	df.Analyse(data)
+	data.Analytics = df.Analysis
+
+	report := &builder.report
+
+	if report.Frames == 0 {
+		report.Start = data.Timestamp
+	} else {
+		// the time since the previous frame is spent in the state of the previous frame
+		builder.addStateTime(data.Timestamp.Sub(builder.previous.Timestamp))
+	}
+
+	report.Frames++
+	report.End = data.Timestamp
+
+	if data.Analytics.IsEngineRunning {
+		if builder.engineStartedAt.IsZero() {
+			builder.engineStartedAt = data.Timestamp
+		}
+
+		if data.Analytics.IsAtOperatingTemp && !report.ReachedOperatingTemp {
+			report.ReachedOperatingTemp = true
+			report.WarmUpTime = data.Timestamp.Sub(builder.engineStartedAt)
+		}
+
+		// dataframes rejected by the analysis are not summarised
+		if df.isValid(data) {
+			builder.addChannelValues(data)
+		}
+	}
+
+	builder.updateFaultTimelines(data)
+	builder.previous = data
+}
+
+func (builder *sessionReportBuilder) addStateTime(elapsed time.Duration) {
+	if elapsed < 0 {
+		return
+	}
+
+	status := builder.previous.Analytics
+
+	if status.IsEngineRunning {
+		builder.report.RunningTime += elapsed
+	}
+
+	if status.IsEngineIdle {
+		builder.report.IdleTime += elapsed
+	}
+
+	if status.IsCruising {
+		builder.report.CruisingTime += elapsed
+	}
+}
+
+func (builder *sessionReportBuilder) addChannelValues(data MemsData) {
+	for _, name := range sessionReportChannels {
+		value, ok := builder.units.GetChannelValue(data, name)
+		if !ok {
+			continue
+		}
+
+		summary, seen := builder.channels[name]
+		if !seen {
+			summary = &ChannelSummary{Name: name, DisplayName: value.DisplayName, Unit: value.Unit, Min: value.Value, Max: value.Value}
+			builder.channels[name] = summary
+		}
+
+		summary.Min = math.Min(summary.Min, value.Value)
+		summary.Max = math.Max(summary.Max, value.Value)
+		// the sum is held in the mean until the report is built
+		summary.Mean += value.Value
+		summary.Samples++
+	}
+}
+
+// updateFaultTimelines opens a period when a fault becomes active and closes it when the fault clears
+func (builder *sessionReportBuilder) updateFaultTimelines(data MemsData) {
+	for _, entry := range builder.diagnostics.GetFaultHistory() {
+		timeline, seen := builder.faults[entry.Name]
+		if !seen {
+			timeline = &FaultTimeline{Name: entry.Name, Severity: builder.getFaultSeverity(entry.Name)}
+			timeline.Remedy, _ = GetRemedy(entry.Name)
+			builder.faults[entry.Name] = timeline
+		}
+
+		last := len(timeline.Periods) - 1
+		open := last >= 0 && timeline.Periods[last].End.IsZero()
+
+		if entry.Active && !open {
+			timeline.Periods = append(timeline.Periods, FaultPeriod{Start: data.Timestamp})
+		}
+
+		if !entry.Active && open {
+			timeline.Periods[last].End = data.Timestamp
+		}
+
+		timeline.Occurrences = entry.Occurrences
+		timeline.ActiveTime = entry.ActiveTime
+	}
+}
+
+// getFaultSeverity returns the severity of the diagnostic rule, ecu fault codes are faults
+func (builder *sessionReportBuilder) getFaultSeverity(name string) Severity {
+	if rule, ok := builder.diagnostics.Rules.Get(name); ok {
+		return rule.Severity()
+	}
+
+	if rule, ok := builder.diagnostics.KeyOnRules.Get(name); ok {
+		return rule.Severity()
+	}
+
+	return SeverityFault
+}
+
+func (builder *sessionReportBuilder) build() SessionReport {
+	report := builder.report
+	report.Duration = report.End.Sub(report.Start)
+	report.WarmUp = builder.diagnostics.GetWarmUpModel()
+	report.Lambda = builder.diagnostics.Analysis.Lambda
+	report.Electrical = builder.diagnostics.electrical.analyse(builder.diagnostics.profile)
+	report.Verdict = VerdictPass
+
+	for _, entry := range builder.diagnostics.GetFaultHistory() {
+		timeline := builder.faults[entry.Name]
+
+		// close the periods of faults active at the end of the session
+		if last := len(timeline.Periods) - 1; last >= 0 && timeline.Periods[last].End.IsZero() {
+			timeline.Periods[last].End = report.End
+		}
+
+		report.Faults = append(report.Faults, *timeline)
+
+		if !timeline.isPersistent() && !timeline.isIntermittent() {
+			continue
+		}
+
+		if timeline.Severity == SeverityFault {
+			report.Verdict = VerdictFail
+		} else if timeline.Severity == SeverityWarning && report.Verdict == VerdictPass {
+			report.Verdict = VerdictAdvisory
+		}
+	}
+
+	for _, name := range sessionReportChannels {
+		if summary, ok := builder.channels[name]; ok {
+			summary.Mean = math.Round(summary.Mean/float64(summary.Samples)*100) / 100
+			report.Channels = append(report.Channels, *summary)
+		}
+	}
+
+	return report
+}
+
+// isPersistent returns true if the fault was active for the persistent fault time in any period
+func (timeline FaultTimeline) isPersistent() bool {
+	for _, period := range timeline.Periods {
+		if period.End.Sub(period.Start) >= persistentFaultTime {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isIntermittent returns true if the fault came and went, and was active for the intermittent fault time over the session
+func (timeline FaultTimeline) isIntermittent() bool {
+	return timeline.ActiveTime >= intermittentFaultTime
+}
+
+// ToJSON returns the session report as JSON
+func (report SessionReport) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(report, "", " ")
+}
+
+// ToMarkdown returns the session report as a Markdown document
+func (report SessionReport) ToMarkdown() string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("# Session Report %s\n\n", report.Scenario))
+	sb.WriteString(fmt.Sprintf("**Verdict:** %s\n\n", report.Verdict))
+
+	sb.WriteString("| Summary | |\n|--------|--------|\n")
+	sb.WriteString(fmt.Sprintf("| Start | %s |\n", report.Start.Format(memsDataTimeFormat)))
+	sb.WriteString(fmt.Sprintf("| Duration | %s |\n", humanizeDuration(report.Duration)))
+	sb.WriteString(fmt.Sprintf("| Frames | %d (%d invalid) |\n", report.Frames, report.InvalidFrames))
+	sb.WriteString(fmt.Sprintf("| Threshold Profile | %s |\n", report.Profile))
+	if report.ReachedOperatingTemp {
+		sb.WriteString(fmt.Sprintf("| Warm Up Time | %s |\n", humanizeDuration(report.WarmUpTime)))
+	} else {
+		sb.WriteString("| Warm Up Time | operating temperature not reached |\n")
+	}
+	sb.WriteString(fmt.Sprintf("| Engine Running | %s |\n", humanizeDuration(report.RunningTime)))
+	sb.WriteString(fmt.Sprintf("| Idle | %s |\n", humanizeDuration(report.IdleTime)))
+	sb.WriteString(fmt.Sprintf("| Cruising | %s |\n", humanizeDuration(report.CruisingTime)))
+
+	sb.WriteString("\n## Warm Up\n\n")
+	sb.WriteString(report.WarmUp.toMarkdown())
+
+	sb.WriteString("\n## Lambda\n\n")
+	sb.WriteString(report.Lambda.toMarkdown())
+
+	sb.WriteString("\n## Electrical\n\n")
+	sb.WriteString(report.Electrical.toMarkdown())
+
+	sb.WriteString("\n## Faults\n\n")
+	if len(report.Faults) == 0 {
+		sb.WriteString("No faults found.\n")
+	} else {
+		sb.WriteString("| Fault | Severity | Occurrences | Active Time | Periods |\n|--------|--------|--------|--------|--------|\n")
+		for _, fault := range report.Faults {
+			var periods []string
+			for i, period := range fault.Periods {
+				if i == markdownFaultPeriods {
+					periods = append(periods, fmt.Sprintf("and %d more", len(fault.Periods)-markdownFaultPeriods))
+					break
+				}
+				periods = append(periods, fmt.Sprintf("%s - %s", period.Start.Format(timeFormat), period.End.Format(timeFormat)))
+			}
+
+			sb.WriteString(fmt.Sprintf("| %s | %s | %d | %s | %s |\n", fault.Name, fault.Severity, fault.Occurrences, humanizeDuration(fault.ActiveTime), strings.Join(periods, ", ")))
+		}
+
+		sb.WriteString("\n## Remedies\n\n")
+		for _, fault := range report.Faults {
+			if fault.Remedy != "" {
+				sb.WriteString(fmt.Sprintf("- **%s** %s\n", fault.Name, fault.Remedy))
+			}
+		}
+	}
+
+	sb.WriteString("\n## Channels (engine running)\n\n")
+	sb.WriteString("| Channel | Min | Max | Mean | Unit |\n|--------|--------|--------|--------|--------|\n")
+	for _, channel := range report.Channels {
+		sb.WriteString(fmt.Sprintf("| %s | %.2f | %.2f | %.2f | %s |\n", channel.DisplayName, channel.Min, channel.Max, channel.Mean, channel.Unit))
+	}
+
+	return sb.String()
+}
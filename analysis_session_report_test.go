@@ -0,0 +1,161 @@
+package rosco
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"testing"
+	"time"
+)
+
+func Test_sessionReport_AnalyseScenario(t *testing.T) {
+	report, err := NewSessionAnalyser().AnalyseScenario("testdata/full-warmup-working-lambda.fcr")
+	then.AssertThat(t, err, is.Nil())
+
+	then.AssertThat(t, report.Scenario, is.EqualTo("full-warmup-working-lambda.fcr"))
+	then.AssertThat(t, report.Profile, is.EqualTo(DefaultThresholdProfile.Name))
+	then.AssertThat(t, report.Frames+report.InvalidFrames, is.EqualTo(730))
+	then.AssertThat(t, report.Start, is.EqualTo(time.Date(2022, 3, 4, 11, 39, 23, 357000000, time.UTC)))
+	then.AssertThat(t, report.Duration, is.EqualTo(report.End.Sub(report.Start)))
+
+	// the engine is started after a few seconds and idles whilst warming up
+	then.AssertThat(t, report.RunningTime.Seconds(), is.LessThan(report.Duration.Seconds()))
+	then.AssertThat(t, report.IdleTime.Seconds(), is.LessThan(report.RunningTime.Seconds()))
+	then.AssertThat(t, report.IdleTime.Seconds(), is.GreaterThan(report.RunningTime.Seconds()/2))
+	then.AssertThat(t, report.CruisingTime, is.EqualTo(time.Duration(0)))
+	then.AssertThat(t, report.ReachedOperatingTemp, is.True())
+	then.AssertThat(t, report.WarmUpTime.Minutes(), is.GreaterThan(float64(5)))
+
+	// the lambda is measured over the last minute of the session
+	then.AssertThat(t, report.Lambda.Duration.Seconds(), is.GreaterThan(float64(0)))
+	then.AssertThat(t, report.Lambda.Duration.Seconds(), is.LessThanOrEqualTo(lambdaAnalysisWindow.Seconds()))
+
+	// the crankshaft position sensor occasionally reads 0 during the warm up, the misread
+	// dataframes are listed in the timeline but do not fail the verdict
+	var crankshaft FaultTimeline
+	for _, fault := range report.Faults {
+		if fault.Name == "CrankshaftSensorFault" {
+			crankshaft = fault
+		}
+	}
+
+	then.AssertThat(t, crankshaft.Severity, is.EqualTo(SeverityFault))
+	then.AssertThat(t, len(crankshaft.Periods), is.EqualTo(crankshaft.Occurrences))
+	then.AssertThat(t, crankshaft.isPersistent(), is.False())
+	then.AssertThat(t, report.Verdict, is.EqualTo(VerdictPass))
+
+	rpm := report.Channels[0]
+	then.AssertThat(t, rpm.Name, is.EqualTo("EngineRPM"))
+	then.AssertThat(t, rpm.Min, is.GreaterThan(float64(0)))
+	then.AssertThat(t, rpm.Max, is.GreaterThan(float64(2000)))
+	then.AssertThat(t, rpm.Mean, is.GreaterThan(rpm.Min))
+	then.AssertThat(t, rpm.Mean, is.LessThan(rpm.Max))
+}
+
+func Test_sessionReport_Units(t *testing.T) {
+	analyser := NewSessionAnalyser()
+	analyser.Units = ImperialUnits
+
+	report, err := analyser.AnalyseScenario("testdata/nofaults.csv")
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, report.Channels[1].Name, is.EqualTo("CoolantTemp"))
+	then.AssertThat(t, report.Channels[1].Unit, is.EqualTo(UnitFahrenheit))
+}
+
+func Test_sessionReport_Verdict(t *testing.T) {
+	frames := []*RawData{
+		{Time: "12:00:00.000", Dataframe80: hex.EncodeToString(createResponseMap()["80"]), Dataframe7d: hex.EncodeToString(createResponseMap()["7D"])},
+	}
+
+	// the engine is not running in the default dataframes
+	report := NewSessionAnalyser().AnalyseFrames("default", time.Date(2022, 3, 4, 0, 0, 0, 0, time.UTC), frames)
+	then.AssertThat(t, report.Frames, is.EqualTo(1))
+	then.AssertThat(t, report.Start, is.EqualTo(time.Date(2022, 3, 4, 12, 0, 0, 0, time.UTC)))
+	then.AssertThat(t, report.RunningTime, is.EqualTo(time.Duration(0)))
+
+	report = NewSessionAnalyser().AnalyseFrames("invalid", time.Time{}, []*RawData{{Time: "12:00:00.000", Dataframe80: "80", Dataframe7d: "7D"}})
+	then.AssertThat(t, report.Frames, is.EqualTo(0))
+	then.AssertThat(t, report.InvalidFrames, is.EqualTo(1))
+	then.AssertThat(t, report.Verdict, is.EqualTo(VerdictPass))
+}
+
+func Test_sessionReport_HealthyRecordings(t *testing.T) {
+	for _, filename := range []string{"testdata/nofaults.fcr", "testdata/full-warmup-working-lambda.fcr"} {
+		report, err := NewSessionAnalyser().AnalyseScenario(filename)
+		then.AssertThat(t, err, is.Nil())
+
+		for _, fault := range report.Faults {
+			then.AssertThat(t, fault.isPersistent(), is.False().Reason(filename+" "+fault.Name))
+		}
+
+		then.AssertThat(t, report.Verdict, is.EqualTo(VerdictPass).Reason(filename))
+	}
+}
+
+func Test_sessionReport_PersistentFault(t *testing.T) {
+	start := time.Date(2022, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	timeline := FaultTimeline{Name: "MapFault", Severity: SeverityFault, Periods: []FaultPeriod{
+		{Start: start, End: start.Add(2 * time.Second)},
+		{Start: start.Add(10 * time.Second), End: start.Add(20 * time.Second)},
+	}}
+	then.AssertThat(t, timeline.isPersistent(), is.False())
+
+	timeline.Periods = append(timeline.Periods, FaultPeriod{Start: start.Add(30 * time.Second), End: start.Add(30 * time.Second).Add(persistentFaultTime)})
+	then.AssertThat(t, timeline.isPersistent(), is.True())
+}
+
+func Test_sessionReport_IntermittentFault(t *testing.T) {
+	reader, err := NewResponderFileReader("testdata/full-warmup-working-lambda.fcr")
+	then.AssertThat(t, err, is.Nil())
+	info, err := reader.Load()
+	then.AssertThat(t, err, is.Nil())
+
+	// the battery voltage drops for 2 seconds in every 6 seconds whilst the engine warms up
+	for i, frame := range info.Data[:600] {
+		if i%12 < 4 {
+			d80, _ := hex.DecodeString(frame.Dataframe80)
+			d7d, _ := hex.DecodeString(frame.Dataframe7d)
+
+			data := decodeMemsData(truncateDataframe(d80, frame80Size+1), truncateDataframe(d7d, frame7dSize+1))
+			data.BatteryVoltage = lowBattery
+
+			d80, d7d = EncodeDataframes(data)
+			frame.Dataframe80 = hex.EncodeToString(d80)
+			frame.Dataframe7d = hex.EncodeToString(d7d)
+		}
+	}
+
+	report := NewSessionAnalyser().AnalyseFrames("intermittent", info.Description.Date, info.Data)
+
+	var battery FaultTimeline
+	for _, fault := range report.Faults {
+		if fault.Name == "BatteryFault" {
+			battery = fault
+		}
+	}
+
+	then.AssertThat(t, battery.Occurrences, is.GreaterThan(40))
+	then.AssertThat(t, battery.isPersistent(), is.False())
+	then.AssertThat(t, battery.isIntermittent(), is.True())
+	then.AssertThat(t, report.Verdict, is.EqualTo(VerdictAdvisory))
+}
+
+func Test_sessionReport_Export(t *testing.T) {
+	report, _ := NewSessionAnalyser().AnalyseScenario("testdata/full-warmup-working-lambda.fcr")
+
+	data, err := report.ToJSON()
+	then.AssertThat(t, err, is.Nil())
+
+	var decoded SessionReport
+	then.AssertThat(t, json.Unmarshal(data, &decoded), is.Nil())
+	then.AssertThat(t, decoded.Verdict, is.EqualTo(report.Verdict))
+	then.AssertThat(t, len(decoded.Faults), is.EqualTo(len(report.Faults)))
+
+	markdown := report.ToMarkdown()
+	then.AssertThat(t, markdown, is.ValueContaining("# Session Report full-warmup-working-lambda.fcr"))
+	then.AssertThat(t, markdown, is.ValueContaining("**Verdict:** pass"))
+	then.AssertThat(t, markdown, is.ValueContaining("| CrankshaftSensorFault | fault |"))
+	then.AssertThat(t, markdown, is.ValueContaining("| Engine Speed |"))
+}
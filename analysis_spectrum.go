@@ -0,0 +1,202 @@
+package rosco
+
+import (
+	"gonum.org/v1/gonum/dsp/fourier"
+	"gonum.org/v1/gonum/stat"
+	"math"
+	"time"
+)
+
+const (
+	// minimum number of samples for a spectrum
+	minimumSpectrumSamples = 8
+	// idle hunting is a slow oscillation of the idle speed within this band in Hz
+	lowestIdleHuntingFrequency  = 0.1
+	highestIdleHuntingFrequency = 1.0
+	// a periodic oscillation must repeat within the dataset, a single cycle is a transient such as the throttle closing
+	minimumHuntingCycles = 2
+	// signal power above this proportion of the nyquist frequency is high frequency
+	highFrequencyBand = 0.5
+	// proportion of the signal power at high frequency that indicates erratic running
+	highestHighFrequencyRatio = 0.6
+)
+
+// SignalSpectrum is the frequency analysis of a signal over the dataset window
+// the samples are resampled to a uniform rate, detrended and windowed before the FFT
+type SignalSpectrum struct {
+	// Samples is the number of samples in the window
+	Samples int `json:"Samples"`
+	// SampleRate of the resampled signal in Hz, the highest frequency detected is half the sample rate
+	SampleRate float64 `json:"SampleRate"`
+	// DominantFrequency is the frequency in Hz of the largest periodic component
+	DominantFrequency float64 `json:"DominantFrequency"`
+	// DominantAmplitude is the amplitude of the largest periodic component in the units of the signal
+	DominantAmplitude float64 `json:"DominantAmplitude"`
+	// Roughness is the RMS of the detrended signal in the units of the signal
+	Roughness float64 `json:"Roughness"`
+	// HighFrequencyRatio is the proportion of the signal power above half the nyquist frequency
+	HighFrequencyRatio float64 `json:"HighFrequencyRatio"`
+}
+
+// getSpectrum returns the spectrum of the signal sampled at the times
+func getSpectrum(times []time.Time, values []float64) SignalSpectrum {
+	spectrum := SignalSpectrum{Samples: len(values)}
+
+	if len(values) < minimumSpectrumSamples {
+		return spectrum
+	}
+
+	duration := times[len(times)-1].Sub(times[0]).Seconds()
+	if duration <= 0 {
+		return spectrum
+	}
+
+	n := len(values)
+	interval := duration / float64(n-1)
+	spectrum.SampleRate = roundTo(1/interval, 3)
+
+	signal := detrend(resample(times, values, interval))
+
+	var power float64
+	for _, v := range signal {
+		power += v * v
+	}
+	spectrum.Roughness = roundTo(math.Sqrt(power/float64(n)), 2)
+
+	// a hann window reduces the leakage between frequencies, the coherent gain of the window is 0.5
+	for i := range signal {
+		signal[i] *= 0.5 * (1 - math.Cos(2*math.Pi*float64(i)/float64(n-1)))
+	}
+
+	fft := fourier.NewFFT(n)
+	coefficients := fft.Coefficients(nil, signal)
+
+	var total, high float64
+
+	// ignore the dc component
+	for i := 1; i < len(coefficients); i++ {
+		magnitude := math.Hypot(real(coefficients[i]), imag(coefficients[i]))
+		frequency := fft.Freq(i) * spectrum.SampleRate
+		amplitude := 2 * magnitude / (float64(n) * 0.5)
+
+		total += magnitude * magnitude
+		if frequency > highFrequencyBand*spectrum.SampleRate/2 {
+			high += magnitude * magnitude
+		}
+
+		if amplitude > spectrum.DominantAmplitude {
+			spectrum.DominantAmplitude = amplitude
+			spectrum.DominantFrequency = frequency
+		}
+	}
+
+	spectrum.DominantAmplitude = roundTo(spectrum.DominantAmplitude, 2)
+	spectrum.DominantFrequency = roundTo(spectrum.DominantFrequency, 3)
+
+	if total > 0 {
+		spectrum.HighFrequencyRatio = roundTo(high/total, 3)
+	}
+
+	return spectrum
+}
+
+// resample interpolates the values onto a uniform interval in seconds, the dataframes are not read at a fixed rate
+func resample(times []time.Time, values []float64, interval float64) []float64 {
+	resampled := make([]float64, len(values))
+	j := 0
+
+	for i := range resampled {
+		t := float64(i) * interval
+
+		for j < len(times)-2 && times[j+1].Sub(times[0]).Seconds() < t {
+			j++
+		}
+
+		t0 := times[j].Sub(times[0]).Seconds()
+		t1 := times[j+1].Sub(times[0]).Seconds()
+
+		if t1 <= t0 {
+			resampled[i] = values[j+1]
+		} else {
+			resampled[i] = values[j] + (values[j+1]-values[j])*math.Min(1, math.Max(0, (t-t0)/(t1-t0)))
+		}
+	}
+
+	return resampled
+}
+
+// detrend removes the linear trend of the signal, leaving the oscillation about the trend
+func detrend(values []float64) []float64 {
+	x := make([]float64, len(values))
+	for i := range x {
+		x[i] = float64(i)
+	}
+
+	alpha, beta := stat.LinearRegression(x, values, nil, false)
+
+	detrended := make([]float64, len(values))
+	for i, v := range values {
+		detrended[i] = v - (alpha + beta*x[i])
+	}
+
+	return detrended
+}
+
+// analyseSpectrum calculates the spectrum of the engine speed and lambda voltage over the dataset
+func (df *DataframeAnalysis) analyseSpectrum() {
+	var times []time.Time
+	var rpm, lambda []float64
+
+	for _, data := range df.dataset {
+		times = append(times, data.Timestamp)
+		rpm = append(rpm, float64(data.EngineRPM))
+		lambda = append(lambda, float64(data.LambdaVoltage))
+	}
+
+	df.Analysis.RPMSpectrum = getSpectrum(times, rpm)
+	df.Analysis.LambdaSpectrum = getSpectrum(times, lambda)
+}
+
+// idle hunting is a periodic rise and fall of the idle speed, the ecu is over correcting the idle
+// check for air leaks, a sticking stepper motor or a dirty throttle body
+// need a full dataset for this analysis
+func (df *DataframeAnalysis) isIdleHunting(data MemsData) bool {
+	spectrum := df.Analysis.RPMSpectrum
+
+	if df.isEngineIdle(data) && len(df.dataset) == df.datasetLength {
+		for _, d := range df.dataset {
+			if !df.isEngineIdle(d) {
+				return false
+			}
+		}
+
+		cycles := spectrum.DominantFrequency * float64(spectrum.Samples-1) / spectrum.SampleRate
+
+		return cycles >= minimumHuntingCycles &&
+			spectrum.DominantFrequency >= lowestIdleHuntingFrequency &&
+			spectrum.DominantFrequency <= highestIdleHuntingFrequency &&
+			spectrum.DominantAmplitude > df.profile.HighestIdleHuntingAmplitude
+	}
+
+	return false
+}
+
+// the dataframes are read too slowly to see individual misfires, but a misfire shows as rough
+// running with the engine speed changing erratically from one dataframe to the next
+// need a full dataset for this analysis
+func (df *DataframeAnalysis) isMisfiring(data MemsData) bool {
+	spectrum := df.Analysis.RPMSpectrum
+
+	if df.isEngineIdle(data) && len(df.dataset) == df.datasetLength {
+		for _, d := range df.dataset {
+			if !df.isEngineIdle(d) {
+				return false
+			}
+		}
+
+		return spectrum.HighFrequencyRatio > highestHighFrequencyRatio &&
+			spectrum.Roughness > df.profile.HighestRPMRoughness
+	}
+
+	return false
+}
@@ -0,0 +1,128 @@
+package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"math"
+	"testing"
+	"time"
+)
+
+const (
+	// spectrum dataframes are read around every half second
+	spectrumFrameInterval = 500 * time.Millisecond
+	// the spectrum is analysed over 20 dataframes
+	spectrumTestDuration = 19 * spectrumFrameInterval
+)
+
+// spectrumSignals sets the engine speed and lambda voltage from functions of the seconds since the first dataframe
+func spectrumSignals(rpm func(seconds float64) float64, lambda func(seconds float64) float64) func(sample int, elapsed time.Duration, data *MemsData) {
+	return func(sample int, elapsed time.Duration, data *MemsData) {
+		// the dataframes are not read at a fixed rate
+		elapsed += time.Duration(sample%3) * 20 * time.Millisecond
+
+		data.Timestamp = testFramesStart.Add(elapsed)
+		data.EngineRPM = int(math.Round(rpm(elapsed.Seconds())))
+		data.LambdaVoltage = int(math.Round(lambda(elapsed.Seconds())))
+	}
+}
+
+func sine(mean float64, amplitude float64, frequency float64) func(seconds float64) float64 {
+	return func(seconds float64) float64 {
+		return mean + amplitude*math.Sin(2*math.Pi*frequency*seconds)
+	}
+}
+
+func Test_spectrum_getSpectrum(t *testing.T) {
+	frames := getTestFrames(spectrumTestDuration, spectrumFrameInterval, spectrumSignals(sine(1000, 150, 0.3), sine(450, 300, 0.7)))
+
+	var times []time.Time
+	var rpm, lambda []float64
+
+	for _, data := range frames {
+		times = append(times, data.Timestamp)
+		rpm = append(rpm, float64(data.EngineRPM))
+		lambda = append(lambda, float64(data.LambdaVoltage))
+	}
+
+	spectrum := getSpectrum(times, rpm)
+	then.AssertThat(t, spectrum.Samples, is.EqualTo(20))
+	then.AssertThat(t, math.Abs(spectrum.SampleRate-2), is.LessThan(0.1))
+	then.AssertThat(t, math.Abs(spectrum.DominantFrequency-0.3), is.LessThan(0.1))
+	then.AssertThat(t, math.Abs(spectrum.DominantAmplitude-150), is.LessThan(30.0))
+
+	spectrum = getSpectrum(times, lambda)
+	then.AssertThat(t, math.Abs(spectrum.DominantFrequency-0.7), is.LessThan(0.1))
+	then.AssertThat(t, spectrum.HighFrequencyRatio, is.GreaterThan(0.5))
+
+	// not enough samples
+	spectrum = getSpectrum(times[:4], rpm[:4])
+	then.AssertThat(t, spectrum.Samples, is.EqualTo(4))
+	then.AssertThat(t, spectrum.DominantAmplitude, is.EqualTo(0.0))
+}
+
+func Test_spectrum_detrend(t *testing.T) {
+	detrended := detrend([]float64{10, 12, 14, 16, 18})
+
+	for _, v := range detrended {
+		then.AssertThat(t, math.Abs(v), is.LessThan(1e-9))
+	}
+}
+
+func Test_spectrum_SteadyIdle(t *testing.T) {
+	// a warming engine with the idle speed falling steadily
+	d := analyseTestFrames(NewDataframeAnalysis(20), getTestFrames(spectrumTestDuration, spectrumFrameInterval, spectrumSignals(func(seconds float64) float64 {
+		return 1100 - seconds*5 + 5*math.Sin(seconds*7)
+	}, sine(450, 300, 0.7))))
+
+	then.AssertThat(t, d.Analysis.RPMSpectrum.DominantAmplitude, is.LessThan(float64(KSeriesProfile.HighestIdleHuntingAmplitude)))
+	then.AssertThat(t, d.Analysis.IdleHuntingFault, is.False())
+	then.AssertThat(t, d.Analysis.MisfireFault, is.False())
+}
+
+func Test_spectrum_IdleHunting(t *testing.T) {
+	d := analyseTestFrames(NewDataframeAnalysis(20), getTestFrames(spectrumTestDuration, spectrumFrameInterval, spectrumSignals(sine(1000, 150, 0.3), sine(450, 300, 0.7))))
+
+	then.AssertThat(t, d.Analysis.IdleHuntingFault, is.True())
+	then.AssertThat(t, d.Analysis.MisfireFault, is.False())
+
+	// the throttle was opened during the dataset
+	frames := getTestFrames(spectrumTestDuration, spectrumFrameInterval, spectrumSignals(sine(1000, 150, 0.3), sine(450, 300, 0.7)))
+	frames[5].ThrottleAngle = activeThrottleAngle + 20
+
+	d = analyseTestFrames(NewDataframeAnalysis(20), frames)
+	then.AssertThat(t, d.Analysis.IdleHuntingFault, is.False())
+}
+
+func Test_spectrum_Misfire(t *testing.T) {
+	// the engine speed drops erratically from one dataframe to the next
+	drops := []float64{0, 120, 10, 90, 0, 140, 20, 0, 110, 30}
+	d := analyseTestFrames(NewDataframeAnalysis(20), getTestFrames(spectrumTestDuration, spectrumFrameInterval, spectrumSignals(func(seconds float64) float64 {
+		return 1000 - drops[int(math.Round(seconds*2))%len(drops)]
+	}, sine(450, 300, 0.7))))
+
+	then.AssertThat(t, d.Analysis.RPMSpectrum.HighFrequencyRatio, is.GreaterThan(highestHighFrequencyRatio))
+	then.AssertThat(t, d.Analysis.MisfireFault, is.True())
+}
+
+func Test_spectrum_Recordings(t *testing.T) {
+	// neither recording misfires or hunts persistently at idle
+	for filename, d := range analyseRecordings(t) {
+		_, misfire := d.GetFaultHistoryEntry("MisfireFault")
+		then.AssertThat(t, misfire, is.False().Reason(filename))
+
+		if hunting, found := d.GetFaultHistoryEntry("IdleHuntingFault"); found {
+			then.AssertThat(t, hunting.ActiveTime.Seconds(), is.LessThan(persistentFaultTime.Seconds()).Reason(filename))
+		}
+	}
+}
+
+func Test_spectrum_Scenario(t *testing.T) {
+	report, err := NewSessionAnalyser().AnalyseScenario("testdata/full-warmup-working-lambda.fcr")
+	then.AssertThat(t, err, is.Nil())
+
+	for _, fault := range report.Faults {
+		then.AssertThat(t, fault.Name, is.Not(is.EqualTo("IdleHuntingFault")))
+		then.AssertThat(t, fault.Name, is.Not(is.EqualTo("MisfireFault")))
+	}
+}
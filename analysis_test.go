@@ -1,6 +1,7 @@
 package rosco
 
 import (
+	"encoding/hex"
 	"github.com/corbym/gocrest/is"
 	"github.com/corbym/gocrest/then"
 	"testing"
@@ -40,6 +41,79 @@ const (
 	goodCASPosition           = 15
 )
 
+// testFramesStart is the time of the first generated dataframe
+var testFramesStart = time.Date(2022, 3, 4, 11, 0, 0, 0, time.UTC)
+
+// getTestFrames returns valid dataframes of a warm engine at idle, one every interval for the duration
+// the update sets the signals of each dataframe from the sample number and the time since the first dataframe
+func getTestFrames(duration time.Duration, interval time.Duration, update func(sample int, elapsed time.Duration, data *MemsData)) []MemsData {
+	var frames []MemsData
+
+	for i := 0; time.Duration(i)*interval <= duration; i++ {
+		elapsed := time.Duration(i) * interval
+
+		data := MemsData{
+			Timestamp:     testFramesStart.Add(elapsed),
+			EngineRPM:     rpmIdle,
+			CoolantTemp:   warmEngineTemperature,
+			IntakeAirTemp: goodIntakeTemperature,
+			ThrottleAngle: idleThrottleAngle,
+			DTC5:          expectedDTC5,
+		}
+
+		if update != nil {
+			update(i, elapsed, &data)
+		}
+
+		frames = append(frames, data)
+	}
+
+	return frames
+}
+
+// analyseTestFrames analyses the dataframes in order
+func analyseTestFrames(d *DataframeAnalysis, frames []MemsData) *DataframeAnalysis {
+	for _, data := range frames {
+		d.Analyse(data)
+	}
+
+	return d
+}
+
+// analyseRecordings analyses each of the recorded scenarios
+func analyseRecordings(t *testing.T) map[string]*DataframeAnalysis {
+	analysed := make(map[string]*DataframeAnalysis)
+
+	for _, filename := range []string{"testdata/nofaults.fcr", "testdata/full-warmup-working-lambda.fcr"} {
+		analysed[filename] = analyseTestFrames(NewDataframeAnalysis(20), getRecordedDataframes(t, filename))
+	}
+
+	return analysed
+}
+
+// getRecordedDataframes decodes the dataframes of a recorded scenario
+// misread dataframes are included, the analysis rejects them by the DTC5 value
+func getRecordedDataframes(t *testing.T, filename string) []MemsData {
+	var frames []MemsData
+
+	reader, err := NewResponderFileReader(filename)
+	then.AssertThat(t, err, is.Nil())
+
+	info, err := reader.Load()
+	then.AssertThat(t, err, is.Nil())
+
+	for _, frame := range info.Data {
+		d80, _ := hex.DecodeString(frame.Dataframe80)
+		d7d, _ := hex.DecodeString(frame.Dataframe7d)
+
+		data := decodeMemsData(truncateDataframe(d80, frame80Size+1), truncateDataframe(d7d, frame7dSize+1))
+		data.Time = frame.Time
+		frames = append(frames, data)
+	}
+
+	return frames
+}
+
 func Test_Analyse(t *testing.T) {
 	d := NewDataframeAnalysis(30)
 
@@ -0,0 +1,86 @@
+package rosco
+
+import (
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// memsDataTimeFormat is the serialised form of the MemsData timestamp
+const memsDataTimeFormat = "2006-01-02 15:04:05.000"
+
+// Clock returns the current time of the session
+// live sessions use the system clock, replayed sessions use the recorded time of the dataframes
+type Clock func() time.Time
+
+// SetClock sets the clock used to timestamp the dataframes
+func (df *DataframeAnalysis) SetClock(clock Clock) {
+	if clock == nil {
+		clock = time.Now
+	}
+
+	df.clock = clock
+}
+
+// StampFrame sets the timestamp of the dataframe and the time elapsed since the session started
+// dataframes with a recorded time keep it, otherwise the time is taken from the session clock
+func (df *DataframeAnalysis) StampFrame(data MemsData) MemsData {
+	if data.Timestamp.IsZero() {
+		data.Timestamp = df.getTimestamp(data)
+	}
+
+	// a replayed scenario restarts from the beginning when it reaches the end
+	if df.sessionStartedAt.IsZero() || data.Timestamp.Before(df.lastFrameAt) {
+		if !df.sessionStartedAt.IsZero() {
+			log.Infof("dataframe time %s is before the previous dataframe, restarting session clock", data.Timestamp.Format(memsDataTimeFormat))
+		}
+		df.sessionStartedAt = data.Timestamp
+	}
+
+	df.lastFrameAt = data.Timestamp
+	data.Elapsed = data.Timestamp.Sub(df.sessionStartedAt)
+
+	// the string form of the time is only used for serialisation
+	if data.Time == "" {
+		data.Time = data.Timestamp.Format(memsDataTimeFormat)
+	}
+
+	return data
+}
+
+// getTimestamp returns the time of the dataframe
+// uses the timestamp if set, otherwise parses the time field and falls back to the session clock
+func (df *DataframeAnalysis) getTimestamp(data MemsData) time.Time {
+	if !data.Timestamp.IsZero() {
+		return data.Timestamp
+	}
+
+	if data.Time != "" {
+		if timestamp, err := parseTimeField(data.Time); err == nil {
+			return timestamp
+		}
+	}
+
+	return df.now()
+}
+
+func (df *DataframeAnalysis) now() time.Time {
+	if df.clock == nil {
+		return time.Now()
+	}
+
+	return df.clock()
+}
+
+// parseTimeField parses the time formats used in the log and scenario files
+func parseTimeField(timeField string) (time.Time, error) {
+	var err error
+	var timestamp time.Time
+
+	for _, layout := range []string{memsDataTimeFormat, timeFormat, "15:04:05", "04:05.0"} {
+		if timestamp, err = time.Parse(layout, timeField); err == nil {
+			return timestamp, err
+		}
+	}
+
+	return timestamp, err
+}
@@ -0,0 +1,93 @@
+package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"testing"
+	"time"
+)
+
+func Test_timestamp_StampFrameUsesClock(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+	now := time.Date(2022, 3, 4, 11, 39, 23, 0, time.UTC)
+	d.SetClock(func() time.Time { return now })
+
+	data := d.StampFrame(MemsData{})
+	then.AssertThat(t, data.Timestamp, is.EqualTo(now))
+	then.AssertThat(t, data.Elapsed, is.EqualTo(time.Duration(0)))
+	then.AssertThat(t, data.Time, is.EqualTo("2022-03-04 11:39:23.000"))
+
+	now = now.Add(time.Second * 5)
+	data = d.StampFrame(MemsData{})
+	then.AssertThat(t, data.Elapsed, is.EqualTo(time.Second*5))
+
+	// a replayed scenario restarting from the beginning restarts the session clock
+	now = now.Add(-time.Minute)
+	data = d.StampFrame(MemsData{})
+	then.AssertThat(t, data.Elapsed, is.EqualTo(time.Duration(0)))
+}
+
+func Test_timestamp_StampFrameUsesRecordedTime(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+
+	data := d.StampFrame(MemsData{Time: "2022-03-04 11:39:23.357"})
+	then.AssertThat(t, data.Timestamp, is.EqualTo(time.Date(2022, 3, 4, 11, 39, 23, 357000000, time.UTC)))
+	then.AssertThat(t, data.Time, is.EqualTo("2022-03-04 11:39:23.357"))
+}
+
+func Test_timestamp_LiveEngineStartTime(t *testing.T) {
+	d := NewDataframeAnalysis(20)
+
+	// live dataframes include the date in the time field
+	d.analyseOperationalStatus(MemsData{Time: "2022-03-04 11:39:23.357", EngineRPM: rpmIdle})
+	then.AssertThat(t, d.engineStartedAt, is.EqualTo(time.Date(2022, 3, 4, 11, 39, 23, 357000000, time.UTC)))
+}
+
+func Test_timestamp_ScenarioTimestamp(t *testing.T) {
+	date := time.Date(2022, 3, 4, 0, 0, 0, 0, time.UTC)
+	timestamp, _ := ConvertTimeFieldToDate("23:59:59.000")
+
+	first := getScenarioTimestamp(date, timestamp, time.Time{})
+	then.AssertThat(t, first, is.EqualTo(time.Date(2022, 3, 4, 23, 59, 59, 0, time.UTC)))
+
+	// times after midnight move to the next day
+	timestamp, _ = ConvertTimeFieldToDate("00:00:01.000")
+	then.AssertThat(t, getScenarioTimestamp(date, timestamp, first), is.EqualTo(time.Date(2022, 3, 5, 0, 0, 1, 0, time.UTC)))
+
+	// times with a date are unchanged
+	timestamp, _ = ConvertTimeFieldToDate("2021-01-01 10:00:00.000")
+	then.AssertThat(t, getScenarioTimestamp(date, timestamp, first), is.EqualTo(timestamp))
+}
+
+func Test_timestamp_ReplayUsesRecordedTime(t *testing.T) {
+	r := NewECUReaderInstance()
+	r.ecuReader = NewECUReader("testdata/full-warmup-working-lambda.fcr")
+	_, _ = r.connectToECU()
+	then.AssertThat(t, r.Responder, is.Not(is.Nil()))
+
+	first, err := r.GetDataframes()
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, first.Time, is.EqualTo("2022-03-04 11:39:23.357"))
+	then.AssertThat(t, first.Timestamp, is.EqualTo(r.Responder.Playbook.Responses[0].Timestamp))
+
+	second, _ := r.GetDataframes()
+	then.AssertThat(t, second.Elapsed, is.EqualTo(second.Timestamp.Sub(first.Timestamp)))
+	then.AssertThat(t, second.Elapsed, is.EqualTo(time.Millisecond*499))
+}
+
+func Test_timestamp_ReplayWithoutRecordedDate(t *testing.T) {
+	// the nofaults scenario was converted from a log recorded without a date
+	r := NewECUReaderInstance()
+	r.ecuReader = NewECUReader("testdata/nofaults.fcr")
+	_, _ = r.connectToECU()
+	then.AssertThat(t, r.Responder, is.Not(is.Nil()))
+
+	// the recorded times are placed on the undated scenario date, every replay has the same timestamps
+	first, err := r.GetDataframes()
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, first.Timestamp, is.EqualTo(time.Date(1970, 1, 1, 12, 35, 55, 186000000, time.UTC)))
+	then.AssertThat(t, first.Time, is.EqualTo(first.Timestamp.Format(memsDataTimeFormat)))
+
+	second, _ := r.GetDataframes()
+	then.AssertThat(t, second.Elapsed, is.EqualTo(time.Millisecond*543))
+}
@@ -0,0 +1,336 @@
+package rosco
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/stat"
+	"math"
+	"strings"
+	"time"
+)
+
+// ThermostatState is the condition of the thermostat estimated from the coolant temperature curve
+type ThermostatState string
+
+const (
+	// ThermostatUnknown not enough of the warm-up curve has been recorded
+	ThermostatUnknown ThermostatState = "unknown"
+	// ThermostatWarming the coolant temperature is rising, the thermostat has not opened
+	ThermostatWarming ThermostatState = "warming"
+	// ThermostatOpen the coolant temperature has plateaued in the expected range
+	ThermostatOpen ThermostatState = "open"
+	// ThermostatStuckOpen the coolant temperature has plateaued below operating temperature
+	ThermostatStuckOpen ThermostatState = "stuck-open"
+	// ThermostatStuckClosed the coolant temperature has risen above the highest thermostat opening temperature
+	ThermostatStuckClosed ThermostatState = "stuck-closed"
+)
+
+const (
+	// coolant temperature is sampled at this interval for the warm-up curve
+	warmUpSampleInterval = 5 * time.Second
+	// the samples are thinned to every other sample and the interval doubled when the curve reaches this number,
+	// so the whole curve is kept in a bounded number of samples
+	maximumWarmUpSamples = 360
+	// minimum number of samples required to model the warm-up curve
+	minimumWarmUpSamples = 12
+	// minimum number of samples either side of the thermostat opening
+	minimumWarmUpSegment = 6
+	// the coolant temperature must hold for this time to be a plateau
+	thermostatPlateauDuration = 2 * time.Minute
+	// the coolant temperature is on a plateau if it changes by less than this rate in °C per minute
+	maximumPlateauRate = 0.5
+	// the coolant temperature is on a plateau if it deviates by less than this in °C
+	maximumPlateauDeviation = 3.0
+	// a plateau must reduce the fit error of a straight line by this proportion
+	plateauFitImprovement = 0.5
+	// the confidence of a stuck thermostat is full when the temperature is this far outside the expected range
+	thermostatTemperatureMargin = 5.0
+	// confidence required to report a stuck thermostat as a fault
+	thermostatFaultConfidence = 0.5
+)
+
+// WarmUpModel is the fit of the coolant temperature curve whilst the engine is running
+// temperatures are in °C and rates in °C per minute
+type WarmUpModel struct {
+	// Samples is the number of coolant temperature samples in the curve
+	Samples int `json:"Samples"`
+	// Duration of the curve from the first sample
+	Duration time.Duration `json:"Duration"`
+	// StartTemperature is the coolant temperature when the engine started
+	StartTemperature float64 `json:"StartTemperature"`
+	// CurrentTemperature is the latest coolant temperature
+	CurrentTemperature float64 `json:"CurrentTemperature"`
+	// AmbientTemperature is the intake air temperature when the engine started
+	AmbientTemperature float64 `json:"AmbientTemperature"`
+	// WarmUpRate is the fitted rate of the rising part of the curve
+	WarmUpRate float64 `json:"WarmUpRate"`
+	// ExpectedWarmUpRate is the rate given by the threshold profile
+	ExpectedWarmUpRate float64 `json:"ExpectedWarmUpRate"`
+	// NormalisedWarmUpRate is the warm-up rate as a percentage of the rise from ambient to operating temperature
+	NormalisedWarmUpRate float64 `json:"NormalisedWarmUpRate"`
+	// EstimatedWarmUpTime is the time to reach operating temperature from the start temperature at the fitted rate
+	EstimatedWarmUpTime time.Duration `json:"EstimatedWarmUpTime"`
+	// ThermostatOpened is true if the curve has plateaued
+	ThermostatOpened bool `json:"ThermostatOpened"`
+	// ThermostatOpeningTemperature is the temperature the curve plateaus at
+	ThermostatOpeningTemperature float64 `json:"ThermostatOpeningTemperature"`
+	// ThermostatOpenedAfter is the time from the first sample to the start of the plateau
+	ThermostatOpenedAfter time.Duration `json:"ThermostatOpenedAfter"`
+	// FitRSquared is the coefficient of determination of the fitted curve
+	FitRSquared float64 `json:"FitRSquared"`
+	// State is the estimated condition of the thermostat
+	State ThermostatState `json:"State"`
+	// Confidence in the estimated state, 0 to 1
+	Confidence float64 `json:"Confidence"`
+}
+
+// warmUpCurve records the coolant temperature whilst the engine is running
+type warmUpCurve struct {
+	startedAt     time.Time
+	lastSampledAt time.Time
+	interval      time.Duration
+	minutes       []float64
+	temperatures  []float64
+	ambient       float64
+	model         WarmUpModel
+	// engineStopped is set when the engine stops, the curve is restarted when the engine starts again
+	engineStopped bool
+}
+
+func newWarmUpCurve() *warmUpCurve {
+	return &warmUpCurve{interval: warmUpSampleInterval, model: WarmUpModel{State: ThermostatUnknown}}
+}
+
+// GetWarmUpModel returns the latest fit of the coolant temperature curve
+func (df *DataframeAnalysis) GetWarmUpModel() WarmUpModel {
+	return df.warmUp.model
+}
+
+// updateWarmUpModel samples the coolant temperature and refits the curve when a sample is added
+func (df *DataframeAnalysis) updateWarmUpModel(data MemsData) {
+	curve := df.warmUp
+	currentTime := df.getTimestamp(data)
+
+	// a replayed scenario restarts from the beginning when it reaches the end
+	// and the curve of a restarted engine starts from the new start temperature
+	if currentTime.Before(curve.lastSampledAt) || curve.engineStopped {
+		curve = newWarmUpCurve()
+		df.warmUp = curve
+	}
+
+	if curve.startedAt.IsZero() {
+		curve.startedAt = currentTime
+		curve.ambient = float64(data.IntakeAirTemp)
+	} else if currentTime.Sub(curve.lastSampledAt) < curve.interval {
+		return
+	}
+
+	curve.lastSampledAt = currentTime
+	curve.minutes = append(curve.minutes, currentTime.Sub(curve.startedAt).Minutes())
+	curve.temperatures = append(curve.temperatures, float64(data.CoolantTemp))
+
+	if len(curve.temperatures) > maximumWarmUpSamples {
+		curve.thin()
+	}
+
+	curve.model = curve.fit(df.profile)
+}
+
+// thin keeps every other sample and doubles the sample interval, the first and latest samples are kept
+func (curve *warmUpCurve) thin() {
+	n := len(curve.temperatures)
+	var minutes, temperatures []float64
+
+	for i := 0; i < n; i += 2 {
+		minutes = append(minutes, curve.minutes[i])
+		temperatures = append(temperatures, curve.temperatures[i])
+	}
+
+	if n%2 == 0 {
+		minutes = append(minutes, curve.minutes[n-1])
+		temperatures = append(temperatures, curve.temperatures[n-1])
+	}
+
+	curve.minutes = minutes
+	curve.temperatures = temperatures
+	curve.interval *= 2
+}
+
+// fit models the curve as a linear rise followed by a plateau once the thermostat opens
+func (curve *warmUpCurve) fit(profile ThresholdProfile) WarmUpModel {
+	n := len(curve.temperatures)
+	x := curve.minutes
+	y := curve.temperatures
+
+	model := WarmUpModel{
+		Samples:            n,
+		Duration:           time.Duration(x[n-1] * float64(time.Minute)),
+		StartTemperature:   y[0],
+		CurrentTemperature: y[n-1],
+		AmbientTemperature: curve.ambient,
+		State:              ThermostatUnknown,
+	}
+
+	if profile.SecondsPerDegree > 0 {
+		model.ExpectedWarmUpRate = roundTo(60/float64(profile.SecondsPerDegree), 2)
+	}
+
+	if n < minimumWarmUpSamples {
+		return model
+	}
+
+	// a straight line through the whole curve
+	alpha, beta := stat.LinearRegression(x, y, nil, false)
+	lineError := sumSquaredError(x, y, alpha, beta)
+	totalError := sumSquaredError(x, y, stat.Mean(y, nil), 0)
+
+	rate := beta
+	fitError := lineError
+	plateauStart := -1
+
+	// the engine was already at a steady temperature
+	if _, deviation := stat.MeanStdDev(y, nil); math.Abs(beta) <= maximumPlateauRate && deviation <= maximumPlateauDeviation && model.Duration >= thermostatPlateauDuration {
+		plateauStart = 0
+	}
+
+	// find the rise and plateau that best fit the curve
+	for k := minimumWarmUpSegment; k <= n-minimumWarmUpSegment; k++ {
+		plateauDuration := time.Duration((x[n-1] - x[k]) * float64(time.Minute))
+		if plateauDuration < thermostatPlateauDuration {
+			break
+		}
+
+		riseAlpha, riseBeta := stat.LinearRegression(x[:k], y[:k], nil, false)
+		_, plateauBeta := stat.LinearRegression(x[k:], y[k:], nil, false)
+		plateauMean, plateauDeviation := stat.MeanStdDev(y[k:], nil)
+
+		if math.Abs(plateauBeta) > maximumPlateauRate || plateauDeviation > maximumPlateauDeviation || riseBeta <= maximumPlateauRate {
+			continue
+		}
+
+		hingeError := sumSquaredError(x[:k], y[:k], riseAlpha, riseBeta) + sumSquaredError(x[k:], y[k:], plateauMean, 0)
+
+		if hingeError < lineError*plateauFitImprovement && hingeError < fitError {
+			fitError = hingeError
+			rate = riseBeta
+			plateauStart = k
+		}
+	}
+
+	model.FitRSquared = 1
+	if totalError > 0 {
+		model.FitRSquared = roundTo(math.Max(0, 1-fitError/totalError), 3)
+	}
+
+	// a steady temperature has nothing to explain, the quality of the fit is how steady it is
+	quality := model.FitRSquared
+	if plateauStart == 0 {
+		_, deviation := stat.MeanStdDev(y, nil)
+		quality = 1 - deviation/maximumPlateauDeviation
+	}
+
+	if plateauStart >= 0 {
+		model.ThermostatOpened = true
+		model.ThermostatOpeningTemperature = roundTo(stat.Mean(y[plateauStart:], nil), 1)
+		model.ThermostatOpenedAfter = time.Duration(x[plateauStart] * float64(time.Minute))
+	}
+
+	if plateauStart != 0 {
+		model.WarmUpRate = roundTo(rate, 2)
+	}
+
+	model.setWarmUpRates(profile)
+	model.setThermostatState(profile, quality)
+
+	return model
+}
+
+// setWarmUpRates compares the warm-up rate to the rise from ambient to operating temperature
+func (model *WarmUpModel) setWarmUpRates(profile ThresholdProfile) {
+	rise := float64(profile.LowestEngineWarmTemperature) - model.AmbientTemperature
+
+	if model.WarmUpRate > 0 && rise > 0 {
+		model.NormalisedWarmUpRate = roundTo(model.WarmUpRate/rise*100, 1)
+	}
+
+	if model.WarmUpRate > 0 {
+		remaining := math.Max(0, float64(profile.LowestEngineWarmTemperature)-model.StartTemperature)
+		model.EstimatedWarmUpTime = time.Duration(remaining / model.WarmUpRate * float64(time.Minute)).Round(time.Second)
+	}
+}
+
+// setThermostatState estimates the state of the thermostat and the confidence in the estimate
+// the confidence is the quality of the fit, reduced for short plateaus and temperatures close to the limits
+func (model *WarmUpModel) setThermostatState(profile ThresholdProfile, quality float64) {
+	lowest := float64(profile.LowestEngineWarmTemperature)
+	highest := float64(profile.HighestThermostatOpeningTemp)
+	confidence := quality
+
+	if model.ThermostatOpened {
+		plateauDuration := model.Duration - model.ThermostatOpenedAfter
+		confidence *= math.Min(1, plateauDuration.Minutes()/thermostatPlateauDuration.Minutes())
+
+		switch {
+		case model.ThermostatOpeningTemperature < lowest:
+			model.State = ThermostatStuckOpen
+			confidence *= math.Min(1, (lowest-model.ThermostatOpeningTemperature)/thermostatTemperatureMargin)
+		case highest > 0 && model.ThermostatOpeningTemperature > highest:
+			model.State = ThermostatStuckClosed
+			confidence *= math.Min(1, (model.ThermostatOpeningTemperature-highest)/thermostatTemperatureMargin)
+		default:
+			model.State = ThermostatOpen
+		}
+	} else {
+		if highest > 0 && model.CurrentTemperature > highest {
+			model.State = ThermostatStuckClosed
+			confidence *= math.Min(1, (model.CurrentTemperature-highest)/thermostatTemperatureMargin)
+		} else {
+			model.State = ThermostatWarming
+		}
+	}
+
+	model.Confidence = roundTo(confidence, 2)
+}
+
+// isThermostatStuck returns true if the warm-up curve shows the thermostat stuck open or closed
+func (model WarmUpModel) isThermostatStuck() bool {
+	return (model.State == ThermostatStuckOpen || model.State == ThermostatStuckClosed) &&
+		model.Confidence >= thermostatFaultConfidence
+}
+
+func sumSquaredError(x, y []float64, alpha, beta float64) float64 {
+	var sum float64
+
+	for i := range x {
+		residual := y[i] - (alpha + beta*x[i])
+		sum += residual * residual
+	}
+
+	return sum
+}
+
+func roundTo(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
+
+// toMarkdown returns the warm-up model as a Markdown table
+func (model WarmUpModel) toMarkdown() string {
+	var sb strings.Builder
+
+	if model.State == ThermostatUnknown {
+		return "Not enough of the warm-up curve was recorded to model.\n"
+	}
+
+	sb.WriteString("| Warm Up | |\n|--------|--------|\n")
+	sb.WriteString(fmt.Sprintf("| Thermostat | %s (%.0f%% confidence) |\n", model.State, model.Confidence*100))
+	sb.WriteString(fmt.Sprintf("| Coolant Temperature | %.0f°C to %.0f°C |\n", model.StartTemperature, model.CurrentTemperature))
+	sb.WriteString(fmt.Sprintf("| Ambient Temperature | %.0f°C |\n", model.AmbientTemperature))
+	sb.WriteString(fmt.Sprintf("| Warm Up Rate | %.2f°C/min (expected %.2f°C/min) |\n", model.WarmUpRate, model.ExpectedWarmUpRate))
+	sb.WriteString(fmt.Sprintf("| Estimated Warm Up Time | %s |\n", humanizeDuration(model.EstimatedWarmUpTime)))
+	if model.ThermostatOpened {
+		sb.WriteString(fmt.Sprintf("| Thermostat Opening Temperature | %.1f°C after %s |\n", model.ThermostatOpeningTemperature, humanizeDuration(model.ThermostatOpenedAfter)))
+	}
+	sb.WriteString(fmt.Sprintf("| Fit R² | %.3f |\n", model.FitRSquared))
+
+	return sb.String()
+}
@@ -0,0 +1,196 @@
+package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// coolantCurve sets the coolant temperature from a function of the minutes since the engine started
+func coolantCurve(coolantTemp func(minutes float64) float64) func(sample int, elapsed time.Duration, data *MemsData) {
+	return func(sample int, elapsed time.Duration, data *MemsData) {
+		data.CoolantTemp = int(math.Round(coolantTemp(elapsed.Minutes())))
+	}
+}
+
+// fitWarmUpFrames fits the warm-up model to the dataframes without the rest of the analysis,
+// so the curves can rise above the coolant temperatures the analysis accepts
+func fitWarmUpFrames(frames []MemsData) *DataframeAnalysis {
+	d := NewDataframeAnalysis(20)
+
+	for _, data := range frames {
+		d.updateWarmUpModel(data)
+	}
+
+	return d
+}
+
+// rise at the rate from the start temperature to the plateau
+func warmUpCurveTo(start float64, rate float64, plateau float64) func(minutes float64) float64 {
+	return func(minutes float64) float64 {
+		return math.Min(start+rate*minutes, plateau)
+	}
+}
+
+func Test_warmUp_NotEnoughSamples(t *testing.T) {
+	d := fitWarmUpFrames(getTestFrames(30*time.Second, time.Second, coolantCurve(warmUpCurveTo(30, 8, 88))))
+	model := d.GetWarmUpModel()
+
+	then.AssertThat(t, model.State, is.EqualTo(ThermostatUnknown))
+	then.AssertThat(t, model.Samples, is.EqualTo(7))
+	then.AssertThat(t, model.StartTemperature, is.EqualTo(float64(30)))
+	then.AssertThat(t, model.AmbientTemperature, is.EqualTo(float64(goodIntakeTemperature)))
+}
+
+func Test_warmUp_Warming(t *testing.T) {
+	d := fitWarmUpFrames(getTestFrames(5*time.Minute, time.Second, coolantCurve(warmUpCurveTo(30, 8, 88))))
+	model := d.GetWarmUpModel()
+
+	then.AssertThat(t, model.State, is.EqualTo(ThermostatWarming))
+	then.AssertThat(t, model.ThermostatOpened, is.False())
+	then.AssertThat(t, math.Abs(model.WarmUpRate-8), is.LessThan(0.1))
+	then.AssertThat(t, model.ExpectedWarmUpRate, is.EqualTo(5.45))
+	then.AssertThat(t, model.EstimatedWarmUpTime, is.EqualTo(6*time.Minute))
+	then.AssertThat(t, model.FitRSquared, is.GreaterThan(0.99))
+}
+
+func Test_warmUp_ThermostatOpen(t *testing.T) {
+	d := fitWarmUpFrames(getTestFrames(12*time.Minute, time.Second, coolantCurve(warmUpCurveTo(30, 8, 88))))
+	model := d.GetWarmUpModel()
+
+	then.AssertThat(t, model.State, is.EqualTo(ThermostatOpen))
+	then.AssertThat(t, model.ThermostatOpened, is.True())
+	then.AssertThat(t, model.ThermostatOpeningTemperature, is.EqualTo(float64(88)))
+	then.AssertThat(t, math.Abs(model.ThermostatOpenedAfter.Minutes()-7.25), is.LessThan(0.25))
+	then.AssertThat(t, math.Abs(model.WarmUpRate-8), is.LessThan(0.1))
+	then.AssertThat(t, model.Confidence, is.GreaterThan(0.9))
+	then.AssertThat(t, model.isThermostatStuck(), is.False())
+}
+
+func Test_warmUp_AlreadyWarm(t *testing.T) {
+	d := fitWarmUpFrames(getTestFrames(5*time.Minute, time.Second, coolantCurve(func(minutes float64) float64 {
+		return 88 + math.Sin(minutes*4)
+	})))
+	model := d.GetWarmUpModel()
+
+	then.AssertThat(t, model.State, is.EqualTo(ThermostatOpen))
+	then.AssertThat(t, model.ThermostatOpenedAfter, is.EqualTo(time.Duration(0)))
+	then.AssertThat(t, model.WarmUpRate, is.EqualTo(float64(0)))
+	then.AssertThat(t, math.Abs(model.ThermostatOpeningTemperature-88), is.LessThan(0.5))
+}
+
+func Test_warmUp_ThermostatStuckOpen(t *testing.T) {
+	d := fitWarmUpFrames(getTestFrames(15*time.Minute, time.Second, coolantCurve(warmUpCurveTo(20, 5, 65))))
+	model := d.GetWarmUpModel()
+
+	then.AssertThat(t, model.State, is.EqualTo(ThermostatStuckOpen))
+	then.AssertThat(t, model.ThermostatOpeningTemperature, is.EqualTo(float64(65)))
+	then.AssertThat(t, model.Confidence, is.GreaterThan(0.9))
+	then.AssertThat(t, model.isThermostatStuck(), is.True())
+
+	// a plateau just below operating temperature has a low confidence
+	d = fitWarmUpFrames(getTestFrames(15*time.Minute, time.Second, coolantCurve(warmUpCurveTo(20, 8, float64(KSeriesProfile.LowestEngineWarmTemperature-1)))))
+	model = d.GetWarmUpModel()
+
+	then.AssertThat(t, model.State, is.EqualTo(ThermostatStuckOpen))
+	then.AssertThat(t, model.Confidence, is.LessThan(thermostatFaultConfidence))
+	then.AssertThat(t, model.isThermostatStuck(), is.False())
+}
+
+func Test_warmUp_ThermostatStuckClosed(t *testing.T) {
+	d := fitWarmUpFrames(getTestFrames(10*time.Minute, time.Second, coolantCurve(warmUpCurveTo(40, 8, 120))))
+	model := d.GetWarmUpModel()
+
+	then.AssertThat(t, model.State, is.EqualTo(ThermostatStuckClosed))
+	then.AssertThat(t, model.ThermostatOpened, is.False())
+	then.AssertThat(t, model.CurrentTemperature, is.EqualTo(float64(120)))
+	then.AssertThat(t, model.isThermostatStuck(), is.True())
+}
+
+func Test_warmUp_ScenarioRestart(t *testing.T) {
+	frames := getTestFrames(5*time.Minute, time.Second, coolantCurve(warmUpCurveTo(30, 8, 88)))
+	d := fitWarmUpFrames(frames)
+	d.updateWarmUpModel(frames[0])
+
+	then.AssertThat(t, d.GetWarmUpModel().Samples, is.EqualTo(1))
+}
+
+func Test_warmUp_EngineRestart(t *testing.T) {
+	frames := getTestFrames(10*time.Minute, time.Second, coolantCurve(warmUpCurveTo(30, 8, 88)))
+	d := analyseTestFrames(NewDataframeAnalysis(20), frames)
+
+	then.AssertThat(t, d.GetWarmUpModel().StartTemperature, is.EqualTo(float64(30)))
+
+	// the engine stops and is restarted warm, the curve starts again from the warm engine
+	last := frames[len(frames)-1]
+	stopped := last
+	stopped.EngineRPM = engineStopped
+	stopped.Timestamp = last.Timestamp.Add(time.Minute)
+	d.Analyse(stopped)
+
+	restarted := last
+	restarted.Timestamp = stopped.Timestamp.Add(time.Minute)
+	d.Analyse(restarted)
+
+	then.AssertThat(t, d.GetWarmUpModel().Samples, is.EqualTo(1))
+	then.AssertThat(t, d.GetWarmUpModel().StartTemperature, is.EqualTo(float64(88)))
+}
+
+func Test_warmUp_SamplesCapped(t *testing.T) {
+	// a long session is sampled every 5 seconds until the cap is reached
+	frames := getTestFrames(2*time.Hour, time.Second, coolantCurve(warmUpCurveTo(30, 8, 88)))
+	d := fitWarmUpFrames(frames)
+
+	model := d.GetWarmUpModel()
+	then.AssertThat(t, model.Samples, is.LessThanOrEqualTo(maximumWarmUpSamples))
+	then.AssertThat(t, model.Samples, is.GreaterThan(maximumWarmUpSamples/2))
+
+	// the whole curve is kept
+	then.AssertThat(t, model.StartTemperature, is.EqualTo(float64(30)))
+	then.AssertThat(t, model.Duration.Minutes(), is.GreaterThan(float64(119)))
+	then.AssertThat(t, model.State, is.EqualTo(ThermostatOpen))
+}
+
+func Test_warmUp_isThermostatFaulty(t *testing.T) {
+	frames := getTestFrames(15*time.Minute, time.Second, coolantCurve(warmUpCurveTo(20, 5, 65)))
+	d := fitWarmUpFrames(frames)
+
+	// the linear estimate has not yet expired, the curve shows the thermostat stuck open
+	d.expectedTimeEngineWarm = frames[len(frames)-1].Timestamp.Add(time.Hour)
+	then.AssertThat(t, d.isThermostatFaulty(frames[len(frames)-1]), is.True())
+
+	// the linear estimate has expired, the curve shows the thermostat open
+	frames = getTestFrames(12*time.Minute, time.Second, coolantCurve(warmUpCurveTo(30, 8, 88)))
+	d = fitWarmUpFrames(frames)
+	d.expectedTimeEngineWarm = frames[0].Timestamp
+	data := frames[len(frames)-1]
+	data.CoolantTemp = KSeriesProfile.LowestEngineWarmTemperature - 1
+	then.AssertThat(t, d.isThermostatFaulty(data), is.False())
+}
+
+func Test_warmUp_Recordings(t *testing.T) {
+	// neither recording reaches the thermostat opening temperature, the engine warms steadily
+	for filename, d := range analyseRecordings(t) {
+		model := d.GetWarmUpModel()
+
+		then.AssertThat(t, model.State, is.EqualTo(ThermostatWarming).Reason(filename))
+		then.AssertThat(t, model.Samples, is.GreaterThan(minimumWarmUpSamples).Reason(filename))
+		then.AssertThat(t, model.FitRSquared, is.GreaterThan(0.9).Reason(filename))
+		then.AssertThat(t, d.Analysis.ThermostatFault, is.False().Reason(filename))
+	}
+}
+
+func Test_warmUp_SessionReport(t *testing.T) {
+	report, err := NewSessionAnalyser().AnalyseScenario("testdata/full-warmup-working-lambda.fcr")
+	then.AssertThat(t, err, is.Nil())
+
+	// the engine reaches operating temperature at the end of the session, before the thermostat opens
+	then.AssertThat(t, report.WarmUp.State, is.EqualTo(ThermostatWarming))
+	then.AssertThat(t, report.WarmUp.WarmUpRate, is.GreaterThan(report.WarmUp.ExpectedWarmUpRate))
+	then.AssertThat(t, report.WarmUp.NormalisedWarmUpRate, is.GreaterThan(float64(0)))
+	then.AssertThat(t, report.WarmUp.isThermostatStuck(), is.False())
+	then.AssertThat(t, strings.Contains(report.ToMarkdown(), "| Thermostat | warming"), is.True())
+}
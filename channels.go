@@ -0,0 +1,213 @@
+package rosco
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ChannelMetadata describes a MemsData channel so that it can be rendered generically
+type ChannelMetadata struct {
+	// Name of the MemsData field
+	Name        string `json:"Name"`
+	DisplayName string `json:"DisplayName"`
+	Unit        string `json:"Unit"`
+	// Min and Max are the expected range of the channel
+	Min float64 `json:"Min"`
+	Max float64 `json:"Max"`
+	// WarningLow and WarningHigh are the thresholds beyond which the value indicates a problem,
+	// nil when the channel has no threshold
+	WarningLow  *float64 `json:"WarningLow,omitempty"`
+	WarningHigh *float64 `json:"WarningHigh,omitempty"`
+	Description string   `json:"Description"`
+}
+
+// channelDefinitions provides the display details of every MemsData channel, the unit and description of
+// channels decoded from the dataframes are taken from the dataframe schema. Channels without a Min and Max
+// use the range of values that can be decoded from the dataframe. The warning thresholds are those of the
+// default threshold profile.
+var channelDefinitions = []ChannelMetadata{
+	{Name: "EngineRPM", DisplayName: "Engine Speed", Min: 0, Max: maximumEngineRPM},
+	{Name: "CoolantTemp", DisplayName: "Coolant Temperature", Min: -30, Max: 130, WarningHigh: threshold(maximumCoolantTemperature)},
+	{Name: "AmbientTemp", DisplayName: "Ambient Temperature"},
+	{Name: "IntakeAirTemp", DisplayName: "Intake Air Temperature", Min: -30, Max: 100, WarningHigh: threshold(maximumAirIntakeTemperature)},
+	{Name: "FuelTemp", DisplayName: "Fuel Temperature"},
+	{Name: "ManifoldAbsolutePressure", DisplayName: "Manifold Absolute Pressure", Min: 0, Max: 120},
+	{Name: "BatteryVoltage", DisplayName: "Battery Voltage", Min: 0, Max: 16, WarningLow: threshold(float64(DefaultThresholdProfile.LowestBatteryVoltage)), WarningHigh: threshold(float64(DefaultThresholdProfile.HighestChargingVoltage))},
+	{Name: "ThrottlePotSensor", DisplayName: "Throttle Potentiometer", Min: 0, Max: 5},
+	{Name: "ThrottlePosition", DisplayName: "Throttle Position", Unit: "%", Min: 0, Max: 100,
+		Description: "not decoded from the dataframes"},
+	{Name: "IdleSwitch", DisplayName: "Idle Switch"},
+	{Name: "AirconSwitch", DisplayName: "Air Conditioning Switch"},
+	{Name: "ParkNeutralSwitch", DisplayName: "Park / Neutral Switch"},
+	{Name: "DTC0", DisplayName: "Fault Codes DTC0"},
+	{Name: "DTC1", DisplayName: "Fault Codes DTC1"},
+	{Name: "IdleSetPoint", DisplayName: "Idle Set Point"},
+	{Name: "IdleHot", DisplayName: "Idle Hot", Min: 0, Max: 100, WarningLow: threshold(float64(DefaultThresholdProfile.MinimumIdleHot)), WarningHigh: threshold(float64(DefaultThresholdProfile.MaximumIdleHot))},
+	{Name: "Uk8011", DisplayName: "Unknown 80x11"},
+	{Name: "IACPosition", DisplayName: "Idle Air Control Position", Min: 0, Max: 180},
+	{Name: "IdleSpeedDeviation", DisplayName: "Idle Speed Deviation", Min: 0, Max: 1000, WarningHigh: threshold(float64(DefaultThresholdProfile.HighestIdleSpeedDeviation))},
+	{Name: "IgnitionAdvanceOffset80", DisplayName: "Ignition Advance Offset"},
+	{Name: "IgnitionAdvance", DisplayName: "Ignition Advance", Min: -10, Max: 50},
+	{Name: "CoilTime", DisplayName: "Coil Time", Min: 0, Max: 10},
+	{Name: "CrankshaftPositionSensor", DisplayName: "Crankshaft Position Sensor", WarningLow: threshold(invalidCASPosition + 1)},
+	{Name: "Uk801a", DisplayName: "Unknown 80x1A"},
+	{Name: "Uk801b", DisplayName: "Unknown 80x1B"},
+	{Name: "IgnitionSwitch", DisplayName: "Ignition Switch"},
+	{Name: "ThrottleAngle", DisplayName: "Throttle Angle", Min: 0, Max: 90},
+	{Name: "Uk7d03", DisplayName: "Unknown 7dx03"},
+	{Name: "AirFuelRatio", DisplayName: "Air:Fuel Ratio", Min: 10, Max: 20},
+	{Name: "DTC2", DisplayName: "Fault Codes DTC2"},
+	{Name: "LambdaVoltage", DisplayName: "Lambda Voltage", Min: 0, Max: 1000, WarningLow: threshold(float64(DefaultThresholdProfile.LowestLambdaValue)), WarningHigh: threshold(float64(DefaultThresholdProfile.HighestLambdaValue))},
+	{Name: "LambdaFrequency", DisplayName: "Lambda Frequency"},
+	{Name: "LambdaDutycycle", DisplayName: "Lambda Duty Cycle"},
+	{Name: "LambdaStatus", DisplayName: "Lambda Status", Min: 0, Max: 1},
+	{Name: "ClosedLoop", DisplayName: "Closed Loop"},
+	{Name: "LongTermFuelTrim", DisplayName: "Long Term Fuel Trim"},
+	{Name: "ShortTermFuelTrim", DisplayName: "Short Term Fuel Trim"},
+	{Name: "FuelTrimCorrection", DisplayName: "Fuel Trim Correction"},
+	{Name: "CarbonCanisterPurgeValve", DisplayName: "Carbon Canister Purge Valve"},
+	{Name: "DTC3", DisplayName: "Fault Codes DTC3"},
+	{Name: "IdleBasePosition", DisplayName: "Idle Base Position", WarningHigh: threshold(maximumIdleBasePosition)},
+	{Name: "Uk7d10", DisplayName: "Unknown 7dx10"},
+	{Name: "DTC4", DisplayName: "Fault Codes DTC4"},
+	{Name: "IgnitionAdvanceOffset7d", DisplayName: "Ignition Advance Offset 7d"},
+	{Name: "IdleSpeedOffset", DisplayName: "Idle Speed Offset"},
+	{Name: "Uk7d14", DisplayName: "Idle Error 2"},
+	{Name: "Uk7d15", DisplayName: "Unknown 7dx15"},
+	{Name: "DTC5", DisplayName: "Fault Codes DTC5"},
+	{Name: "Uk7d17", DisplayName: "Unknown 7dx17"},
+	{Name: "Uk7d18", DisplayName: "Unknown 7dx18"},
+	{Name: "Uk7d19", DisplayName: "Unknown 7dx19"},
+	{Name: "Uk7d1a", DisplayName: "Unknown 7dx1A"},
+	{Name: "Uk7d1b", DisplayName: "Unknown 7dx1B"},
+	{Name: "Uk7d1c", DisplayName: "Unknown 7dx1C"},
+	{Name: "Uk7d1d", DisplayName: "Unknown 7dx1D"},
+	{Name: "Uk7d1e", DisplayName: "Unknown 7dx1E"},
+	{Name: "JackCount", DisplayName: "Jack Count", Min: 0, Max: 255, WarningHigh: threshold(float64(DefaultThresholdProfile.HighestJackCount))},
+	{Name: "Vacuum", DisplayName: "Manifold Vacuum", Min: -20, Max: 100},
+	{Name: "EngineLoad", DisplayName: "Engine Load", Min: 0, Max: 100},
+	{Name: "Lambda", DisplayName: "Lambda", Min: 0.7, Max: 1.3},
+	{Name: "MixtureState", DisplayName: "Mixture"},
+	{Name: "FuelFlow", DisplayName: "Estimated Fuel Flow", Min: 0, Max: 50},
+	{Name: "CoolantTempSensorFault", DisplayName: "Coolant Temperature Sensor Fault",
+		Description: "coolant temperature sensor fault (Code 1) reported by the ECU"},
+	{Name: "IntakeAirTempSensorFault", DisplayName: "Intake Air Temperature Sensor Fault",
+		Description: "intake air temperature sensor fault (Code 2) reported by the ECU"},
+	{Name: "FuelPumpCircuitFault", DisplayName: "Fuel Pump Circuit Fault",
+		Description: "fuel pump circuit fault (Code 10) reported by the ECU"},
+	{Name: "ThrottlePotCircuitFault", DisplayName: "Throttle Potentiometer Circuit Fault",
+		Description: "throttle potentiometer circuit fault (Code 16) reported by the ECU"},
+}
+
+// channelRegistry is the metadata of the channels indexed by name
+var channelRegistry = createChannelRegistry()
+
+func threshold(value float64) *float64 {
+	return &value
+}
+
+func createChannelRegistry() map[string]ChannelMetadata {
+	registry := make(map[string]ChannelMetadata)
+
+	for _, metadata := range channelDefinitions {
+		channel, decoded := GetDataframeChannel(metadata.Name)
+
+		if decoded {
+			metadata.Unit = channel.Unit
+			metadata.Description = channel.Description
+		}
+
+		if metadata.Min == 0 && metadata.Max == 0 {
+			if field, _ := reflect.TypeOf(MemsData{}).FieldByName(metadata.Name); field.Type.Kind() == reflect.Bool {
+				// switches and faults
+				metadata.Max = 1
+			} else if decoded {
+				metadata.Min, metadata.Max = channel.getRange()
+			}
+		}
+
+		registry[metadata.Name] = metadata
+	}
+
+	return registry
+}
+
+// GetChannels returns the metadata of all the channels in MemsData order
+func GetChannels() []ChannelMetadata {
+	var channels []ChannelMetadata
+
+	for _, metadata := range channelDefinitions {
+		channels = append(channels, channelRegistry[metadata.Name])
+	}
+
+	return channels
+}
+
+// GetChannel returns the metadata for the named MemsData channel
+func GetChannel(name string) (ChannelMetadata, bool) {
+	metadata, ok := channelRegistry[name]
+	return metadata, ok
+}
+
+// GetValue returns the value of the channel in the MemsData as a float, switches are 0 or 1
+func (metadata ChannelMetadata) GetValue(data MemsData) float64 {
+	field := reflect.ValueOf(data).FieldByName(metadata.Name)
+
+	switch field.Kind() {
+	case reflect.Bool:
+		if field.Bool() {
+			return 1
+		}
+		return 0
+	case reflect.Float32:
+		return field.Float()
+	case reflect.Uint8:
+		return float64(field.Uint())
+	case reflect.Int:
+		return float64(field.Int())
+	}
+
+	return 0
+}
+
+// FormatValue returns the value of the channel in the MemsData with its unit
+func (metadata ChannelMetadata) FormatValue(data MemsData) string {
+	field := reflect.ValueOf(data).FieldByName(metadata.Name)
+
+	var value string
+
+	switch field.Kind() {
+	case reflect.Bool:
+		value = fmt.Sprintf("%t", field.Bool())
+	case reflect.String:
+		value = field.String()
+	case reflect.Float32:
+		value = fmt.Sprintf("%.2f", field.Float())
+	default:
+		value = fmt.Sprintf("%.0f", metadata.GetValue(data))
+	}
+
+	if metadata.Unit != "" {
+		value = fmt.Sprintf("%s %s", value, metadata.Unit)
+	}
+
+	return value
+}
+
+// IsWarning returns true if the value is beyond the warning thresholds of the channel
+func (metadata ChannelMetadata) IsWarning(value float64) bool {
+	if metadata.WarningLow != nil && value < *metadata.WarningLow {
+		return true
+	}
+
+	if metadata.WarningHigh != nil && value > *metadata.WarningHigh {
+		return true
+	}
+
+	return false
+}
+
+// IsInRange returns true if the value is within the expected range of the channel
+func (metadata ChannelMetadata) IsInRange(value float64) bool {
+	return value >= metadata.Min && value <= metadata.Max
+}
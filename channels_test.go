@@ -0,0 +1,93 @@
+package rosco
+
+import (
+	"encoding/json"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"reflect"
+	"testing"
+)
+
+func Test_channels_AllMemsDataChannelsRegistered(t *testing.T) {
+	memsdata := reflect.TypeOf(MemsData{})
+
+	for i := 0; i < memsdata.NumField(); i++ {
+		field := memsdata.Field(i)
+
+		switch field.Type.Kind() {
+		case reflect.Bool, reflect.Int, reflect.Uint8, reflect.Float32:
+			metadata, found := GetChannel(field.Name)
+			then.AssertThat(t, found, is.True().Reason(field.Name))
+			then.AssertThat(t, metadata.DisplayName, is.Not(is.EqualTo("")))
+			then.AssertThat(t, metadata.Description, is.Not(is.EqualTo("")))
+			then.AssertThat(t, metadata.Max > metadata.Min, is.True().Reason(field.Name))
+		}
+	}
+
+	then.AssertThat(t, len(GetChannels()), is.EqualTo(len(channelDefinitions)))
+}
+
+func Test_channels_GetChannel(t *testing.T) {
+	metadata, found := GetChannel("CoolantTemp")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, metadata.DisplayName, is.EqualTo("Coolant Temperature"))
+	then.AssertThat(t, metadata.Unit, is.EqualTo("°C"))
+	then.AssertThat(t, *metadata.WarningHigh, is.EqualTo(float64(maximumCoolantTemperature)))
+	then.AssertThat(t, metadata.WarningLow, is.Nil())
+
+	metadata, _ = GetChannel("LambdaVoltage")
+	then.AssertThat(t, metadata.Unit, is.EqualTo("mV"))
+
+	_, found = GetChannel("Analytics")
+	then.AssertThat(t, found, is.False())
+}
+
+func Test_channels_RangeFromSchema(t *testing.T) {
+	// channels without an expected range use the range that can be decoded
+	metadata, _ := GetChannel("LongTermFuelTrim")
+	then.AssertThat(t, metadata.Min, is.EqualTo(float64(-128)))
+	then.AssertThat(t, metadata.Max, is.EqualTo(float64(127)))
+
+	metadata, _ = GetChannel("IdleSwitch")
+	then.AssertThat(t, metadata.Min, is.EqualTo(float64(0)))
+	then.AssertThat(t, metadata.Max, is.EqualTo(float64(1)))
+}
+
+func Test_channels_IsWarning(t *testing.T) {
+	metadata, _ := GetChannel("BatteryVoltage")
+	then.AssertThat(t, metadata.IsWarning(12.5), is.True())
+	then.AssertThat(t, metadata.IsWarning(14.1), is.False())
+	then.AssertThat(t, metadata.IsWarning(15.5), is.True())
+	then.AssertThat(t, metadata.IsInRange(14.1), is.True())
+	then.AssertThat(t, metadata.IsInRange(20), is.False())
+
+	metadata, _ = GetChannel("EngineRPM")
+	then.AssertThat(t, metadata.IsWarning(850), is.False())
+}
+
+func Test_channels_GetValue(t *testing.T) {
+	data := MemsData{CoolantTemp: 88, BatteryVoltage: 14.1, ClosedLoop: true, DTC2: 4}
+
+	metadata, _ := GetChannel("CoolantTemp")
+	then.AssertThat(t, metadata.GetValue(data), is.EqualTo(float64(88)))
+	then.AssertThat(t, metadata.FormatValue(data), is.EqualTo("88 °C"))
+
+	metadata, _ = GetChannel("BatteryVoltage")
+	then.AssertThat(t, metadata.FormatValue(data), is.EqualTo("14.10 V"))
+
+	metadata, _ = GetChannel("ClosedLoop")
+	then.AssertThat(t, metadata.GetValue(data), is.EqualTo(float64(1)))
+	then.AssertThat(t, metadata.FormatValue(data), is.EqualTo("true"))
+
+	metadata, _ = GetChannel("DTC2")
+	then.AssertThat(t, metadata.GetValue(data), is.EqualTo(float64(4)))
+}
+
+func Test_channels_JSON(t *testing.T) {
+	metadata, _ := GetChannel("BatteryVoltage")
+
+	data, err := json.Marshal(metadata)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, string(data), is.ValueContaining(`"DisplayName":"Battery Voltage"`))
+	then.AssertThat(t, string(data), is.ValueContaining(`"WarningLow":`))
+}
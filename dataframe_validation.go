@@ -0,0 +1,69 @@
+package rosco
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrShortDataframe the dataframe has fewer bytes than the expected frame size
+	ErrShortDataframe = errors.New("short dataframe")
+	// ErrDataframeLengthMismatch the length byte of the dataframe does not match the expected frame size
+	ErrDataframeLengthMismatch = errors.New("dataframe length mismatch")
+	// ErrDataframeWrongEcho the first byte of the dataframe is not the echo of the command
+	ErrDataframeWrongEcho = errors.New("dataframe command echo mismatch")
+)
+
+// DataframeError describes why a dataframe is invalid
+// use errors.Is with ErrShortDataframe, ErrDataframeLengthMismatch or ErrDataframeWrongEcho to identify the cause
+type DataframeError struct {
+	Command  byte
+	Expected int
+	Received int
+	Err      error
+}
+
+func (e *DataframeError) Error() string {
+	return fmt.Sprintf("dataframe 0x%02X invalid, %s (expected %d, received %d)", e.Command, e.Err, e.Expected, e.Received)
+}
+
+func (e *DataframeError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateDataframe checks the command echo, the length byte and the size of the dataframe
+// returns a DataframeError if the dataframe is not valid
+func ValidateDataframe(command byte, frame []byte) error {
+	size := getDataframeSize(command)
+
+	if len(frame) < 2 {
+		return &DataframeError{Command: command, Expected: size + 1, Received: len(frame), Err: ErrShortDataframe}
+	}
+
+	if frame[0] != command {
+		return &DataframeError{Command: command, Expected: int(command), Received: int(frame[0]), Err: ErrDataframeWrongEcho}
+	}
+
+	if int(frame[1]) != size {
+		return &DataframeError{Command: command, Expected: size, Received: int(frame[1]), Err: ErrDataframeLengthMismatch}
+	}
+
+	// the frame is the command echo followed by the size byte and the data
+	if len(frame) < size+1 {
+		return &DataframeError{Command: command, Expected: size + 1, Received: len(frame), Err: ErrShortDataframe}
+	}
+
+	if len(frame) > size+1 {
+		return &DataframeError{Command: command, Expected: size + 1, Received: len(frame), Err: ErrDataframeLengthMismatch}
+	}
+
+	return nil
+}
+
+func getDataframeSize(command byte) int {
+	if command == frame7d {
+		return frame7dSize
+	}
+
+	return frame80Size
+}
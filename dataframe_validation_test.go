@@ -0,0 +1,92 @@
+package rosco
+
+import (
+	"encoding/hex"
+	"errors"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"strings"
+	"testing"
+)
+
+// invalidDataframeReader responds to the dataframe requests with the configured frames
+type invalidDataframeReader struct {
+	d80 []byte
+	d7d []byte
+}
+
+func (r *invalidDataframeReader) Connect() (bool, error) {
+	return true, nil
+}
+
+func (r *invalidDataframeReader) Disconnect() error {
+	return nil
+}
+
+func (r *invalidDataframeReader) SendAndReceive(command []byte) ([]byte, error) {
+	switch strings.ToUpper(hex.EncodeToString(command)) {
+	case "80":
+		return r.d80, nil
+	case "7D":
+		return r.d7d, nil
+	}
+
+	return generateECUResponse(hex.EncodeToString(command)), nil
+}
+
+func Test_validation_ValidDataframes(t *testing.T) {
+	then.AssertThat(t, ValidateDataframe(frame80, createResponseMap()["80"]), is.Nil())
+	then.AssertThat(t, ValidateDataframe(frame7d, createResponseMap()["7D"]), is.Nil())
+}
+
+func Test_validation_ShortDataframe(t *testing.T) {
+	err := ValidateDataframe(frame7d, createResponseMap()["7D"][:20])
+	then.AssertThat(t, errors.Is(err, ErrShortDataframe), is.True())
+
+	var dataframeError *DataframeError
+	then.AssertThat(t, errors.As(err, &dataframeError), is.True())
+	then.AssertThat(t, dataframeError.Expected, is.EqualTo(33))
+	then.AssertThat(t, dataframeError.Received, is.EqualTo(20))
+
+	err = ValidateDataframe(frame80, []byte{})
+	then.AssertThat(t, errors.Is(err, ErrShortDataframe), is.True())
+}
+
+func Test_validation_WrongEcho(t *testing.T) {
+	err := ValidateDataframe(frame80, createResponseMap()["7D"])
+	then.AssertThat(t, errors.Is(err, ErrDataframeWrongEcho), is.True())
+}
+
+func Test_validation_LengthMismatch(t *testing.T) {
+	d80 := append([]byte{}, createResponseMap()["80"]...)
+	d80[1] = 0x1b
+
+	err := ValidateDataframe(frame80, d80)
+	then.AssertThat(t, errors.Is(err, ErrDataframeLengthMismatch), is.True())
+
+	// frames with trailing bytes
+	d80 = append(createResponseMap()["80"], 0x00)
+
+	err = ValidateDataframe(frame80, d80)
+	then.AssertThat(t, errors.Is(err, ErrDataframeLengthMismatch), is.True())
+}
+
+func Test_validation_GetDataframesDiscardsInvalidFrames(t *testing.T) {
+	r := NewECUReaderInstance()
+	r.ecuReader = &invalidDataframeReader{d80: createResponseMap()["80"][:10], d7d: createResponseMap()["7D"]}
+
+	data, err := r.GetDataframes()
+	then.AssertThat(t, errors.Is(err, ErrShortDataframe), is.True())
+	then.AssertThat(t, data.Dataframe80, is.EqualTo(""))
+	then.AssertThat(t, r.Diagnostics.Analysis.IsEngineRunning, is.False())
+}
+
+func Test_validation_ResponderShortFrame(t *testing.T) {
+	responder := NewResponder()
+	responder.Playbook.Responses = []PlaybookResponse{{Dataframe80: createResponseMap()["80"][:10], Dataframe7d: append(createResponseMap()["7D"], 0x00, 0x00)}}
+	responder.Playbook.Count = 1
+
+	// short frames are served without panicking and long frames are truncated
+	then.AssertThat(t, len(responder.GetECUResponse([]byte{0x80})), is.EqualTo(10))
+	then.AssertThat(t, len(responder.GetECUResponse([]byte{0x7d})), is.EqualTo(33))
+}
@@ -17,25 +17,30 @@ type MemsDataLogger struct {
 	Filepath string
 	Filename string
 	IsOpen   bool
+	// Units the values are logged in
+	Units UnitSystem
 }
 
-const MemsDataHeader = "#time," +
-	"80x01-02_engine-rpm,80x03_coolant_temp,80x04_ambient_temp,80x05_intake_air_temp,80x06_fuel_temp,80x07_map_kpa,80x08_battery_voltage,80x09_throttle_pot,80x0A_idle_switch,80x0B_uk1," +
-	"80x0C_park_neutral_switch,80x0D-0E_fault_codes,80x0F_idle_set_point,80x10_idle_hot,80x11_uk2,80x12_iac_position,80x13-14_idle_error,80x15_ignition_advance_offset,80x16_ignition_advance,80x17-18_coil_time," +
-	"80x19_crankshaft_position_sensor,80x1A_uk4,80x1B_uk5," +
-	"7dx01_ignition_switch,7dx02_throttle_angle,7dx03_uk6,7dx04_air_fuel_ratio,7dx05_dtc2,7dx06_lambda_voltage,7dx07_lambda_sensor_frequency,7dx08_lambda_sensor_dutycycle,7dx09_lambda_sensor_status,7dx0A_closed_loop," +
-	"7dx0B_long_term_fuel_trim,7dx0C_short_term_fuel_trim,7dx0D_carbon_canister_dutycycle,7dx0E_dtc3,7dx0F_idle_base_pos,7dx10_uk7,7dx11_dtc4,7dx12_ignition_advance2,7dx13_idle_speed_offset,7dx14_idle_error2," +
-	"7dx14-15_uk10,7dx16_dtc5,7dx17_uk11,7dx18_uk12,7dx19_uk13,7dx1A_uk14,7dx1B_uk15,7dx1C_uk16,7dx1D_uk17,7dx1E_uk18,7dx1F_uk19,0x7d_raw,0x80_raw"
+// faultCodesColumn lists the names of the active fault codes
+const faultCodesColumn = "dtc_faults"
+
+// MemsDataHeader is the CSV log file header for the dataframe channels, the columns are defined by the dataframe schema
+var MemsDataHeader = getMemsDataHeader(MetricUnits)
 
 const DiagnosticsCSVHeader = "engine_running,warming,at_operating_temp,engine_idle,idle_fault,idle_speed_fault,idle_error_fault,idle_hot_fault," +
 	"cruising,closed_loop,closed_loop_expected,closed_loop_fault,throttle_active,map_fault,vacuum_fault,iac_fault,iac_range_fault,iac_jack_fault,o2_system_fault," +
-	"lambda_range_fault,lambda_oscillation_fault,thermostat_fault,crankshaft_sensor_fault,coil_fault"
+	"lambda_range_fault,lambda_oscillation_fault,thermostat_fault,crankshaft_sensor_fault,coil_fault,profile"
 
 // NewMemsDataLogger logs the mems data to a CSV file
 func NewMemsDataLogger(folder string, prefix string) *MemsDataLogger {
+	return NewMemsDataLoggerWithUnits(folder, prefix, MetricUnits)
+}
+
+// NewMemsDataLoggerWithUnits logs the mems data to a CSV file converting the values into the unit system
+func NewMemsDataLoggerWithUnits(folder string, prefix string, units UnitSystem) *MemsDataLogger {
 	var err error
 
-	datalogger := &MemsDataLogger{}
+	datalogger := &MemsDataLogger{Units: units}
 	filename := getFilename(folder, prefix)
 
 	if err = openFile(datalogger, filename); err == nil {
@@ -56,7 +61,7 @@ func writeFileHeader(datalogger *MemsDataLogger) error {
 
 	if datalogger.IsOpen {
 		// create the header
-		header := MemsDataHeader + "," + DiagnosticsCSVHeader
+		header := getMemsDataHeader(datalogger.Units) + "," + DiagnosticsCSVHeader
 		// write the header to the file
 		if err = datalogger.writer.Write(strings.Split(header, ",")); err == nil {
 			defer datalogger.writer.Flush()
@@ -91,7 +96,7 @@ func createFileWriter(datalogger *MemsDataLogger) {
 func (datalogger *MemsDataLogger) WriteMemsDataToFile(memsdata MemsData) {
 	if datalogger.IsOpen {
 		// convert the memdata into csv fields
-		data := convertMemsDataToCSVData(memsdata)
+		data := convertMemsDataToCSVData(memsdata, datalogger.Units)
 
 		// write the data
 		datalogger.writeMemsDataToLogfile(data)
@@ -131,90 +136,36 @@ func getFilename(folder string, prefix string) string {
 	return filepath.FromSlash(filename)
 }
 
-func convertMemsDataToCSVData(data MemsData) []string {
-	s := fmt.Sprintf("%s,"+
-		"%d,%d,%d,%d,%d,%.2f,%.2f,%.2f,%t,%t,"+
-		"%t,%d,%d,%d,%d,%d,%d,%d,%.2f,%.2f,"+
-		"%d,%d,%d,"+
-		"%t,%d,%d,%.2f,%d,%d,%d,%d,%d,%t,"+
-		"%d,%d,%d,%d,%d,%d,%d,%d,%d,%d,"+
-		"%d,%d,%d,%d,%d,%d,%d,%d,%d,%d,%d,"+
-		"%s,%s,"+
-		"%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t",
-		data.Time,
-		data.EngineRPM,
-		data.CoolantTemp,
-		data.AmbientTemp,
-		data.IntakeAirTemp,
-		data.FuelTemp,
-		data.ManifoldAbsolutePressure,
-		data.BatteryVoltage,
-		data.ThrottlePotSensor,
-		data.IdleSwitch,
-		data.AirconSwitch,
-		data.ParkNeutralSwitch,
-		data.DTC0,
-		data.IdleSetPoint,
-		data.IdleHot,
-		data.Uk8011,
-		data.IACPosition,
-		data.IdleSpeedDeviation,
-		data.IgnitionAdvanceOffset80,
-		data.IgnitionAdvance,
-		data.CoilTime,
-		data.CrankshaftPositionSensor,
-		data.Uk801a,
-		data.Uk801b,
-		data.IgnitionSwitch,
-		data.ThrottleAngle,
-		data.Uk7d03,
-		data.AirFuelRatio,
-		data.DTC2,
-		data.LambdaVoltage,
-		data.LambdaFrequency,
-		data.LambdaDutycycle,
-		data.LambdaStatus,
-		data.ClosedLoop,
-		data.LongTermFuelTrim,
-		data.ShortTermFuelTrim,
-		data.CarbonCanisterPurgeValve,
-		data.DTC3,
-		data.IdleBasePosition,
-		data.Uk7d10,
-		data.DTC4,
-		data.IgnitionAdvanceOffset7d,
-		data.IdleSpeedOffset,
-		data.Uk7d14,
-		data.Uk7d15,
-		data.DTC5,
-		data.Uk7d17,
-		data.Uk7d18,
-		data.Uk7d19,
-		data.Uk7d1a,
-		data.Uk7d1b,
-		data.Uk7d1c,
-		data.Uk7d1d,
-		data.Uk7d1e,
-		data.JackCount,
+func getMemsDataHeader(units UnitSystem) string {
+	return "#time," + getSchemaCSVHeader(units) + ",0x7d_raw,0x80_raw," + faultCodesColumn
+}
+
+func convertMemsDataToCSVData(data MemsData, units UnitSystem) []string {
+	csvData := []string{data.Time}
+	csvData = append(csvData, getSchemaCSVData(data, units)...)
+
+	diagnostics := fmt.Sprintf("%s,%s,%s,"+
+		"%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%t,%s",
 		strings.ToUpper(data.Dataframe7d),
 		strings.ToUpper(data.Dataframe80),
+		faultCodesToCSV(data.FaultCodes),
 		data.Analytics.IsEngineRunning,
 		data.Analytics.IsEngineWarming,
 		data.Analytics.IsAtOperatingTemp,
 		data.Analytics.IsEngineIdle,
 		data.Analytics.IsEngineIdleFault,
 		data.Analytics.IdleSpeedFault,
-		false, //data.Analytics.IdleErrorFault,
+		data.Analytics.IdleErrorFault,
 		data.Analytics.IdleHotFault,
-		false, //data.Analytics.IsCruising,
+		data.Analytics.IsCruising,
 		data.Analytics.IsClosedLoop,
-		false, //data.Analytics.IsClosedLoopExpected,
-		false, //data.Analytics.ClosedLoopFault,
+		data.Analytics.IsClosedLoopExpected,
+		data.Analytics.ClosedLoopFault,
 		data.Analytics.IsThrottleActive,
 		data.Analytics.MapFault,
 		data.Analytics.VacuumFault,
 		data.Analytics.IdleAirControlFault,
-		false, //data.Analytics.IdleAirControlRangeFault,
+		data.Analytics.IdleAirControlRangeFault,
 		data.Analytics.IdleAirControlJackFault,
 		data.Analytics.O2SystemFault,
 		data.Analytics.LambdaRangeFault,
@@ -222,7 +173,9 @@ func convertMemsDataToCSVData(data MemsData) []string {
 		data.Analytics.ThermostatFault,
 		data.Analytics.CrankshaftSensorFault,
 		data.Analytics.CoilFault,
+		// the profile name is a single csv field
+		strings.ReplaceAll(data.Analytics.Profile, ",", " "),
 	)
 
-	return strings.Split(s, ",")
+	return append(csvData, strings.Split(diagnostics, ",")...)
 }
@@ -0,0 +1,135 @@
+package rosco
+
+import (
+	"math/bits"
+	"strings"
+)
+
+// FaultCode describes a diagnostic trouble code decoded from the DTC bytes
+type FaultCode struct {
+	// Code is the documented ECU fault code number, 0 if the fault has no documented number
+	Code        int    `json:"Code"`
+	Name        string `json:"Name"`
+	Source      string `json:"Source"`
+	Location    string `json:"Location"`
+	Bit         int    `json:"Bit"`
+	Description string `json:"Description"`
+	mask        byte
+}
+
+const (
+	// DTC bytes reading 0xFF are not supported by the ECU and are not decoded
+	unsupportedDTCValue = 0xff
+	faultCodeSeparator  = "|"
+)
+
+var faultCodeDefinitions = []FaultCode{
+	// 80x0d DTC0 Fault Codes
+	newFaultCode(1, "CoolantTempSensor", "DTC0", "80x0D", CoolantSensorFaultCode, "coolant temperature sensor circuit fault"),
+	newFaultCode(2, "IntakeAirTempSensor", "DTC0", "80x0D", AirSensorFaultCode, "inlet air temperature sensor circuit fault"),
+	newFaultCode(0, "TurboOverboost", "DTC0", "80x0D", TurboOverboostFaultCode, "turbo overboost detected"),
+	newFaultCode(0, "AmbientTempSensor", "DTC0", "80x0D", AmbientTempSensorFaultCode, "ambient temperature sensor circuit fault"),
+	newFaultCode(0, "FuelRailTempSensor", "DTC0", "80x0D", FuelRailTempFaultCode, "fuel rail temperature sensor circuit fault"),
+	newFaultCode(0, "Knock", "DTC0", "80x0D", KnockDetectedFaultCode, "knock detected"),
+
+	// 80x0e DTC1 Fault Codes
+	newFaultCode(0, "CoolantTempGauge", "DTC1", "80x0E", CoolantTempGaugeFaultCode, "coolant temperature gauge circuit fault"),
+	newFaultCode(10, "FuelPumpCircuit", "DTC1", "80x0E", FuelPumpFaultCode, "fuel pump relay circuit fault"),
+	newFaultCode(0, "AirConClutch", "DTC1", "80x0E", AirConFaultCode, "air conditioning clutch relay circuit fault"),
+	newFaultCode(0, "PurgeValve", "DTC1", "80x0E", PurgeValveFaultCode, "carbon canister purge valve circuit fault"),
+	newFaultCode(0, "MAPSensor", "DTC1", "80x0E", MAPSensorFaultCode, "manifold absolute pressure sensor fault"),
+	newFaultCode(0, "BoostValve", "DTC1", "80x0E", BoostValveFaultCode, "boost control valve circuit fault"),
+	newFaultCode(16, "ThrottlePotCircuit", "DTC1", "80x0E", ThrottlePotFaultCode, "throttle potentiometer circuit fault"),
+
+	// 7dx05 DTC2 Fault Codes
+	newFaultCode(0, "LambdaHeaterRelay", "DTC2", "7dx05", LambdaHeaterRelay, "lambda sensor heater relay circuit fault"),
+	newFaultCode(0, "SecondaryTriggerSync", "DTC2", "7dx05", SecondaryTriggerSync, "secondary trigger synchronisation lost"),
+	newFaultCode(0, "Fan1Control", "DTC2", "7dx05", Fan1Control, "radiator fan 1 control circuit fault"),
+	// bit 6 (Fan2Control) is not decoded, it reads 1 throughout the nofaults recording with the fans off
+	// and 0 on the full warm-up recording, so it is part of the resting value of some ECUs
+
+	// 7dx0E DTC3 Fault Codes
+	newFaultCode(0, "PrimaryTriggerSync", "DTC3", "7dx0E", PrimaryTriggerSync, "primary trigger synchronisation lost"),
+}
+
+func newFaultCode(code int, name string, source string, location string, mask byte, description string) FaultCode {
+	return FaultCode{
+		Code:        code,
+		Name:        name,
+		Source:      source,
+		Location:    location,
+		Bit:         bits.TrailingZeros8(mask),
+		Description: description,
+		mask:        mask,
+	}
+}
+
+// GetFaultCodeDefinitions returns all the fault codes the decoder recognises
+func GetFaultCodeDefinitions() []FaultCode {
+	definitions := make([]FaultCode, len(faultCodeDefinitions))
+	copy(definitions, faultCodeDefinitions)
+
+	return definitions
+}
+
+// DecodeFaultCodes decodes the DTC0 - DTC5 bytes into the list of active faults
+// the fan 2 control bit of DTC2 is deliberately ignored, some ECUs set it at rest with the fans off
+func DecodeFaultCodes(data MemsData) []FaultCode {
+	dtc := map[string]uint8{
+		"DTC0": data.DTC0,
+		"DTC1": data.DTC1,
+		"DTC2": data.DTC2,
+		"DTC3": data.DTC3,
+		"DTC4": data.DTC4,
+		"DTC5": data.DTC5,
+	}
+
+	faults := []FaultCode{}
+
+	for _, definition := range faultCodeDefinitions {
+		value := dtc[definition.Source]
+
+		if value != unsupportedDTCValue && value&definition.mask != 0 {
+			faults = append(faults, definition)
+		}
+	}
+
+	return faults
+}
+
+// HasFaultCode returns true if the named fault is in the list of faults
+func HasFaultCode(faults []FaultCode, name string) bool {
+	for _, fault := range faults {
+		if fault.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// formats the fault names for the csv log, the names are separated with '|'
+func faultCodesToCSV(faults []FaultCode) string {
+	var names []string
+
+	for _, fault := range faults {
+		names = append(names, fault.Name)
+	}
+
+	return strings.Join(names, faultCodeSeparator)
+}
+
+// parses the fault names in the csv log back into the fault codes, unknown names are ignored
+func faultCodesFromCSV(s string) []FaultCode {
+	faults := []FaultCode{}
+
+	for _, name := range strings.Split(s, faultCodeSeparator) {
+		for _, definition := range faultCodeDefinitions {
+			if definition.Name == strings.TrimSpace(name) {
+				faults = append(faults, definition)
+			}
+		}
+	}
+
+	return faults
+}
@@ -0,0 +1,113 @@
+package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"strings"
+	"testing"
+)
+
+func Test_faultcodes_DecodeNoFaults(t *testing.T) {
+	data := MemsData{
+		DTC3: unsupportedDTCValue,
+		DTC4: unsupportedDTCValue,
+		DTC5: unsupportedDTCValue,
+	}
+
+	faults := DecodeFaultCodes(data)
+	then.AssertThat(t, len(faults), is.EqualTo(0))
+}
+
+func Test_faultcodes_DecodeDTC0andDTC1(t *testing.T) {
+	data := MemsData{
+		DTC0: CoolantSensorFaultCode | KnockDetectedFaultCode,
+		DTC1: FuelPumpFaultCode | ThrottlePotFaultCode,
+	}
+
+	faults := DecodeFaultCodes(data)
+	then.AssertThat(t, len(faults), is.EqualTo(4))
+
+	then.AssertThat(t, faults[0].Name, is.EqualTo("CoolantTempSensor"))
+	then.AssertThat(t, faults[0].Code, is.EqualTo(1))
+	then.AssertThat(t, faults[0].Source, is.EqualTo("DTC0"))
+	then.AssertThat(t, faults[0].Location, is.EqualTo("80x0D"))
+	then.AssertThat(t, faults[0].Bit, is.EqualTo(0))
+
+	then.AssertThat(t, faults[1].Name, is.EqualTo("Knock"))
+	then.AssertThat(t, faults[1].Bit, is.EqualTo(6))
+
+	then.AssertThat(t, faults[2].Name, is.EqualTo("FuelPumpCircuit"))
+	then.AssertThat(t, faults[2].Code, is.EqualTo(10))
+
+	then.AssertThat(t, faults[3].Name, is.EqualTo("ThrottlePotCircuit"))
+	then.AssertThat(t, faults[3].Code, is.EqualTo(16))
+}
+
+func Test_faultcodes_DecodeDTC2andDTC3(t *testing.T) {
+	data := MemsData{
+		DTC2: LambdaHeaterRelay | Fan2Control,
+		DTC3: PrimaryTriggerSync,
+	}
+
+	faults := DecodeFaultCodes(data)
+	then.AssertThat(t, len(faults), is.EqualTo(2))
+	then.AssertThat(t, HasFaultCode(faults, "LambdaHeaterRelay"), is.True())
+	// the fan 2 bit is part of the resting value
+	then.AssertThat(t, HasFaultCode(faults, "Fan2Control"), is.False())
+	then.AssertThat(t, HasFaultCode(faults, "PrimaryTriggerSync"), is.True())
+	then.AssertThat(t, HasFaultCode(faults, "Fan1Control"), is.False())
+}
+
+func Test_faultcodes_UnsupportedDTCIgnored(t *testing.T) {
+	// unused dtc bytes read 255 and should not report every fault
+	data := MemsData{
+		DTC0: unsupportedDTCValue,
+		DTC3: unsupportedDTCValue,
+	}
+
+	faults := DecodeFaultCodes(data)
+	then.AssertThat(t, len(faults), is.EqualTo(0))
+}
+
+func Test_faultcodes_GetFaultCodeDefinitions(t *testing.T) {
+	definitions := GetFaultCodeDefinitions()
+	then.AssertThat(t, len(definitions), is.EqualTo(17))
+}
+
+func Test_faultcodes_faultCodesToCSV(t *testing.T) {
+	faults := DecodeFaultCodes(MemsData{DTC0: CoolantSensorFaultCode | AirSensorFaultCode})
+	then.AssertThat(t, faultCodesToCSV(faults), is.EqualTo("CoolantTempSensor|IntakeAirTempSensor"))
+	then.AssertThat(t, faultCodesToCSV([]FaultCode{}), is.EqualTo(""))
+}
+
+func Test_faultcodes_createMemsDataframe(t *testing.T) {
+	r := NewECUReaderInstance()
+
+	data := r.createMemsDataframe(responseMap80WithDTC(FuelPumpFaultCode), createResponseMap()["7D"])
+	then.AssertThat(t, data.FuelPumpCircuitFault, is.True())
+	then.AssertThat(t, HasFaultCode(data.FaultCodes, "FuelPumpCircuit"), is.True())
+
+	// the decoded faults are logged in a single column
+	header := strings.Split(MemsDataHeader+","+DiagnosticsCSVHeader, ",")
+	then.AssertThat(t, len(convertMemsDataToCSVData(data, MetricUnits)), is.EqualTo(len(header)))
+}
+
+func responseMap80WithDTC(dtc1 byte) []byte {
+	data := append([]byte{}, createResponseMap()["80"]...)
+	data[15] = dtc1
+
+	return data
+}
+
+func Test_faultcodes_RecordingsWithNoFaults(t *testing.T) {
+	for _, filename := range []string{"testdata/nofaults.fcr", "testdata/full-warmup-working-lambda.fcr"} {
+		for _, data := range getRecordedDataframes(t, filename) {
+			// misread dataframes are rejected by the analysis
+			if data.DTC5 != expectedDTC5Value {
+				continue
+			}
+
+			then.AssertThat(t, len(DecodeFaultCodes(data)), is.EqualTo(0).Reason(filename+" "+data.Time))
+		}
+	}
+}
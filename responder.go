@@ -31,6 +31,7 @@ type Playbook struct {
 	Count             int
 	servedDataframe7d bool
 	servedDataframe80 bool
+	servedTimestamp   time.Time
 }
 
 type ScenarioDetails struct {
@@ -89,6 +90,13 @@ func (responder *ScenarioResponder) LoadScenario(filepath string) error {
 				// attempt to convert to time
 				timestamp, err = ConvertTimeFieldToDate(responder.RawData[i].Time)
 
+				// times recorded without a date are placed on the scenario date
+				if i > 0 {
+					timestamp = getScenarioTimestamp(responder.Description.Date, timestamp, responder.Playbook.Responses[i-1].Timestamp)
+				} else {
+					timestamp = getScenarioTimestamp(responder.Description.Date, timestamp, time.Time{})
+				}
+
 				pr.Timestamp = timestamp
 				pr.Dataframe7d = responder.convertHexStringToByteArray(responder.RawData[i].Dataframe7d)
 				pr.Dataframe80 = responder.convertHexStringToByteArray(responder.RawData[i].Dataframe80)
@@ -106,23 +114,55 @@ func ConvertTimeFieldToDate(timeField string) (time.Time, error) {
 	var timestamp time.Time
 
 	// attempt to convert to time
-	if timestamp, err = time.Parse("2006-01-02 15:04:05.000", timeField); err != nil {
-		if timestamp, err = time.Parse("15:04:05.000", timeField); err != nil {
-			if timestamp, err = time.Parse("15:04:05", timeField); err != nil {
-				if timestamp, err = time.Parse("04:05.0", timeField); err != nil {
-					log.Warnf("unable to parse timestamp %s, defaulting to current time", timeField)
-					timestamp = time.Now()
-				}
-			}
-		}
+	if timestamp, err = parseTimeField(timeField); err != nil {
+		log.Warnf("unable to parse timestamp %s, defaulting to current time", timeField)
+		timestamp = time.Now()
 	}
 
 	return timestamp, err
 }
 
+// scenarios recorded without a date are replayed on this date, so every replay has the same timestamps
+var undatedScenarioDate = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// getScenarioTimestamp adds the scenario date to a time recorded without a date, scenarios without a date
+// use the undated scenario date. Times earlier than the previous dataframe by more than 12 hours have passed
+// midnight and move to the next day
+func getScenarioTimestamp(date time.Time, timestamp time.Time, previous time.Time) time.Time {
+	if timestamp.Year() > 0 {
+		return timestamp
+	}
+
+	if date.Year() <= 0 {
+		date = undatedScenarioDate
+	}
+
+	timestamp = time.Date(date.Year(), date.Month(), date.Day(), timestamp.Hour(), timestamp.Minute(), timestamp.Second(), timestamp.Nanosecond(), date.Location())
+
+	for !previous.IsZero() && previous.Sub(timestamp) > time.Hour*12 {
+		timestamp = timestamp.AddDate(0, 0, 1)
+	}
+
+	return timestamp
+}
+
+// GetTimestamp returns the recorded time of the dataframes last served from the playbook
+func (responder *ScenarioResponder) GetTimestamp() time.Time {
+	if responder.Playbook.servedTimestamp.IsZero() && len(responder.Playbook.Responses) > 0 {
+		return responder.Playbook.Responses[responder.Playbook.Position].Timestamp
+	}
+
+	return responder.Playbook.servedTimestamp
+}
+
 // MovePositionToLocation finds and moves the position in the playbook to
 // the time location specified
 func (responder *ScenarioResponder) MovePositionToLocation(timelocation time.Time) {
+	if len(responder.Playbook.Responses) > 0 {
+		// time locations without a date are on the date of the scenario
+		timelocation = getScenarioTimestamp(responder.Playbook.Responses[0].Timestamp, timelocation, responder.Playbook.Responses[0].Timestamp)
+	}
+
 	for i, r := range responder.Playbook.Responses {
 		if timelocation.Before(r.Timestamp) {
 			log.Infof("moving position from %v to %v", responder.Playbook.Position, i)
@@ -186,18 +226,19 @@ func (responder *ScenarioResponder) GetECUResponse(cmd []byte) []byte {
 	if responder.isDataframeRequest(command) {
 		// get the position of the next response
 		position := responder.Playbook.Position
+		responder.Playbook.servedTimestamp = responder.Playbook.Responses[position].Timestamp
 
 		if command == "7D" {
 			data = responder.Playbook.Responses[position].Dataframe7d
-			// truncate to the right size
-			data = data[:33]
+			// truncate to the right size, short frames are served as recorded
+			data = truncateDataframe(data, frame7dSize+1)
 			responder.Playbook.servedDataframe7d = true
 		}
 
 		if command == "80" {
 			data = responder.Playbook.Responses[position].Dataframe80
-			// truncate to the right size
-			data = data[:29]
+			// truncate to the right size, short frames are served as recorded
+			data = truncateDataframe(data, frame80Size+1)
 			responder.Playbook.servedDataframe80 = true
 		}
 
@@ -236,3 +277,11 @@ func (responder *ScenarioResponder) convertHexStringToByteArray(response string)
 
 	return data
 }
+
+func truncateDataframe(data []byte, size int) []byte {
+	if len(data) > size {
+		return data[:size]
+	}
+
+	return data
+}
@@ -1,9 +1,12 @@
 package rosco
 
 import (
+	"encoding/csv"
+	"encoding/hex"
 	"fmt"
 	"github.com/gocarina/gocsv"
 	log "github.com/sirupsen/logrus"
+	"io"
 	"os"
 	"path/filepath"
 	"time"
@@ -34,12 +37,19 @@ func (r *ScenarioCSVReader) Load() (ResponderFileInfo, error) {
 		} else {
 			log.Infof("successfully parsed %s, %d records read", r.filepath, len(data))
 
-			if file, err := os.Stat(r.file.Name()); err == nil {
-				date = file.ModTime()
-			} else {
-				date = time.Now()
-				// don't report this as an error
-				err = nil
+			if hasMissingDataframes(data) {
+				if err = r.encodeMissingDataframes(data); err != nil {
+					return r.info, err
+				}
+			}
+
+			// the scenario is dated from the first recorded time, logs recorded without a date are
+			// placed on the undated scenario date
+			date = undatedScenarioDate
+			if len(data) > 0 {
+				if first, err := ConvertTimeFieldToDate(data[0].Time); err == nil {
+					date = getScenarioTimestamp(undatedScenarioDate, first, time.Time{})
+				}
 			}
 
 			r.info = ResponderFileInfo{
@@ -64,6 +74,57 @@ func (r *ScenarioCSVReader) Load() (ResponderFileInfo, error) {
 	return r.info, err
 }
 
+// encodeMissingDataframes recreates the raw dataframes from the decoded columns
+// for log files created without the 0x7d_raw and 0x80_raw columns
+func (r *ScenarioCSVReader) encodeMissingDataframes(data []*RawData) error {
+	var err error
+	var records [][]string
+
+	if _, err = r.file.Seek(0, io.SeekStart); err != nil {
+		log.Errorf("error rewinding csv file %s (%s)", r.filepath, err)
+		return err
+	}
+
+	reader := csv.NewReader(r.file)
+	reader.FieldsPerRecord = -1
+
+	if records, err = reader.ReadAll(); err != nil {
+		err = fmt.Errorf("error reading csv file %s (%s)", r.filepath, err)
+		log.Errorf("%s", err)
+		return err
+	}
+
+	if len(records) != len(data)+1 {
+		err = fmt.Errorf("unable to encode dataframes for %s, record count mismatch", r.filepath)
+		log.Errorf("%s", err)
+		return err
+	}
+
+	header := records[0]
+
+	for i, raw := range data {
+		if raw.Dataframe80 == "" || raw.Dataframe7d == "" {
+			d80, d7d := EncodeDataframes(parseSchemaCSVData(header, records[i+1]))
+			raw.Dataframe80 = hex.EncodeToString(d80)
+			raw.Dataframe7d = hex.EncodeToString(d7d)
+		}
+	}
+
+	log.Infof("encoded dataframes from the decoded columns in %s", r.filepath)
+
+	return err
+}
+
+func hasMissingDataframes(data []*RawData) bool {
+	for _, raw := range data {
+		if raw.Dataframe80 == "" || raw.Dataframe7d == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (r *ScenarioCSVReader) openFile() error {
 	var err error
 
@@ -1 +1,84 @@
 package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"testing"
+)
+
+func Test_csvReader_LoadWithRawDataframes(t *testing.T) {
+	r := NewScenarioCSVReader("testdata/nofaults.csv")
+
+	info, err := r.Load()
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, info.Description.Count, is.EqualTo(338))
+	then.AssertThat(t, hasMissingDataframes(info.Data), is.False())
+}
+
+func Test_csvReader_LoadWithoutRawDataframes(t *testing.T) {
+	raw, err := NewScenarioCSVReader("testdata/nofaults.csv").Load()
+	then.AssertThat(t, err, is.Nil())
+
+	decoded, err := NewScenarioCSVReader("testdata/nofaults-decoded.csv").Load()
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, decoded.Description.Count, is.EqualTo(raw.Description.Count))
+	then.AssertThat(t, hasMissingDataframes(decoded.Data), is.False())
+
+	responder := NewResponder()
+
+	for i := range decoded.Data {
+		expected := decodeDataframes(responder.convertHexStringToByteArray(raw.Data[i].Dataframe80), responder.convertHexStringToByteArray(raw.Data[i].Dataframe7d))
+		actual := decodeDataframes(responder.convertHexStringToByteArray(decoded.Data[i].Dataframe80), responder.convertHexStringToByteArray(decoded.Data[i].Dataframe7d))
+
+		then.AssertThat(t, actual.EngineRPM, is.EqualTo(expected.EngineRPM))
+		then.AssertThat(t, actual.CoolantTemp, is.EqualTo(expected.CoolantTemp))
+		then.AssertThat(t, actual.ManifoldAbsolutePressure, is.EqualTo(expected.ManifoldAbsolutePressure))
+		then.AssertThat(t, actual.BatteryVoltage, is.EqualTo(expected.BatteryVoltage))
+		then.AssertThat(t, actual.ThrottleAngle, is.EqualTo(expected.ThrottleAngle))
+		then.AssertThat(t, actual.LambdaVoltage, is.EqualTo(expected.LambdaVoltage))
+		then.AssertThat(t, actual.CoilTime, is.EqualTo(expected.CoilTime))
+		then.AssertThat(t, actual.IdleBasePosition, is.EqualTo(expected.IdleBasePosition))
+	}
+}
+
+func Test_csvReader_PlaybackWithoutRawDataframes(t *testing.T) {
+	r := NewECUReaderInstance()
+	r.ecuReader = NewECUReader("testdata/nofaults-decoded.csv")
+	_, _ = r.connectToECU()
+
+	data, err := r.GetDataframes()
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, data.CoolantTemp, is.EqualTo(56))
+	then.AssertThat(t, data.BatteryVoltage, is.EqualTo(float32(12)))
+}
+
+func Test_csvReader_LoadImperialLog(t *testing.T) {
+	logged := MemsData{
+		Time:                     "12:00:00.000",
+		EngineRPM:                850,
+		CoolantTemp:              88,
+		IntakeAirTemp:            30,
+		ManifoldAbsolutePressure: 35,
+		BatteryVoltage:           13.6,
+		DTC1:                     FuelPumpFaultCode,
+	}
+	logged.FaultCodes = DecodeFaultCodes(logged)
+
+	d := NewMemsDataLoggerWithUnits("testlogs", "IMPERIAL", ImperialUnits)
+	d.WriteMemsDataToFile(logged)
+	d.Close()
+
+	info, err := NewScenarioCSVReader(d.Filepath).Load()
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, info.Description.Count, is.EqualTo(1))
+
+	responder := NewResponder()
+	data := decodeDataframes(responder.convertHexStringToByteArray(info.Data[0].Dataframe80), responder.convertHexStringToByteArray(info.Data[0].Dataframe7d))
+
+	then.AssertThat(t, data.EngineRPM, is.EqualTo(logged.EngineRPM))
+	then.AssertThat(t, data.CoolantTemp, is.EqualTo(logged.CoolantTemp))
+	then.AssertThat(t, data.IntakeAirTemp, is.EqualTo(logged.IntakeAirTemp))
+	then.AssertThat(t, data.ManifoldAbsolutePressure, is.EqualTo(logged.ManifoldAbsolutePressure))
+	then.AssertThat(t, data.BatteryVoltage, is.EqualTo(logged.BatteryVoltage))
+	then.AssertThat(t, data.DTC1, is.EqualTo(logged.DTC1))
+}
@@ -6,6 +6,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
 	"os"
+	"time"
 )
 
 type ScenarioFCRReader struct {
@@ -44,6 +45,11 @@ func (r *ScenarioFCRReader) Load() (ResponderFileInfo, error) {
 						},
 					}
 
+					// scenarios converted from logs without a date are placed on the undated scenario date
+					if fcrData.Date.Year() <= 0 {
+						r.info.Description.Date = getScenarioTimestamp(undatedScenarioDate, fcrData.Date, time.Time{})
+					}
+
 					if len(data) > 0 {
 						r.info.Description.Duration, err = getScenarioDuration(fcrData.RawData[0].Time, fcrData.RawData[r.info.Description.Count-1].Time)
 					}
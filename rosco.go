@@ -6,7 +6,6 @@ import (
 	"encoding/hex"
 	"fmt"
 	log "github.com/sirupsen/logrus"
-	"math"
 	"reflect"
 	"strings"
 	"time"
@@ -19,6 +18,9 @@ type ECUReaderInstance struct {
 	Status      *ECUStatus
 	Diagnostics *DataframeAnalysis
 	Responder   *ScenarioResponder
+	Journal     *ECUJournal
+	// Units the session data is exported and reported in
+	Units UnitSystem
 }
 
 // NewECUReaderInstance creates a new mems structure
@@ -26,6 +28,8 @@ func NewECUReaderInstance() *ECUReaderInstance {
 	m := &ECUReaderInstance{}
 	m.Status = &ECUStatus{}
 	m.Diagnostics = NewDataframeAnalysis(20)
+	m.Journal = NewECUJournal()
+	m.Units = MetricUnits
 	m.resetStatus()
 
 	return m
@@ -37,10 +41,6 @@ func (ecu *ECUReaderInstance) ConnectAndInitialiseECU(port string) (bool, error)
 
 	ecu.ecuReader = NewECUReader(port)
 
-	if reflect.TypeOf(ecu.ecuReader) == reflect.TypeOf(&ScenarioReader{}) {
-		ecu.Responder = ecu.ecuReader.(*ScenarioReader).Responder
-	}
-
 	if connected, err = ecu.connectToECU(); err == nil {
 		if connected {
 			ecu.Status.Connected = true
@@ -80,14 +80,13 @@ func (ecu *ECUReaderInstance) Disconnect() error {
 func (ecu *ECUReaderInstance) ResetDiagnostics() {
 	// update the status
 	log.Info("resetting ecu diagnostics")
-	ecu.Diagnostics = NewDataframeAnalysis(20)
+	ecu.Diagnostics = NewDataframeAnalysisWithProfile(20, ecu.Diagnostics.profile)
+	ecu.setClock()
 }
 
 func (ecu *ECUReaderInstance) GetDataframes() (MemsData, error) {
 	var err error
 	var d80, d7d []byte
-	var df80 DataFrame80
-	var df7d DataFrame7d
 
 	df := MemsData{}
 
@@ -95,30 +94,26 @@ func (ecu *ECUReaderInstance) GetDataframes() (MemsData, error) {
 	log.Info("getting 0x7d and 0x80 dataframes")
 
 	if d80, d7d, err = ecu.readRawDataFrames(); err == nil {
-		// create the dataframes from the raw binary df
-		if df80, err = ecu.createDataframe80(d80); err == nil {
-			if df7d, err = ecu.createDataframe7D(d7d); err == nil {
+		// validate and create the dataframes from the raw binary df, invalid frames are discarded
+		if _, err = ecu.createDataframe80(d80); err == nil {
+			if _, err = ecu.createDataframe7D(d7d); err == nil {
 				// build the Mems Dataframe using the raw df and applying the relevant adjustments and calculations
-				df = ecu.createMemsDataframe(df80, df7d)
+				df = ecu.createMemsDataframe(d80, d7d)
 				// include the raw df converted into string format
 				df.Dataframe80 = hex.EncodeToString(d80)
-				if len(df.Dataframe80) != 58 {
-					log.Warnf("dataframe 0x80 length exception, expected 29 (%s)", df.Dataframe80)
-				}
-
 				df.Dataframe7d = hex.EncodeToString(d7d)
-				if len(df.Dataframe7d) != 66 {
-					log.Warnf("dataframe 0x7D length exception, expected 33 (%s)", df.Dataframe7d)
-				}
+
+				// calculate the derived metrics before the analysis so they are available to the analysis and log
+				df = ecu.Diagnostics.DeriveMetrics(df)
 
 				ecu.Diagnostics.Analyse(df)
 				df.Analytics = ecu.Diagnostics.Analysis
 
 				log.Infof("generated ecu df from dataframe (%+v)", df)
+
+				ecu.writeToLog(df)
 			}
 		}
-
-		ecu.writeToLog(df)
 	}
 
 	return df, err
@@ -126,60 +121,47 @@ func (ecu *ECUReaderInstance) GetDataframes() (MemsData, error) {
 }
 
 func (ecu *ECUReaderInstance) connectToECU() (bool, error) {
-	return ecu.ecuReader.Connect()
+	connected, err := ecu.ecuReader.Connect()
+
+	// the scenario responder is created when the scenario reader connects
+	if reflect.TypeOf(ecu.ecuReader) == reflect.TypeOf(&ScenarioReader{}) {
+		ecu.Responder = ecu.ecuReader.(*ScenarioReader).Responder
+	} else {
+		ecu.Responder = nil
+	}
+
+	ecu.setClock()
+
+	return connected, err
 }
 
-func (ecu *ECUReaderInstance) createMemsDataframe(df80 DataFrame80, df7d DataFrame7d) MemsData {
-	t := time.Now()
-
-	memsdata := MemsData{
-		Time:                     t.Format("2006-01-02 15:04:05.000"),
-		EngineRPM:                int(df80.EngineRpm),
-		CoolantTemp:              int(df80.CoolantTemp) - 55,
-		AmbientTemp:              int(df80.AmbientTemp) - 55,
-		IntakeAirTemp:            int(df80.IntakeAirTemp) - 55,
-		FuelTemp:                 int(df80.FuelTemp) - 55,
-		ManifoldAbsolutePressure: float32(df80.ManifoldAbsolutePressure),
-		BatteryVoltage:           float32(df80.BatteryVoltage) / 10,
-		ThrottlePotSensor:        roundTo2DecimalPoints(float32(df80.ThrottlePotSensor) * 0.02),
-		IdleSwitch:               bool(df80.IdleSwitch&IdleSwitchActive != 0),
-		AirconSwitch:             bool(df80.AirconSwitch != 0),
-		ParkNeutralSwitch:        bool(df80.ParkNeutralSwitch != 0),
-		DTC0:                     df80.Dtc0,
-		DTC1:                     df80.Dtc1,
-		IdleSetPoint:             int(df80.IdleSetPoint),
-		IdleHot:                  int(df80.IdleHot), // was (hotidle - 35) but don't understand why this offset
-		IACPosition:              int(df80.IacPosition),
-		IdleSpeedDeviation:       int(df80.IdleSpeedDeviation),
-		IgnitionAdvanceOffset80:  int(df80.IgnitionAdvanceOffset80),
-		IgnitionAdvance:          (float32(df80.IgnitionAdvance) / 2) - 24,
-		CoilTime:                 roundTo2DecimalPoints(float32(df80.CoilTime) * 0.002),
-		CrankshaftPositionSensor: int(df80.CrankshaftPositionSensor),
-		CoolantTempSensorFault:   bool(df80.Dtc0&CoolantSensorFaultCode != 0),
-		IntakeAirTempSensorFault: bool(df80.Dtc0&AirSensorFaultCode != 0),
-		FuelPumpCircuitFault:     bool(df80.Dtc1&FuelPumpFaultCode != 0),
-		ThrottlePotCircuitFault:  bool(df80.Dtc1&ThrottlePotFaultCode != 0),
-		IgnitionSwitch:           bool(df7d.IgnitionSwitch != 0),
-		ThrottleAngle:            int(math.Round(float64(df7d.ThrottleAngle) * 6 / 10)),
-		AirFuelRatio:             float32(df7d.AirFuelRatio) / 10,
-		DTC2:                     df7d.Dtc2,
-		LambdaVoltage:            int(df7d.LambdaVoltage) * 5,
-		LambdaFrequency:          int(df7d.LambdaFrequency),
-		LambdaDutycycle:          int(df7d.LambdaDutyCycle),
-		LambdaStatus:             int(df7d.LambdaStatus),
-		ClosedLoop:               bool(df7d.LoopIndicator != 0),
-		LongTermFuelTrim:         int(df7d.LongTermFuelTrim) - 128,
-		ShortTermFuelTrim:        int(df7d.ShortTermFuelTrim) - 100,
-		FuelTrimCorrection:       int(df7d.ShortTermFuelTrim) - 100,
-		CarbonCanisterPurgeValve: int(df7d.CarbonCanisterPurgeValve),
-		DTC3:                     df7d.Dtc3,
-		IdleBasePosition:         int(df7d.IdleBasePos),
-		DTC4:                     df7d.Dtc4,
-		IgnitionAdvanceOffset7d:  int(df7d.IgnitionAdvanceOffset7d) - 48,
-		IdleSpeedOffset:          int(df7d.IdleSpeedOffset), // - 128) * 25,
-		DTC5:                     df7d.Dtc5,
-		JackCount:                int(df7d.JackCount),
+// setClock uses the recorded time of the scenario when replaying, otherwise the system clock
+func (ecu *ECUReaderInstance) setClock() {
+	if ecu.Responder != nil {
+		ecu.Diagnostics.SetClock(ecu.Responder.GetTimestamp)
+	} else {
+		ecu.Diagnostics.SetClock(time.Now)
 	}
+}
+
+func (ecu *ECUReaderInstance) createMemsDataframe(d80 []byte, d7d []byte) MemsData {
+	memsdata := decodeMemsData(d80, d7d)
+	// timestamp the dataframe with the session clock
+	return ecu.Diagnostics.StampFrame(memsdata)
+}
+
+// decodeMemsData decodes the channels and the fault codes from the dataframes
+func decodeMemsData(d80 []byte, d7d []byte) MemsData {
+	// decode the channels using the dataframe schema
+	memsdata := decodeDataframes(d80, d7d)
+
+	memsdata.CoolantTempSensorFault = bool(memsdata.DTC0&CoolantSensorFaultCode != 0)
+	memsdata.IntakeAirTempSensorFault = bool(memsdata.DTC0&AirSensorFaultCode != 0)
+	memsdata.FuelPumpCircuitFault = bool(memsdata.DTC1&FuelPumpFaultCode != 0)
+	memsdata.ThrottlePotCircuitFault = bool(memsdata.DTC1&ThrottlePotFaultCode != 0)
+
+	// decode the fault codes from the dtc bytes
+	memsdata.FaultCodes = DecodeFaultCodes(memsdata)
 
 	return memsdata
 }
@@ -188,11 +170,10 @@ func (ecu *ECUReaderInstance) createDataframe7D(d7d []byte) (DataFrame7d, error)
 	var err error
 	var df7d DataFrame7d
 
-	defer func() {
-		if err := recover(); err != nil {
-			log.Warnf("dataframe conversion panic occurred %s", err)
-		}
-	}()
+	if err = ValidateDataframe(frame7d, d7d); err != nil {
+		log.Errorf("invalid dataframe x7d (%s)", err)
+		return df7d, err
+	}
 
 	// populate the DataFrame structure for command 0x7d
 	byteReader := bytes.NewReader(d7d)
@@ -200,7 +181,7 @@ func (ecu *ECUReaderInstance) createDataframe7D(d7d []byte) (DataFrame7d, error)
 	if err = binary.Read(byteReader, binary.BigEndian, &df7d); err != nil {
 		log.Errorf("error reading dataframe x7d (%s)", err)
 	} else {
-		log.Infof("dataframe x7d received (data: %X dataframe: %+v)", byteReader, df7d)
+		log.Infof("dataframe x7d received (data: %X dataframe: %+v)", d7d, df7d)
 	}
 
 	return df7d, err
@@ -210,11 +191,10 @@ func (ecu *ECUReaderInstance) createDataframe80(d80 []byte) (DataFrame80, error)
 	var err error
 	var df80 DataFrame80
 
-	defer func() {
-		if err := recover(); err != nil {
-			log.Warnf("dataframe conversion panic occurred %s", err)
-		}
-	}()
+	if err = ValidateDataframe(frame80, d80); err != nil {
+		log.Errorf("invalid dataframe x80 (%s)", err)
+		return df80, err
+	}
 
 	// populate the DataFrame structure for command 0x80
 	byteReader := bytes.NewReader(d80)
@@ -222,7 +202,7 @@ func (ecu *ECUReaderInstance) createDataframe80(d80 []byte) (DataFrame80, error)
 	if err = binary.Read(byteReader, binary.BigEndian, &df80); err != nil {
 		log.Errorf("error reading dataframe x80 (%s)", err)
 	} else {
-		log.Infof("dataframe x80 received (data: %X dataframe: %+v)", byteReader, df80)
+		log.Infof("dataframe x80 received (data: %X dataframe: %+v)", d80, df80)
 	}
 
 	return df80, err
@@ -246,10 +226,57 @@ func (ecu *ECUReaderInstance) readRawDataFrames() ([]byte, []byte, error) {
 	return dataframe80, dataframe7d, dferr
 }
 
+// SetUnitSystem selects the units the session data is exported and reported in, metric, imperial, lambda or psi
+// the units of the log file are applied when the next log is opened
+func (ecu *ECUReaderInstance) SetUnitSystem(name string) error {
+	var err error
+	var units UnitSystem
+
+	if units, err = GetUnitSystem(name); err != nil {
+		log.Warnf("%s", err)
+		return err
+	}
+
+	ecu.Units = units
+	log.Infof("session unit system set to %s", units.Name)
+
+	return err
+}
+
+// SetThresholdProfile sets the diagnostic thresholds used to analyse the session
+// the profile is either a built-in profile name or a JSON profile file, the diagnostics are reset
+func (ecu *ECUReaderInstance) SetThresholdProfile(name string) error {
+	var err error
+	var profile ThresholdProfile
+
+	if isThresholdProfileFile(name) {
+		profile, err = LoadThresholdProfile(name)
+	} else {
+		profile, err = GetThresholdProfile(name)
+	}
+
+	if err != nil {
+		log.Warnf("%s", err)
+		return err
+	}
+
+	ecu.Diagnostics = NewDataframeAnalysisWithProfile(20, profile)
+	ecu.setClock()
+	log.Infof("session threshold profile set to %s", profile.Name)
+
+	return err
+}
+
 func (ecu *ECUReaderInstance) openLog() {
 	// initialise logging
 	if ecu.isMEMSReader() {
-		ecu.dataLogger = NewMemsDataLogger(GetLogFolder(), ecu.Status.ECUSerial)
+		ecu.dataLogger = NewMemsDataLoggerWithUnits(GetLogFolder(), ecu.Status.ECUSerial, ecu.Units)
+		log.Infof("logging session analysed with threshold profile %s", ecu.Diagnostics.Analysis.Profile)
+
+		// keep the journal of changes made to the ecu alongside the log
+		if ecu.dataLogger.IsOpen {
+			_ = ecu.Journal.Open(getJournalFilepath(ecu.dataLogger.Filepath))
+		}
 	}
 }
 
@@ -258,6 +285,8 @@ func (ecu *ECUReaderInstance) closeLog() {
 		if ecu.dataLogger != nil {
 			ecu.dataLogger.Close()
 		}
+
+		ecu.Journal.Close()
 	}
 }
 
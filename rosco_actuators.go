@@ -62,10 +62,14 @@ func (ecu *ECUReaderInstance) activateActuator(activateCommand []byte, deactivat
 		if data, err = ecu.ecuReader.SendAndReceive(activateCommand); err == nil {
 			log.Infof("actuator %X activated (%X)", activateCommand, data)
 		}
+
+		ecu.journalStateChange(activateCommand, data, 1, err)
 	} else {
 		if data, err = ecu.ecuReader.SendAndReceive(deactivateCommand); err == nil {
 			log.Infof("actuator %X deactivated (%X)", deactivateCommand, data)
 		}
+
+		ecu.journalStateChange(deactivateCommand, data, 0, err)
 	}
 
 	return err
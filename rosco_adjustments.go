@@ -5,6 +5,16 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// Adjustment names
+const (
+	ShortTermFuelTrimAdjustment     = "ShortTermFuelTrim"
+	LongTermFuelTrimAdjustment      = "LongTermFuelTrim"
+	IdleDecayAdjustment             = "IdleDecay"
+	IdleSpeedAdjustment             = "IdleSpeed"
+	IgnitionAdvanceOffsetAdjustment = "IgnitionAdvanceOffset"
+	IACPositionAdjustment           = "IACPosition"
+)
+
 // AdjustShortTermFuelTrim increments or decrements by the number of steps
 func (ecu *ECUReaderInstance) AdjustShortTermFuelTrim(steps int) (int, error) {
 	return ecu.applyAdjustment(MEMSSTFTIncrement, MEMSSTFTDecrement, MEMSFuelTrimDefault, steps)
@@ -51,48 +61,71 @@ func (ecu *ECUReaderInstance) applyAdjustment(incrementCommand []byte, decrement
 		return defaultValue, err
 	}
 
+	return ecu.adjust(incrementCommand, decrementCommand, steps, 0)
+}
+
+// adjust applies the steps and records the adjustment in the journal
+// undoOf identifies the journal entry being reversed, 0 if this is not an undo
+func (ecu *ECUReaderInstance) adjust(incrementCommand []byte, decrementCommand []byte, steps int, undoOf int) (int, error) {
+	var err error
+	var cmd, first, last []byte
+
+	before, read := ecu.readAdjustment(incrementCommand)
+
 	if steps > 0 {
 		// if the steps are positive then increment the adjustment by n steps.
-		return ecu.incementAdjustment(incrementCommand, steps)
+		cmd = incrementCommand
+		first, last, err = ecu.incementAdjustment(cmd, steps)
 	} else {
 		// if the steps are negative then decrement the adjustment by n steps.
-		return ecu.decrementAdjustment(decrementCommand, steps)
+		cmd = decrementCommand
+		first, last, err = ecu.decrementAdjustment(cmd, steps)
+	}
+
+	ecu.journalAdjustment(cmd, steps, before, read, first, last, undoOf, err)
+
+	if err == nil {
+		return int(last[1]), err
+	} else {
+		return 0, err
 	}
 }
 
-func (ecu *ECUReaderInstance) decrementAdjustment(cmd []byte, steps int) (int, error) {
+// returns the ecu response to the first and last steps
+func (ecu *ECUReaderInstance) decrementAdjustment(cmd []byte, steps int) ([]byte, []byte, error) {
 	var err error
-	var data []byte
+	var data, first []byte
 
-	log.Infof("decrementing adjustable command %X by %d steps", data, steps)
+	log.Infof("decrementing adjustable command %X by %d steps", cmd, steps)
 	for step := steps; step < 0; step++ {
 		if data, err = ecu.ecuReader.SendAndReceive(cmd); err == nil {
 			log.Infof("command %X deccremented to %X", cmd, data)
+
+			if first == nil {
+				first = data
+			}
 		}
 	}
 
-	if err == nil {
-		return int(data[1]), err
-	} else {
-		return 0, err
-	}
+	return first, data, err
 }
 
-func (ecu *ECUReaderInstance) incementAdjustment(cmd []byte, steps int) (int, error) {
+// returns the ecu response to the first and last steps
+func (ecu *ECUReaderInstance) incementAdjustment(cmd []byte, steps int) ([]byte, []byte, error) {
 	var err error
-	var data []byte
+	var data, first []byte
 
-	log.Infof("incrementing adjustable command %X by %d steps", data, steps)
+	log.Infof("incrementing adjustable command %X by %d steps", cmd, steps)
 
 	for step := 0; step < steps; step++ {
 		if data, err = ecu.ecuReader.SendAndReceive(cmd); err == nil {
 			log.Infof("command %X incremented to %X", cmd, data)
+
+			if first == nil {
+				first = data
+			}
 		}
 	}
 
-	if err == nil {
-		return int(data[1]), err
-	} else {
-		return 0, err
-	}
+	return first, data, err
 }
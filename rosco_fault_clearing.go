@@ -0,0 +1,125 @@
+package rosco
+
+import (
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// FaultClearingConfig controls the verification of a fault clear
+type FaultClearingConfig struct {
+	// ObservationWindow is how long the dataframes are monitored after the clear for faults reappearing
+	ObservationWindow time.Duration
+	// SampleInterval is the delay between dataframe reads
+	SampleInterval time.Duration
+	// Clock returns the current time, defaults to time.Now
+	Clock func() time.Time
+}
+
+// FaultClearingReport describes the outcome of clearing the ECU faults
+type FaultClearingReport struct {
+	ClearedAt time.Time `json:"ClearedAt"`
+	// Before are the faults present before the clear
+	Before []FaultCode `json:"Before"`
+	// Cleared are the faults that were cleared and did not return
+	Cleared []FaultCode `json:"Cleared"`
+	// Persisted are the faults still present immediately after the clear, these are hard faults
+	Persisted []FaultCode `json:"Persisted"`
+	// Reappeared are the faults that cleared but returned within the observation window
+	Reappeared []FaultCode `json:"Reappeared"`
+	// New are faults that were not present before the clear but appeared within the observation window
+	New []FaultCode `json:"New"`
+	// Success is true when all faults cleared and none returned
+	Success bool `json:"Success"`
+}
+
+// NewFaultClearingConfig returns the default fault clearing configuration
+func NewFaultClearingConfig() FaultClearingConfig {
+	return FaultClearingConfig{
+		ObservationWindow: time.Second * 30,
+		SampleInterval:    time.Second,
+		Clock:             time.Now,
+	}
+}
+
+// ClearAndVerifyFaults reads the faults, clears them and monitors the dataframes
+// to report which faults cleared, which persisted and which reappeared
+func (ecu *ECUReaderInstance) ClearAndVerifyFaults(config FaultClearingConfig) (FaultClearingReport, error) {
+	var err error
+	var data MemsData
+
+	report := FaultClearingReport{}
+
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+
+	if data, err = ecu.GetDataframes(); err != nil {
+		log.Errorf("unable to read faults before clearing (%s)", err)
+		return report, err
+	}
+
+	report.Before = data.FaultCodes
+
+	if err = ecu.ClearFaults(); err != nil {
+		return report, err
+	}
+
+	report.ClearedAt = config.Clock()
+
+	if data, err = ecu.GetDataframes(); err != nil {
+		log.Errorf("unable to read faults after clearing (%s)", err)
+		return report, err
+	}
+
+	// faults present immediately after the clear could not be cleared
+	persisted := make(map[string]bool)
+	reappeared := make(map[string]bool)
+	appeared := make(map[string]bool)
+
+	for _, fault := range data.FaultCodes {
+		persisted[fault.Name] = true
+	}
+
+	// observe the faults for the duration of the window
+	for config.Clock().Sub(report.ClearedAt) < config.ObservationWindow {
+		time.Sleep(config.SampleInterval)
+
+		if data, err = ecu.GetDataframes(); err != nil {
+			log.Errorf("unable to read faults during observation (%s)", err)
+			return report, err
+		}
+
+		for _, fault := range data.FaultCodes {
+			if !persisted[fault.Name] {
+				if HasFaultCode(report.Before, fault.Name) {
+					reappeared[fault.Name] = true
+				} else {
+					appeared[fault.Name] = true
+				}
+			}
+		}
+	}
+
+	for _, fault := range report.Before {
+		switch {
+		case persisted[fault.Name]:
+			report.Persisted = append(report.Persisted, fault)
+		case reappeared[fault.Name]:
+			report.Reappeared = append(report.Reappeared, fault)
+		default:
+			report.Cleared = append(report.Cleared, fault)
+		}
+	}
+
+	for _, fault := range faultCodeDefinitions {
+		if appeared[fault.Name] || (persisted[fault.Name] && !HasFaultCode(report.Before, fault.Name)) {
+			report.New = append(report.New, fault)
+		}
+	}
+
+	report.Success = len(report.Persisted) == 0 && len(report.Reappeared) == 0 && len(report.New) == 0
+
+	log.Infof("fault clearing report (%+v)", report)
+
+	return report, err
+}
@@ -0,0 +1,137 @@
+package rosco
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"strings"
+	"testing"
+	"time"
+)
+
+// faultyECUReader reports fault codes, hard faults survive a clear
+// and intermittent faults return after a number of frames
+type faultyECUReader struct {
+	dtc0         byte
+	dtc1         byte
+	hard         byte
+	intermittent byte
+	returnAfter  int
+	frames       int
+	cleared      bool
+}
+
+func (r *faultyECUReader) Connect() (bool, error) {
+	return true, nil
+}
+
+func (r *faultyECUReader) Disconnect() error {
+	return nil
+}
+
+func (r *faultyECUReader) SendAndReceive(command []byte) ([]byte, error) {
+	switch strings.ToUpper(hex.EncodeToString(command)) {
+	case "80":
+		if r.cleared {
+			r.frames++
+			if r.frames > r.returnAfter {
+				r.dtc1 |= r.intermittent
+			}
+		}
+
+		data := append([]byte{}, createResponseMap()["80"]...)
+		data[14] = r.dtc0
+		data[15] = r.dtc1
+		return data, nil
+	case "CC":
+		r.dtc0 = 0
+		r.dtc1 &= r.hard
+		r.cleared = true
+		return []byte{0xcc, 0x00}, nil
+	case "7D":
+		// no dtc2 faults
+		data := append([]byte{}, createResponseMap()["7D"]...)
+		data[6] = 0
+		return data, nil
+	}
+
+	return generateECUResponse(hex.EncodeToString(command)), nil
+}
+
+func getFaultClearingConfig() FaultClearingConfig {
+	config := NewFaultClearingConfig()
+	config.SampleInterval = 0
+	config.Clock = steppedClock(time.Second)
+
+	return config
+}
+
+func Test_faultClearing_AllCleared(t *testing.T) {
+	r := NewECUReaderInstance()
+	r.ecuReader = &faultyECUReader{dtc0: CoolantSensorFaultCode, dtc1: FuelPumpFaultCode, returnAfter: 100}
+
+	report, err := r.ClearAndVerifyFaults(getFaultClearingConfig())
+
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, report.Success, is.True())
+	then.AssertThat(t, len(report.Before), is.EqualTo(2))
+	then.AssertThat(t, len(report.Cleared), is.EqualTo(2))
+	then.AssertThat(t, len(report.Persisted), is.EqualTo(0))
+	then.AssertThat(t, len(report.Reappeared), is.EqualTo(0))
+
+	// the clear is recorded in the journal
+	then.AssertThat(t, r.Journal.GetEntries()[0].Operation, is.EqualTo("ClearFaults"))
+}
+
+func Test_faultClearing_HardAndIntermittentFaults(t *testing.T) {
+	r := NewECUReaderInstance()
+	r.ecuReader = &faultyECUReader{
+		dtc0:         AirSensorFaultCode,
+		dtc1:         FuelPumpFaultCode | ThrottlePotFaultCode,
+		hard:         ThrottlePotFaultCode,
+		intermittent: FuelPumpFaultCode | MAPSensorFaultCode,
+		returnAfter:  5,
+	}
+
+	report, err := r.ClearAndVerifyFaults(getFaultClearingConfig())
+
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, report.Success, is.False())
+	then.AssertThat(t, len(report.Before), is.EqualTo(3))
+
+	then.AssertThat(t, len(report.Cleared), is.EqualTo(1))
+	then.AssertThat(t, report.Cleared[0].Name, is.EqualTo("IntakeAirTempSensor"))
+
+	then.AssertThat(t, len(report.Persisted), is.EqualTo(1))
+	then.AssertThat(t, report.Persisted[0].Name, is.EqualTo("ThrottlePotCircuit"))
+
+	then.AssertThat(t, len(report.Reappeared), is.EqualTo(1))
+	then.AssertThat(t, report.Reappeared[0].Name, is.EqualTo("FuelPumpCircuit"))
+
+	then.AssertThat(t, len(report.New), is.EqualTo(1))
+	then.AssertThat(t, report.New[0].Name, is.EqualTo("MAPSensor"))
+
+	data, err := json.Marshal(report)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, string(data), is.ValueContaining(`"Persisted":[{"Code":16,"Name":"ThrottlePotCircuit"`))
+	then.AssertThat(t, string(data), is.ValueContaining(`"Success":false`))
+}
+
+func Test_faultClearing_OutsideObservationWindow(t *testing.T) {
+	r := NewECUReaderInstance()
+	r.ecuReader = &faultyECUReader{
+		dtc1:         FuelPumpFaultCode,
+		intermittent: FuelPumpFaultCode,
+		returnAfter:  5,
+	}
+
+	config := getFaultClearingConfig()
+	config.ObservationWindow = time.Second * 3
+
+	report, err := r.ClearAndVerifyFaults(config)
+
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, report.Success, is.True())
+	then.AssertThat(t, len(report.Cleared), is.EqualTo(1))
+}
@@ -0,0 +1,236 @@
+package rosco
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gonum.org/v1/gonum/stat"
+	"math"
+	"time"
+)
+
+// IdleCalibrationConfig controls the closed loop idle speed calibration
+type IdleCalibrationConfig struct {
+	// TargetRPM is the idle speed the calibration steps towards
+	TargetRPM int
+	// Tolerance is the acceptable difference in RPM between the settled idle and the target
+	Tolerance int
+	// CoarseThreshold, when the RPM error exceeds this value the idle speed is adjusted, otherwise the IAC position
+	CoarseThreshold int
+	// MaxSteps is the maximum number of adjustments made before the calibration gives up
+	MaxSteps int
+	// SettleSamples is the number of dataframes used to determine if the RPM has settled
+	SettleSamples int
+	// MaxSettleSamples is the maximum number of dataframes read whilst waiting for the RPM to settle
+	MaxSettleSamples int
+	// SettleStdDev is the RPM standard deviation below which the idle is considered settled
+	SettleStdDev float64
+	// SampleInterval is the delay between dataframe reads
+	SampleInterval time.Duration
+}
+
+// IdleCalibrationStep describes a single adjustment made during the calibration
+type IdleCalibrationStep struct {
+	Timestamp  time.Time
+	Adjustment string
+	Steps      int
+	Value      int
+	RPMBefore  float64
+	RPMAfter   float64
+	RPMStdDev  float64
+	Settled    bool
+}
+
+// IdleCalibrationReport is the outcome of the idle speed calibration
+type IdleCalibrationReport struct {
+	TargetRPM      int
+	StartRPM       float64
+	FinalRPM       float64
+	FinalRPMStdDev float64
+	Stable         bool
+	Success        bool
+	Aborted        bool
+	AbortReason    string
+	Steps          []IdleCalibrationStep
+	StartedAt      time.Time
+	CompletedAt    time.Time
+}
+
+// NewIdleCalibrationConfig returns a calibration configuration with default settings for the target RPM
+func NewIdleCalibrationConfig(targetRPM int) IdleCalibrationConfig {
+	return IdleCalibrationConfig{
+		TargetRPM:        targetRPM,
+		Tolerance:        25,
+		CoarseThreshold:  75,
+		MaxSteps:         20,
+		SettleSamples:    5,
+		MaxSettleSamples: 30,
+		SettleStdDev:     15,
+		SampleInterval:   time.Second,
+	}
+}
+
+// CalibrateIdleSpeed steps the idle speed and IAC position towards the target RPM.
+// The engine must be running, warm and at idle. Between each step the RPM is allowed to settle.
+// The calibration is aborted if the throttle is opened or a fault is detected.
+func (ecu *ECUReaderInstance) CalibrateIdleSpeed(config IdleCalibrationConfig) (IdleCalibrationReport, error) {
+	var err error
+	var data MemsData
+	var rpm, stddev float64
+	var settled bool
+
+	report := IdleCalibrationReport{
+		TargetRPM: config.TargetRPM,
+		StartedAt: time.Now(),
+	}
+
+	log.Infof("starting idle calibration (%+v)", config)
+
+	// check the engine is ready for calibration
+	if data, err = ecu.GetDataframes(); err != nil {
+		return ecu.abortIdleCalibration(report, err)
+	}
+
+	if err = isReadyForIdleCalibration(data.Analytics); err != nil {
+		return ecu.abortIdleCalibration(report, err)
+	}
+
+	if rpm, stddev, settled, err = ecu.waitForSettledIdle(config); err != nil {
+		return ecu.abortIdleCalibration(report, err)
+	}
+
+	report.StartRPM = rpm
+
+	for len(report.Steps) < config.MaxSteps {
+		rpmError := config.TargetRPM - int(math.Round(rpm))
+
+		if absInt(rpmError) <= config.Tolerance {
+			log.Infof("idle calibration reached target, %.0f rpm", rpm)
+			break
+		}
+
+		step := IdleCalibrationStep{
+			Timestamp: time.Now(),
+			RPMBefore: rpm,
+			Steps:     1,
+		}
+
+		if rpmError < 0 {
+			step.Steps = -1
+		}
+
+		if absInt(rpmError) > config.CoarseThreshold {
+			step.Adjustment = IdleSpeedAdjustment
+			step.Value, err = ecu.AdjustIdleSpeed(step.Steps)
+		} else {
+			step.Adjustment = IACPositionAdjustment
+			step.Value, err = ecu.AdjustIACPosition(step.Steps)
+		}
+
+		if err != nil {
+			return ecu.abortIdleCalibration(report, fmt.Errorf("%s adjustment failed (%s)", step.Adjustment, err))
+		}
+
+		if rpm, stddev, settled, err = ecu.waitForSettledIdle(config); err != nil {
+			return ecu.abortIdleCalibration(report, err)
+		}
+
+		step.RPMAfter = rpm
+		step.RPMStdDev = stddev
+		step.Settled = settled
+		report.Steps = append(report.Steps, step)
+
+		log.Infof("idle calibration step %+v", step)
+	}
+
+	report.FinalRPM = rpm
+	report.FinalRPMStdDev = stddev
+	report.Stable = settled
+	report.Success = settled && absInt(config.TargetRPM-int(math.Round(rpm))) <= config.Tolerance
+	report.CompletedAt = time.Now()
+
+	log.Infof("completed idle calibration (%+v)", report)
+
+	return report, err
+}
+
+// waitForSettledIdle reads dataframes until the RPM is stable or the maximum number of samples have been read
+// returns the mean and standard deviation of the RPM over the settle window
+func (ecu *ECUReaderInstance) waitForSettledIdle(config IdleCalibrationConfig) (float64, float64, bool, error) {
+	var samples []float64
+	var mean, stddev float64
+
+	window := config.SettleSamples
+	if window < 2 {
+		window = 2
+	}
+
+	for i := 0; i < config.MaxSettleSamples || len(samples) < window; i++ {
+		time.Sleep(config.SampleInterval)
+
+		data, err := ecu.GetDataframes()
+		if err != nil {
+			return mean, stddev, false, err
+		}
+
+		if err = isReadyForIdleCalibration(data.Analytics); err != nil {
+			return mean, stddev, false, err
+		}
+
+		samples = append(samples, float64(data.EngineRPM))
+
+		if len(samples) >= window {
+			mean, stddev = stat.MeanStdDev(samples[len(samples)-window:], nil)
+
+			if stddev <= config.SettleStdDev {
+				return mean, stddev, true, nil
+			}
+		}
+	}
+
+	log.Warnf("idle rpm did not settle (mean %.0f, std dev %.1f)", mean, stddev)
+
+	return mean, stddev, false, nil
+}
+
+func (ecu *ECUReaderInstance) abortIdleCalibration(report IdleCalibrationReport, err error) (IdleCalibrationReport, error) {
+	report.Aborted = true
+	report.AbortReason = err.Error()
+	report.CompletedAt = time.Now()
+
+	log.Warnf("idle calibration aborted (%s)", err)
+
+	return report, err
+}
+
+// isReadyForIdleCalibration checks the engine is warm and idling without faults that would affect the idle speed
+func isReadyForIdleCalibration(analysis AnalysisReport) error {
+	if !analysis.IsEngineRunning {
+		return fmt.Errorf("engine is not running")
+	}
+
+	if !analysis.IsAtOperatingTemp {
+		return fmt.Errorf("engine is not at operating temperature")
+	}
+
+	if analysis.IsThrottleActive || !analysis.IsEngineIdle {
+		return fmt.Errorf("throttle is active")
+	}
+
+	if analysis.CoolantTempSensorFault || analysis.IntakeAirTempSensorFault || analysis.ThrottlePotCircuitFault {
+		return fmt.Errorf("ecu fault codes present")
+	}
+
+	if analysis.CrankshaftSensorFault || analysis.CoilFault || analysis.IdleAirControlFault {
+		return fmt.Errorf("operational fault detected")
+	}
+
+	return nil
+}
+
+func absInt(x int) int {
+	if x < 0 {
+		return -x
+	}
+
+	return x
+}
@@ -0,0 +1,148 @@
+package rosco
+
+import (
+	"encoding/hex"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"strings"
+	"testing"
+)
+
+// simulatedIdleReader emulates a warm engine at idle where the
+// rpm responds to idle speed and iac adjustments
+type simulatedIdleReader struct {
+	rpm           int
+	throttleAngle byte
+	idleSpeed     byte
+	iacPosition   byte
+}
+
+func newSimulatedIdleReader(rpm int) *simulatedIdleReader {
+	responseMap = createResponseMap()
+	return &simulatedIdleReader{rpm: rpm, throttleAngle: 0x14, idleSpeed: 0x80, iacPosition: 0x80}
+}
+
+func (r *simulatedIdleReader) Connect() (bool, error) {
+	return true, nil
+}
+
+func (r *simulatedIdleReader) Disconnect() error {
+	return nil
+}
+
+func (r *simulatedIdleReader) SendAndReceive(command []byte) ([]byte, error) {
+	switch strings.ToUpper(hex.EncodeToString(command)) {
+	case "80":
+		data := append([]byte{}, responseMap["80"]...)
+		data[2] = byte(r.rpm >> 8)
+		data[3] = byte(r.rpm)
+		// warm engine, good map
+		data[4] = 80 + 55
+		data[8] = 34
+		return data, nil
+	case "7D":
+		data := append([]byte{}, responseMap["7D"]...)
+		data[3] = r.throttleAngle
+		return data, nil
+	case "91":
+		r.rpm += 25
+		r.idleSpeed++
+		return []byte{0x91, r.idleSpeed}, nil
+	case "92":
+		r.rpm -= 25
+		r.idleSpeed--
+		return []byte{0x92, r.idleSpeed}, nil
+	case "FD":
+		r.rpm += 10
+		r.iacPosition++
+		return []byte{0xfd, r.iacPosition}, nil
+	case "FE":
+		r.rpm -= 10
+		r.iacPosition--
+		return []byte{0xfe, r.iacPosition}, nil
+	}
+
+	return generateECUResponse(hex.EncodeToString(command)), nil
+}
+
+func getIdleCalibrationConfig(target int) IdleCalibrationConfig {
+	config := NewIdleCalibrationConfig(target)
+	config.SampleInterval = 0
+
+	return config
+}
+
+func Test_idleCalibration_ReachesTarget(t *testing.T) {
+	r := NewECUReaderInstance()
+	r.ecuReader = newSimulatedIdleReader(1189)
+
+	report, err := r.CalibrateIdleSpeed(getIdleCalibrationConfig(850))
+
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, report.Aborted, is.False())
+	then.AssertThat(t, report.Success, is.True())
+	then.AssertThat(t, report.Stable, is.True())
+	then.AssertThat(t, report.StartRPM, is.EqualTo(float64(1189)))
+	then.AssertThat(t, report.FinalRPM, is.EqualTo(float64(874)))
+	then.AssertThat(t, len(report.Steps), is.EqualTo(15))
+
+	// coarse adjustments using the idle speed first, then fine adjustments with the iac
+	then.AssertThat(t, report.Steps[0].Adjustment, is.EqualTo(IdleSpeedAdjustment))
+	then.AssertThat(t, report.Steps[0].Steps, is.EqualTo(-1))
+	then.AssertThat(t, report.Steps[14].Adjustment, is.EqualTo(IACPositionAdjustment))
+
+	// each adjustment is recorded in the journal
+	then.AssertThat(t, len(r.Journal.GetEntries()), is.EqualTo(15))
+}
+
+func Test_idleCalibration_AlreadyAtTarget(t *testing.T) {
+	r := NewECUReaderInstance()
+	r.ecuReader = newSimulatedIdleReader(860)
+
+	report, err := r.CalibrateIdleSpeed(getIdleCalibrationConfig(850))
+
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, report.Success, is.True())
+	then.AssertThat(t, len(report.Steps), is.EqualTo(0))
+}
+
+func Test_idleCalibration_MaxSteps(t *testing.T) {
+	r := NewECUReaderInstance()
+	r.ecuReader = newSimulatedIdleReader(1189)
+
+	config := getIdleCalibrationConfig(850)
+	config.MaxSteps = 3
+
+	report, err := r.CalibrateIdleSpeed(config)
+
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, report.Success, is.False())
+	then.AssertThat(t, len(report.Steps), is.EqualTo(3))
+	then.AssertThat(t, report.FinalRPM, is.EqualTo(float64(1114)))
+}
+
+func Test_idleCalibration_AbortsOnThrottle(t *testing.T) {
+	reader := newSimulatedIdleReader(1189)
+	reader.throttleAngle = 0x30
+
+	r := NewECUReaderInstance()
+	r.ecuReader = reader
+
+	report, err := r.CalibrateIdleSpeed(getIdleCalibrationConfig(850))
+
+	then.AssertThat(t, err, is.Not(is.Nil()))
+	then.AssertThat(t, report.Aborted, is.True())
+	then.AssertThat(t, report.AbortReason, is.EqualTo("throttle is active"))
+}
+
+func Test_idleCalibration_AbortsOnColdEngine(t *testing.T) {
+	r := NewECUReaderInstance()
+	r.ecuReader = NewECUReader(scenarioPort)
+	_, _ = r.connectToECU()
+
+	report, err := r.CalibrateIdleSpeed(getIdleCalibrationConfig(850))
+
+	then.AssertThat(t, err, is.Not(is.Nil()))
+	then.AssertThat(t, report.Aborted, is.True())
+	then.AssertThat(t, len(report.Steps), is.EqualTo(0))
+}
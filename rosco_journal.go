@@ -0,0 +1,374 @@
+package rosco
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JournalEntry records a single state changing operation sent to the ECU
+type JournalEntry struct {
+	Sequence  int       `json:"Sequence"`
+	Timestamp time.Time `json:"Timestamp"`
+	Operation string    `json:"Operation"`
+	Command   string    `json:"Command"`
+	Response  string    `json:"Response"`
+	Steps     int       `json:"Steps"`
+	Before    int       `json:"Before"`
+	After     int       `json:"After"`
+	UndoOf    int       `json:"UndoOf,omitempty"`
+	Error     string    `json:"Error,omitempty"`
+}
+
+// ECUJournal is an append-only record of the changes made to the ECU
+// when a file is attached, each entry is also appended to the file as a json line
+type ECUJournal struct {
+	mutex    sync.Mutex
+	file     *os.File
+	Filepath string
+	Entries  []JournalEntry
+}
+
+const (
+	journalFileExtension = ".journal"
+)
+
+// operations that can be reversed by the journal, keyed by operation name
+type journalAdjustment struct {
+	incrementCommand []byte
+	decrementCommand []byte
+	// readCommand reports the value without changing it, nil if the ecu only reports the value after a step
+	readCommand []byte
+}
+
+var journalAdjustments = map[string]journalAdjustment{
+	ShortTermFuelTrimAdjustment:     {MEMSSTFTIncrement, MEMSSTFTDecrement, nil},
+	LongTermFuelTrimAdjustment:      {MEMSLTFTIncrement, MEMSLTFTDecrement, nil},
+	IdleDecayAdjustment:             {MEMSIdleDecayIncrement, MEMSIdleDecayDecrement, nil},
+	IdleSpeedAdjustment:             {MEMSIdleSpeedIncrement, MEMSIdleSpeedDecrement, nil},
+	IgnitionAdvanceOffsetAdjustment: {MEMSIgnitionAdvanceOffsetIncrement, MEMSIgnitionAdvanceOffsetDecrement, nil},
+	IACPositionAdjustment:           {MEMSIACIncrement, MEMSIACDecrement, MEMSGetIACPosition},
+}
+
+// names of the actuator and reset operations, keyed by the command code
+var journalOperations = map[byte]string{
+	MEMSFuelPumpOn[0]:    "FuelPump",
+	MEMSFuelPumpOff[0]:   "FuelPump",
+	MEMSPTCRelayOn[0]:    "PTCRelay",
+	MEMSPTCRelayOff[0]:   "PTCRelay",
+	MEMSACRelayOn[0]:     "ACRelay",
+	MEMSACRelayOff[0]:    "ACRelay",
+	MEMSPurgeValveOn[0]:  "PurgeValve",
+	MEMSPurgeValveOff[0]: "PurgeValve",
+	MEMSO2HeaterOn[0]:    "O2Heater",
+	MEMSO2HeaterOff[0]:   "O2Heater",
+	MEMSBoostValveOn[0]:  "BoostValve",
+	MEMSBoostValveOff[0]: "BoostValve",
+	MEMSFan1On[0]:        "Fan1",
+	MEMSFan1Off[0]:       "Fan1",
+	MEMSFan2On[0]:        "Fan2",
+	MEMSFan2Off[0]:       "Fan2",
+	MEMSTestInjectors[0]: "Injectors",
+	MEMSFireCoil[0]:      "Coil",
+	MEMSClearFaults[0]:   "ClearFaults",
+	MEMSResetAdj[0]:      "ResetAdjustments",
+	MEMSResetECU[0]:      "ResetECU",
+}
+
+// NewECUJournal creates an empty in-memory journal
+func NewECUJournal() *ECUJournal {
+	return &ECUJournal{}
+}
+
+// Open attaches a file to the journal, entries are appended to the file as they are recorded
+// the journal must be closed before another file is attached
+func (journal *ECUJournal) Open(filepath string) error {
+	var err error
+
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	if journal.file != nil {
+		err = fmt.Errorf("journal file %s is already open", journal.Filepath)
+		log.Errorf("%s", err)
+		return err
+	}
+
+	if journal.file, err = os.OpenFile(filepath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		log.Errorf("unable to open journal file %s (%s)", filepath, err)
+	} else {
+		journal.Filepath = filepath
+		log.Infof("opened journal file %s", filepath)
+	}
+
+	return err
+}
+
+// Close detaches the file from the journal, the in-memory entries are retained
+func (journal *ECUJournal) Close() {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	if journal.file != nil {
+		if err := journal.file.Close(); err != nil {
+			log.Errorf("error closing journal file (%s)", err)
+		} else {
+			log.Infof("closed journal file %s", journal.Filepath)
+		}
+
+		journal.file = nil
+	}
+}
+
+// GetEntries returns a copy of the journal entries
+func (journal *ECUJournal) GetEntries() []JournalEntry {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	entries := make([]JournalEntry, len(journal.Entries))
+	copy(entries, journal.Entries)
+
+	return entries
+}
+
+// GetEntry returns the entry with the given sequence number
+func (journal *ECUJournal) GetEntry(sequence int) (JournalEntry, error) {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	for _, entry := range journal.Entries {
+		if entry.Sequence == sequence {
+			return entry, nil
+		}
+	}
+
+	return JournalEntry{}, fmt.Errorf("journal entry %d not found", sequence)
+}
+
+// isUndone returns true if an entry has already been reversed by a later entry
+func (journal *ECUJournal) isUndone(sequence int) bool {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	for _, entry := range journal.Entries {
+		if entry.UndoOf == sequence {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lastValue returns the most recent after value recorded for the operation
+func (journal *ECUJournal) lastValue(operation string) int {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	for i := len(journal.Entries) - 1; i >= 0; i-- {
+		if journal.Entries[i].Operation == operation {
+			return journal.Entries[i].After
+		}
+	}
+
+	return 0
+}
+
+// record appends the entry to the journal and the journal file, returning the recorded entry
+func (journal *ECUJournal) record(entry JournalEntry) JournalEntry {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	entry.Sequence = len(journal.Entries) + 1
+	entry.Timestamp = time.Now()
+
+	journal.Entries = append(journal.Entries, entry)
+
+	if journal.file != nil {
+		if data, err := json.Marshal(entry); err == nil {
+			if _, err = journal.file.Write(append(data, '\n')); err != nil {
+				log.Errorf("unable to write to journal file (%s)", err)
+			}
+		} else {
+			log.Errorf("unable to serialise journal entry (%s)", err)
+		}
+	}
+
+	log.Infof("journal recorded %+v", entry)
+
+	return entry
+}
+
+// Undo reverses the change made by a recorded adjustment
+// returns the value of the adjustment after it has been reversed
+func (ecu *ECUReaderInstance) Undo(sequence int) (int, error) {
+	var err error
+	var entry JournalEntry
+
+	if entry, err = ecu.Journal.GetEntry(sequence); err != nil {
+		log.Warnf("%s", err)
+		return 0, err
+	}
+
+	adjustment, isAdjustment := journalAdjustments[entry.Operation]
+
+	if !isAdjustment || entry.UndoOf != 0 {
+		err = fmt.Errorf("journal entry %d (%s) cannot be undone", entry.Sequence, entry.Operation)
+		log.Warnf("%s", err)
+		return 0, err
+	}
+
+	if ecu.Journal.isUndone(entry.Sequence) {
+		err = fmt.Errorf("journal entry %d has already been undone", entry.Sequence)
+		log.Warnf("%s", err)
+		return entry.Before, err
+	}
+
+	if entry.Error != "" && entry.Response == "" {
+		err = fmt.Errorf("journal entry %d failed without the ecu reporting the value, cannot be undone", entry.Sequence)
+		log.Warnf("%s", err)
+		return entry.Before, err
+	}
+
+	// reverse the change the ecu reported rather than the requested steps,
+	// the adjustment may have failed or reached its limit part way
+	steps := entry.Before - entry.After
+
+	if entry.Before == entry.After {
+		err = fmt.Errorf("journal entry %d made no change, nothing to undo", entry.Sequence)
+		log.Warnf("%s", err)
+		return entry.Before, err
+	}
+
+	log.Infof("undoing journal entry %d (%s) by %d steps", entry.Sequence, entry.Operation, steps)
+
+	return ecu.adjust(adjustment.incrementCommand, adjustment.decrementCommand, steps, entry.Sequence)
+}
+
+// readAdjustment reads the value of the adjustment before it is stepped, the ecu reports the new value
+// in response to each step so the value is derived from the first step if the adjustment cannot be read
+func (ecu *ECUReaderInstance) readAdjustment(command []byte) (int, bool) {
+	adjustment, ok := journalAdjustments[getJournalAdjustmentName(command)]
+
+	if !ok || adjustment.readCommand == nil {
+		return 0, false
+	}
+
+	data, err := ecu.ecuReader.SendAndReceive(adjustment.readCommand)
+	if err != nil || len(data) < 2 {
+		log.Warnf("unable to read adjustment %X before stepping (%s)", adjustment.readCommand, err)
+		return 0, false
+	}
+
+	return int(data[1]), true
+}
+
+// journalAdjustment records an adjustment with the value read before the steps and the value after the last step
+func (ecu *ECUReaderInstance) journalAdjustment(command []byte, steps int, before int, read bool, first []byte, last []byte, undoOf int, err error) {
+	entry := JournalEntry{
+		Operation: getJournalAdjustmentName(command),
+		Command:   strings.ToUpper(hex.EncodeToString(command)),
+		Response:  strings.ToUpper(hex.EncodeToString(last)),
+		Steps:     steps,
+		Before:    before,
+		UndoOf:    undoOf,
+	}
+
+	if len(first) > 1 && len(last) > 1 {
+		if !read {
+			direction := 1
+			if steps < 0 {
+				direction = -1
+			}
+
+			entry.Before = int(first[1]) - direction
+		}
+
+		entry.After = int(last[1])
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	ecu.Journal.record(entry)
+}
+
+// journalStateChange records an actuator test, actuators are recorded as 1 on and 0 off
+func (ecu *ECUReaderInstance) journalStateChange(command []byte, response []byte, after int, err error) {
+	entry := JournalEntry{
+		Operation: getJournalOperationName(command),
+		Command:   strings.ToUpper(hex.EncodeToString(command)),
+		Response:  strings.ToUpper(hex.EncodeToString(response)),
+		Before:    ecu.Journal.lastValue(getJournalOperationName(command)),
+		After:     after,
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	ecu.Journal.record(entry)
+}
+
+// readResetValue reads the value cleared by the reset, the iac position for the adjustments
+// and the number of fault codes for the fault and ecu resets
+func (ecu *ECUReaderInstance) readResetValue(command []byte) int {
+	if bytes.Equal(command, MEMSResetAdj) {
+		position, _ := ecu.GetIACPosition()
+		return position
+	}
+
+	d80, d7d, err := ecu.readRawDataFrames()
+	if err != nil {
+		return 0
+	}
+
+	return len(DecodeFaultCodes(decodeMemsData(d80, d7d)))
+}
+
+// journalReset records a reset with the values read before and after the reset
+func (ecu *ECUReaderInstance) journalReset(command []byte, response []byte, before int, after int, err error) {
+	entry := JournalEntry{
+		Operation: getJournalOperationName(command),
+		Command:   strings.ToUpper(hex.EncodeToString(command)),
+		Response:  strings.ToUpper(hex.EncodeToString(response)),
+		Before:    before,
+		After:     after,
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	ecu.Journal.record(entry)
+}
+
+func getJournalAdjustmentName(command []byte) string {
+	for name, adjustment := range journalAdjustments {
+		if bytes.Equal(adjustment.incrementCommand, command) || bytes.Equal(adjustment.decrementCommand, command) {
+			return name
+		}
+	}
+
+	return strings.ToUpper(hex.EncodeToString(command))
+}
+
+func getJournalOperationName(command []byte) string {
+	if len(command) > 0 {
+		if name, ok := journalOperations[command[0]]; ok {
+			return name
+		}
+	}
+
+	return strings.ToUpper(hex.EncodeToString(command))
+}
+
+func getJournalFilepath(logFilepath string) string {
+	return strings.TrimSuffix(logFilepath, ".csv") + journalFileExtension
+}
@@ -0,0 +1,295 @@
+package rosco
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// steppingECUReader responds to the adjustments with the stepped value like the ecu,
+// the other commands are answered with the loopback responses
+// steps listed in failSteps fail without changing the value
+type steppingECUReader struct {
+	values    map[string]int
+	steps     int
+	failSteps map[int]bool
+}
+
+func newSteppingECUReader() *steppingECUReader {
+	return &steppingECUReader{values: make(map[string]int)}
+}
+
+func (r *steppingECUReader) Connect() (bool, error) {
+	return true, nil
+}
+
+func (r *steppingECUReader) Disconnect() error {
+	return nil
+}
+
+func (r *steppingECUReader) SendAndReceive(command []byte) ([]byte, error) {
+	if bytes.Equal(command, MEMSResetAdj) {
+		r.values = make(map[string]int)
+	}
+
+	for name, adjustment := range journalAdjustments {
+		value, ok := r.values[name]
+		if !ok {
+			// the loopback responds to an increment with one step above the default
+			value = int(createResponseMap()[strings.ToUpper(hex.EncodeToString(adjustment.incrementCommand))][1]) - 1
+		}
+
+		switch {
+		case bytes.Equal(command, adjustment.incrementCommand):
+			value++
+		case bytes.Equal(command, adjustment.decrementCommand):
+			value--
+		case bytes.Equal(command, adjustment.readCommand):
+		default:
+			continue
+		}
+
+		if !bytes.Equal(command, adjustment.readCommand) {
+			r.steps++
+			if r.failSteps[r.steps] {
+				return nil, fmt.Errorf("step %d failed", r.steps)
+			}
+		}
+
+		r.values[name] = value
+		return []byte{command[0], byte(value)}, nil
+	}
+
+	return generateECUResponse(hex.EncodeToString(command)), nil
+}
+
+func getSteppingECU(t *testing.T) *ECUReaderInstance {
+	r := NewECUReaderInstance()
+	r.ecuReader = newSteppingECUReader()
+	connected, err := r.connectToECU()
+
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, connected, is.True())
+
+	return r
+}
+
+func Test_journal_AdjustmentRecorded(t *testing.T) {
+	r := getSteppingECU(t)
+
+	value, err := r.AdjustShortTermFuelTrim(2)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, value, is.EqualTo(140))
+
+	entries := r.Journal.GetEntries()
+	then.AssertThat(t, len(entries), is.EqualTo(1))
+	then.AssertThat(t, entries[0].Sequence, is.EqualTo(1))
+	then.AssertThat(t, entries[0].Operation, is.EqualTo("ShortTermFuelTrim"))
+	then.AssertThat(t, entries[0].Command, is.EqualTo("79"))
+	then.AssertThat(t, entries[0].Response, is.EqualTo("798C"))
+	then.AssertThat(t, entries[0].Steps, is.EqualTo(2))
+	then.AssertThat(t, entries[0].Before, is.EqualTo(138))
+	then.AssertThat(t, entries[0].After, is.EqualTo(140))
+
+	// the iac position is read before it is stepped
+	value, err = r.AdjustIACPosition(-3)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, value, is.EqualTo(125))
+
+	entries = r.Journal.GetEntries()
+	then.AssertThat(t, entries[1].Operation, is.EqualTo("IACPosition"))
+	then.AssertThat(t, entries[1].Before, is.EqualTo(128))
+	then.AssertThat(t, entries[1].After, is.EqualTo(125))
+
+	// zero step adjustments are not sent to the ecu and are not recorded
+	_, err = r.AdjustShortTermFuelTrim(0)
+	then.AssertThat(t, err, is.Not(is.Nil()))
+	then.AssertThat(t, len(r.Journal.GetEntries()), is.EqualTo(2))
+}
+
+func Test_journal_StateChangesRecorded(t *testing.T) {
+	r := getSteppingECU(t)
+
+	_ = r.TestFuelPump(true)
+	_ = r.TestFuelPump(false)
+	_ = r.ClearFaults()
+	_ = r.ResetAdjustments()
+	_ = r.ResetECU()
+	// heartbeats do not change the ecu state
+	_ = r.SendHeartbeat()
+
+	entries := r.Journal.GetEntries()
+	then.AssertThat(t, len(entries), is.EqualTo(5))
+	then.AssertThat(t, entries[0].Operation, is.EqualTo("FuelPump"))
+	then.AssertThat(t, entries[0].Before, is.EqualTo(0))
+	then.AssertThat(t, entries[0].After, is.EqualTo(1))
+	then.AssertThat(t, entries[1].Operation, is.EqualTo("FuelPump"))
+	then.AssertThat(t, entries[1].Before, is.EqualTo(1))
+	then.AssertThat(t, entries[1].After, is.EqualTo(0))
+	then.AssertThat(t, entries[2].Operation, is.EqualTo("ClearFaults"))
+	then.AssertThat(t, entries[2].Response, is.EqualTo("CC00"))
+	then.AssertThat(t, entries[3].Operation, is.EqualTo("ResetAdjustments"))
+	then.AssertThat(t, entries[4].Operation, is.EqualTo("ResetECU"))
+}
+
+func Test_journal_ResetsRecorded(t *testing.T) {
+	r := getSteppingECU(t)
+
+	// the iac position is read before and after the adjustments are reset
+	_, _ = r.AdjustIACPosition(-3)
+	_ = r.ResetAdjustments()
+
+	entry := r.Journal.GetEntries()[1]
+	then.AssertThat(t, entry.Operation, is.EqualTo("ResetAdjustments"))
+	then.AssertThat(t, entry.Before, is.EqualTo(125))
+	then.AssertThat(t, entry.After, is.EqualTo(128))
+
+	// the fault codes are counted before and after the faults are cleared
+	r = NewECUReaderInstance()
+	r.ecuReader = &faultyECUReader{dtc0: CoolantSensorFaultCode, dtc1: FuelPumpFaultCode, returnAfter: 100}
+	_ = r.ClearFaults()
+
+	entry = r.Journal.GetEntries()[0]
+	then.AssertThat(t, entry.Operation, is.EqualTo("ClearFaults"))
+	then.AssertThat(t, entry.Before, is.EqualTo(2))
+	then.AssertThat(t, entry.After, is.EqualTo(0))
+}
+
+func Test_journal_Undo(t *testing.T) {
+	r := getSteppingECU(t)
+
+	_, err := r.AdjustShortTermFuelTrim(2)
+	then.AssertThat(t, err, is.Nil())
+
+	// all the steps are reversed
+	value, err := r.Undo(1)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, value, is.EqualTo(138))
+
+	entries := r.Journal.GetEntries()
+	then.AssertThat(t, len(entries), is.EqualTo(2))
+	then.AssertThat(t, entries[1].Command, is.EqualTo("7A"))
+	then.AssertThat(t, entries[1].Steps, is.EqualTo(-2))
+	then.AssertThat(t, entries[1].Before, is.EqualTo(140))
+	then.AssertThat(t, entries[1].After, is.EqualTo(138))
+	then.AssertThat(t, entries[1].UndoOf, is.EqualTo(1))
+
+	// entries can only be undone once
+	_, err = r.Undo(1)
+	then.AssertThat(t, err, is.Not(is.Nil()))
+
+	// undo entries cannot be undone
+	_, err = r.Undo(2)
+	then.AssertThat(t, err, is.Not(is.Nil()))
+
+	// unknown entries
+	_, err = r.Undo(99)
+	then.AssertThat(t, err, is.Not(is.Nil()))
+
+	// only adjustments can be undone
+	_ = r.ClearFaults()
+	_, err = r.Undo(3)
+	then.AssertThat(t, err, is.Not(is.Nil()))
+}
+
+func Test_journal_UndoPartialAdjustment(t *testing.T) {
+	r := getSteppingECU(t)
+	reader := r.ecuReader.(*steppingECUReader)
+
+	// the second of the 3 steps fails, the ecu only moves by 2 steps
+	reader.failSteps = map[int]bool{2: true}
+	_, err := r.AdjustShortTermFuelTrim(3)
+	then.AssertThat(t, err, is.Nil())
+
+	entry, _ := r.Journal.GetEntry(1)
+	then.AssertThat(t, entry.Steps, is.EqualTo(3))
+	then.AssertThat(t, entry.After-entry.Before, is.EqualTo(2))
+
+	// only the change reported by the ecu is reversed
+	value, err := r.Undo(1)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, value, is.EqualTo(entry.Before))
+
+	undo, _ := r.Journal.GetEntry(2)
+	then.AssertThat(t, undo.Steps, is.EqualTo(-2))
+}
+
+func Test_journal_UndoFailedAdjustment(t *testing.T) {
+	r := getSteppingECU(t)
+	reader := r.ecuReader.(*steppingECUReader)
+
+	// the last step fails, the ecu does not report the value after the adjustment
+	reader.failSteps = map[int]bool{2: true}
+	_, err := r.AdjustShortTermFuelTrim(2)
+	then.AssertThat(t, err, is.Not(is.Nil()))
+
+	entry, _ := r.Journal.GetEntry(1)
+	then.AssertThat(t, entry.Error, is.Not(is.EqualTo("")))
+
+	_, err = r.Undo(1)
+	then.AssertThat(t, err, is.Not(is.Nil()))
+	then.AssertThat(t, len(r.Journal.GetEntries()), is.EqualTo(1))
+}
+
+func Test_journal_OpenTwice(t *testing.T) {
+	journal := NewECUJournal()
+
+	first := filepath.Join(t.TempDir(), "FIRST.journal")
+	err := journal.Open(first)
+	then.AssertThat(t, err, is.Nil())
+
+	// the open file must be closed before another is attached
+	err = journal.Open(filepath.Join(t.TempDir(), "SECOND.journal"))
+	then.AssertThat(t, err, is.Not(is.Nil()))
+	then.AssertThat(t, journal.Filepath, is.EqualTo(first))
+
+	journal.Close()
+
+	second := filepath.Join(t.TempDir(), "SECOND.journal")
+	err = journal.Open(second)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, journal.Filepath, is.EqualTo(second))
+	journal.Close()
+}
+
+func Test_journal_WritesToFile(t *testing.T) {
+	r := getSteppingECU(t)
+
+	file := filepath.Join(t.TempDir(), "TEST.journal")
+	err := r.Journal.Open(file)
+	then.AssertThat(t, err, is.Nil())
+
+	_, _ = r.AdjustIdleSpeed(1)
+	_ = r.TestFan1(true)
+	r.Journal.Close()
+
+	f, err := os.Open(file)
+	then.AssertThat(t, err, is.Nil())
+	defer f.Close()
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		var entry JournalEntry
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		then.AssertThat(t, err, is.Nil())
+		entries = append(entries, entry)
+	}
+
+	then.AssertThat(t, len(entries), is.EqualTo(2))
+	then.AssertThat(t, entries[0].Operation, is.EqualTo("IdleSpeed"))
+	then.AssertThat(t, entries[1].Operation, is.EqualTo("Fan1"))
+}
+
+func Test_journal_getJournalFilepath(t *testing.T) {
+	then.AssertThat(t, getJournalFilepath("logs/ABNMP003-2022-03-04-113900.csv"), is.EqualTo("logs/ABNMP003-2022-03-04-113900.journal"))
+}
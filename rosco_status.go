@@ -19,24 +19,38 @@ func (ecu *ECUReaderInstance) SendHeartbeat() error {
 // ResetAdjustments resets the adjustable values
 func (ecu *ECUReaderInstance) ResetAdjustments() error {
 	log.Info("resetting  ecu adjustable values ")
-	return ecu.updateECUState(MEMSResetAdj)
+	return ecu.resetECUState(MEMSResetAdj)
 }
 
 // ResetECU clears fault codes. resets adjustable values and learnt values
 func (ecu *ECUReaderInstance) ResetECU() error {
 	log.Info("resetting ecu")
-	return ecu.updateECUState(MEMSResetECU)
+	return ecu.resetECUState(MEMSResetECU)
 }
 
 // ClearFaults clears fault codes
 func (ecu *ECUReaderInstance) ClearFaults() error {
 	log.Info("clearing ecu recorded faults ")
-	return ecu.updateECUState(MEMSClearFaults)
+	return ecu.resetECUState(MEMSClearFaults)
 }
 
 // Updates ECU state, is used to clear the state for the reset commands or emitting a state keep-alive heartbeat
 // Returns success of the operation
 func (ecu *ECUReaderInstance) updateECUState(command []byte) error {
+	_, err := ecu.sendECUStateCommand(command)
+	return err
+}
+
+// Resets the ECU state and records the reset in the journal
+func (ecu *ECUReaderInstance) resetECUState(command []byte) error {
+	before := ecu.readResetValue(command)
+	data, err := ecu.sendECUStateCommand(command)
+	ecu.journalReset(command, data, before, ecu.readResetValue(command), err)
+
+	return err
+}
+
+func (ecu *ECUReaderInstance) sendECUStateCommand(command []byte) ([]byte, error) {
 	var err error
 	var data []byte
 
@@ -44,7 +58,7 @@ func (ecu *ECUReaderInstance) updateECUState(command []byte) error {
 		log.Infof("updated ECU state with clear, reset or heartbeat (%X)", data)
 	}
 
-	return err
+	return data, err
 }
 
 func (ecu *ECUReaderInstance) resetStatus() {
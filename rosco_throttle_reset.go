@@ -0,0 +1,232 @@
+package rosco
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// Closed throttle reset procedure
+//
+// The closed throttle position is relearnt by fully depressing and releasing the accelerator pedal
+// 5 times within 10 seconds of turning on the ignition and then waiting 20 seconds.
+// The procedure is monitored from the live dataframes, pedal presses are detected from the
+// throttle angle and throttle pot voltage.
+
+// ThrottleResetStage identifies the stage of the procedure an event relates to
+type ThrottleResetStage string
+
+const (
+	ThrottleResetStart    ThrottleResetStage = "Start"
+	ThrottleResetPress    ThrottleResetStage = "Press"
+	ThrottleResetWait     ThrottleResetStage = "Wait"
+	ThrottleResetComplete ThrottleResetStage = "Complete"
+	ThrottleResetFailed   ThrottleResetStage = "Failed"
+)
+
+// ThrottleResetEvent is emitted to prompt the user and report progress through the procedure
+type ThrottleResetEvent struct {
+	Stage     ThrottleResetStage
+	Message   string
+	Presses   int
+	Elapsed   time.Duration
+	Remaining time.Duration
+}
+
+// ThrottleResetCallback receives the procedure events
+type ThrottleResetCallback func(event ThrottleResetEvent)
+
+// ThrottleResetConfig controls the closed throttle reset procedure
+type ThrottleResetConfig struct {
+	// RequiredPresses is the number of full pedal presses required
+	RequiredPresses int
+	// PressWindow is the time from the start of the procedure in which all the presses must be completed
+	PressWindow time.Duration
+	// WaitTime is the time to wait with the pedal released after the presses
+	WaitTime time.Duration
+	// PressedThrottleAngle is the throttle angle at which the pedal is considered fully pressed
+	PressedThrottleAngle int
+	// PressedThrottlePot is the throttle pot voltage at which the pedal is considered fully pressed
+	PressedThrottlePot float32
+	// SampleInterval is the delay between dataframe reads
+	SampleInterval time.Duration
+	// Clock returns the current time, defaults to time.Now
+	Clock func() time.Time
+}
+
+// ThrottleResetResult is the outcome of the closed throttle reset procedure
+type ThrottleResetResult struct {
+	Success         bool
+	Reason          string
+	Presses         int
+	PressTimes      []time.Duration
+	IdleSwitch      bool
+	ThrottleAngle   int
+	JackCountBefore int
+	JackCountAfter  int
+}
+
+// NewThrottleResetConfig returns the configuration for the standard reset procedure
+func NewThrottleResetConfig() ThrottleResetConfig {
+	return ThrottleResetConfig{
+		RequiredPresses:      5,
+		PressWindow:          time.Second * 10,
+		WaitTime:             time.Second * 20,
+		PressedThrottleAngle: 60,
+		PressedThrottlePot:   3.5,
+		SampleInterval:       time.Millisecond * 200,
+		Clock:                time.Now,
+	}
+}
+
+// RunThrottleReset guides the user through the closed throttle reset procedure
+// the procedure should be started as soon as the ignition has been turned on, with the engine off
+func (ecu *ECUReaderInstance) RunThrottleReset(config ThrottleResetConfig, callback ThrottleResetCallback) (ThrottleResetResult, error) {
+	var err error
+	var data MemsData
+
+	result := ThrottleResetResult{}
+
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+
+	if callback == nil {
+		callback = func(event ThrottleResetEvent) {}
+	}
+
+	if data, err = ecu.GetDataframes(); err != nil {
+		return ecu.failThrottleReset(result, callback, err)
+	}
+
+	if data.EngineRPM > engineNotRunningRPM {
+		return ecu.failThrottleReset(result, callback, fmt.Errorf("engine must be off, turn the ignition on without starting the engine"))
+	}
+
+	result.JackCountBefore = data.JackCount
+	startedAt := config.Clock()
+
+	callback(ThrottleResetEvent{
+		Stage:     ThrottleResetStart,
+		Message:   fmt.Sprintf("fully press and release the accelerator pedal %d times within %s", config.RequiredPresses, config.PressWindow),
+		Remaining: config.PressWindow,
+	})
+
+	// count the pedal presses, a press is complete once the pedal has been fully pressed and released
+	pressed := false
+
+	for result.Presses < config.RequiredPresses {
+		time.Sleep(config.SampleInterval)
+
+		if data, err = ecu.GetDataframes(); err != nil {
+			return ecu.failThrottleReset(result, callback, err)
+		}
+
+		elapsed := config.Clock().Sub(startedAt)
+
+		if elapsed > config.PressWindow {
+			return ecu.failThrottleReset(result, callback, fmt.Errorf("only %d of %d presses completed within %s", result.Presses, config.RequiredPresses, config.PressWindow))
+		}
+
+		if isPedalFullyPressed(data, config) {
+			pressed = true
+		} else if pressed && ecu.isPedalReleased(data) {
+			pressed = false
+			result.Presses++
+			result.PressTimes = append(result.PressTimes, elapsed)
+
+			callback(ThrottleResetEvent{
+				Stage:     ThrottleResetPress,
+				Message:   fmt.Sprintf("press %d of %d detected at %.1fs", result.Presses, config.RequiredPresses, elapsed.Seconds()),
+				Presses:   result.Presses,
+				Elapsed:   elapsed,
+				Remaining: config.PressWindow - elapsed,
+			})
+		}
+	}
+
+	// wait with the pedal released whilst the ecu learns the closed throttle position
+	waitStartedAt := config.Clock()
+
+	callback(ThrottleResetEvent{
+		Stage:     ThrottleResetWait,
+		Message:   fmt.Sprintf("release the pedal and wait %s", config.WaitTime),
+		Presses:   result.Presses,
+		Remaining: config.WaitTime,
+	})
+
+	for {
+		time.Sleep(config.SampleInterval)
+
+		if data, err = ecu.GetDataframes(); err != nil {
+			return ecu.failThrottleReset(result, callback, err)
+		}
+
+		if !ecu.isPedalReleased(data) {
+			return ecu.failThrottleReset(result, callback, fmt.Errorf("pedal pressed during the wait, restart the procedure"))
+		}
+
+		waited := config.Clock().Sub(waitStartedAt)
+
+		if waited >= config.WaitTime {
+			break
+		}
+
+		callback(ThrottleResetEvent{
+			Stage:     ThrottleResetWait,
+			Message:   fmt.Sprintf("waiting, %.0fs remaining", (config.WaitTime - waited).Seconds()),
+			Presses:   result.Presses,
+			Elapsed:   waited,
+			Remaining: config.WaitTime - waited,
+		})
+	}
+
+	// confirm the closed throttle position has been learnt
+	// with the pedal released the idle switch should be off and the jack count should not have increased
+	result.IdleSwitch = data.IdleSwitch
+	result.ThrottleAngle = data.ThrottleAngle
+	result.JackCountAfter = data.JackCount
+
+	if result.IdleSwitch {
+		return ecu.failThrottleReset(result, callback, fmt.Errorf("idle switch is active with the throttle closed"))
+	}
+
+	if result.JackCountAfter > result.JackCountBefore {
+		return ecu.failThrottleReset(result, callback, fmt.Errorf("jack count increased from %d to %d", result.JackCountBefore, result.JackCountAfter))
+	}
+
+	result.Success = true
+
+	callback(ThrottleResetEvent{
+		Stage:   ThrottleResetComplete,
+		Message: "closed throttle position reset successfully",
+		Presses: result.Presses,
+	})
+
+	log.Infof("closed throttle reset completed (%+v)", result)
+
+	return result, nil
+}
+
+func (ecu *ECUReaderInstance) failThrottleReset(result ThrottleResetResult, callback ThrottleResetCallback, err error) (ThrottleResetResult, error) {
+	result.Success = false
+	result.Reason = err.Error()
+
+	callback(ThrottleResetEvent{
+		Stage:   ThrottleResetFailed,
+		Message: err.Error(),
+		Presses: result.Presses,
+	})
+
+	log.Warnf("closed throttle reset failed (%s)", err)
+
+	return result, err
+}
+
+func isPedalFullyPressed(data MemsData, config ThrottleResetConfig) bool {
+	return data.ThrottleAngle >= config.PressedThrottleAngle || data.ThrottlePotSensor >= config.PressedThrottlePot
+}
+
+func (ecu *ECUReaderInstance) isPedalReleased(data MemsData) bool {
+	return data.ThrottleAngle <= ecu.Diagnostics.profile.DefaultIdleThrottleAngle
+}
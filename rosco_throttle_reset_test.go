@@ -0,0 +1,162 @@
+package rosco
+
+import (
+	"encoding/hex"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	closedThrottle  = 0x14
+	pressedThrottle = 0xc8
+)
+
+// scriptedThrottleReader plays back a sequence of throttle angles with the engine off
+type scriptedThrottleReader struct {
+	throttle  []byte
+	position  int
+	rpm       int
+	jackCount byte
+}
+
+func newScriptedThrottleReader(presses int, wait int) *scriptedThrottleReader {
+	responseMap = createResponseMap()
+	r := &scriptedThrottleReader{jackCount: 0x1f}
+
+	// initial frame read before the procedure starts
+	r.throttle = append(r.throttle, closedThrottle)
+
+	for i := 0; i < presses; i++ {
+		r.throttle = append(r.throttle, pressedThrottle, closedThrottle)
+	}
+
+	for i := 0; i < wait; i++ {
+		r.throttle = append(r.throttle, closedThrottle)
+	}
+
+	return r
+}
+
+func (r *scriptedThrottleReader) Connect() (bool, error) {
+	return true, nil
+}
+
+func (r *scriptedThrottleReader) Disconnect() error {
+	return nil
+}
+
+func (r *scriptedThrottleReader) SendAndReceive(command []byte) ([]byte, error) {
+	throttle := byte(closedThrottle)
+	if r.position < len(r.throttle) {
+		throttle = r.throttle[r.position]
+	}
+
+	switch strings.ToUpper(hex.EncodeToString(command)) {
+	case "80":
+		data := append([]byte{}, responseMap["80"]...)
+		data[2] = byte(r.rpm >> 8)
+		data[3] = byte(r.rpm)
+		if throttle != closedThrottle {
+			data[11] = IdleSwitchActive
+		}
+		return data, nil
+	case "7D":
+		data := append([]byte{}, responseMap["7D"]...)
+		data[3] = throttle
+		data[32] = r.jackCount
+		r.position++
+		return data, nil
+	}
+
+	return generateECUResponse(hex.EncodeToString(command)), nil
+}
+
+// steppedClock advances by the interval each time the time is requested
+func steppedClock(interval time.Duration) func() time.Time {
+	now := time.Date(2022, 3, 4, 11, 39, 0, 0, time.UTC)
+
+	return func() time.Time {
+		now = now.Add(interval)
+		return now
+	}
+}
+
+func getThrottleResetConfig() ThrottleResetConfig {
+	config := NewThrottleResetConfig()
+	config.SampleInterval = 0
+	config.Clock = steppedClock(time.Millisecond * 500)
+
+	return config
+}
+
+func Test_throttleReset_Success(t *testing.T) {
+	var events []ThrottleResetEvent
+
+	r := NewECUReaderInstance()
+	r.ecuReader = newScriptedThrottleReader(5, 45)
+
+	result, err := r.RunThrottleReset(getThrottleResetConfig(), func(event ThrottleResetEvent) {
+		events = append(events, event)
+	})
+
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, result.Success, is.True())
+	then.AssertThat(t, result.Presses, is.EqualTo(5))
+	then.AssertThat(t, len(result.PressTimes), is.EqualTo(5))
+	then.AssertThat(t, result.IdleSwitch, is.False())
+	then.AssertThat(t, result.JackCountBefore, is.EqualTo(31))
+	then.AssertThat(t, result.JackCountAfter, is.EqualTo(31))
+
+	then.AssertThat(t, events[0].Stage, is.EqualTo(ThrottleResetStart))
+	then.AssertThat(t, events[1].Stage, is.EqualTo(ThrottleResetPress))
+	then.AssertThat(t, events[5].Presses, is.EqualTo(5))
+	then.AssertThat(t, events[6].Stage, is.EqualTo(ThrottleResetWait))
+	then.AssertThat(t, events[len(events)-1].Stage, is.EqualTo(ThrottleResetComplete))
+}
+
+func Test_throttleReset_TooFewPresses(t *testing.T) {
+	var last ThrottleResetEvent
+
+	r := NewECUReaderInstance()
+	r.ecuReader = newScriptedThrottleReader(3, 45)
+
+	result, err := r.RunThrottleReset(getThrottleResetConfig(), func(event ThrottleResetEvent) {
+		last = event
+	})
+
+	then.AssertThat(t, err, is.Not(is.Nil()))
+	then.AssertThat(t, result.Success, is.False())
+	then.AssertThat(t, result.Presses, is.EqualTo(3))
+	then.AssertThat(t, last.Stage, is.EqualTo(ThrottleResetFailed))
+}
+
+func Test_throttleReset_PressedDuringWait(t *testing.T) {
+	reader := newScriptedThrottleReader(5, 5)
+	reader.throttle = append(reader.throttle, pressedThrottle)
+
+	r := NewECUReaderInstance()
+	r.ecuReader = reader
+
+	result, err := r.RunThrottleReset(getThrottleResetConfig(), nil)
+
+	then.AssertThat(t, err, is.Not(is.Nil()))
+	then.AssertThat(t, result.Success, is.False())
+	then.AssertThat(t, result.Presses, is.EqualTo(5))
+}
+
+func Test_throttleReset_EngineRunning(t *testing.T) {
+	reader := newScriptedThrottleReader(5, 45)
+	reader.rpm = 850
+
+	r := NewECUReaderInstance()
+	r.ecuReader = reader
+
+	result, err := r.RunThrottleReset(getThrottleResetConfig(), nil)
+
+	then.AssertThat(t, err, is.Not(is.Nil()))
+	then.AssertThat(t, result.Success, is.False())
+	then.AssertThat(t, result.Presses, is.EqualTo(0))
+}
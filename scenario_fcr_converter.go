@@ -43,7 +43,12 @@ func (scenario *ScenarioFile) ConvertLogToScenario(id string) error {
 		scenario.RawData = responder.RawData
 		scenario.Summary = fmt.Sprintf("Scenario file created from %s", id)
 		if scenario.Count > 0 {
-			scenario.Date, _ = ConvertTimeFieldToDate(responder.RawData[0].Time)
+			if date, err := ConvertTimeFieldToDate(responder.RawData[0].Time); err == nil {
+				// the scenario is dated from the first recorded time of the log
+				scenario.Date = getScenarioTimestamp(responder.Description.Date, date, time.Time{})
+			} else {
+				log.Warnf("unable to read the start time of %s, using the conversion date (%s)", filename, err)
+			}
 		}
 
 		log.Infof("converted %s to %s", filename, scenario.filePath)
@@ -3,7 +3,9 @@ package rosco
 import (
 	"github.com/corbym/gocrest/is"
 	"github.com/corbym/gocrest/then"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func Test_scenario_NewScenarioFile(t *testing.T) {
@@ -20,6 +22,9 @@ func Test_scenario_ConvertLogToScenario(t *testing.T) {
 	err := s.ConvertLogToScenario("testdata/nofaults.csv")
 	then.AssertThat(t, err, is.Nil())
 	then.AssertThat(t, s.Count, is.EqualTo(338))
+
+	// the log was recorded without a date, the scenario is placed on the undated scenario date
+	then.AssertThat(t, s.Date, is.EqualTo(time.Date(1970, 1, 1, 12, 35, 55, 186000000, time.UTC)))
 }
 
 func Test_scenario_ReadScenarioFile(t *testing.T) {
@@ -33,9 +38,10 @@ func Test_scenario_ReadScenarioFile(t *testing.T) {
 }
 
 func Test_scenario_ConvertAndSaveScenarioFile(t *testing.T) {
-	// create a new scenario file
-	s := NewScenarioFile("testdata/nofaults.fcr")
-	then.AssertThat(t, s.filePath, is.EqualTo("testdata/nofaults.fcr"))
+	// create a new scenario file outside of the testdata
+	filename := filepath.Join(t.TempDir(), "nofaults.fcr")
+	s := NewScenarioFile(filename)
+	then.AssertThat(t, s.filePath, is.EqualTo(filename))
 
 	// convert the log (csv)
 	err := s.ConvertLogToScenario("testdata/nofaults.csv")
@@ -44,4 +50,10 @@ func Test_scenario_ConvertAndSaveScenarioFile(t *testing.T) {
 
 	err = s.Write()
 	then.AssertThat(t, err, is.Nil())
+
+	// the saved scenario is read back with the same date
+	saved := NewScenarioFile(filename)
+	then.AssertThat(t, saved.Read(), is.Nil())
+	then.AssertThat(t, saved.Count, is.EqualTo(s.Count))
+	then.AssertThat(t, saved.Date.Equal(s.Date), is.True())
 }
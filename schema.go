@@ -0,0 +1,405 @@
+package rosco
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+const (
+	// dataframe commands the channels are read from
+	frame80 = byte(0x80)
+	frame7d = byte(0x7d)
+	// derived channels are calculated from the decoded values and not read from a dataframe
+	derivedFrame = byte(0x00)
+	// number of bytes reported by the dataframes, includes the size byte
+	frame80Size = 0x1c
+	frame7dSize = 0x20
+)
+
+// DataframeChannel describes how a value in the MemsData is decoded from the raw ECU dataframes
+// the decoded value is calculated as ((raw * Scale) / Divisor) + Offset
+type DataframeChannel struct {
+	// Name of the MemsData field the channel is decoded into
+	Name string
+	// Frame is the dataframe command the channel is read from, 0x80 or 0x7d
+	Frame byte
+	// Register is the position of the value in the dataframe, e.g. 0x07 for 80x07
+	Register int
+	// Width is the number of bytes, 2 byte values are big endian
+	Width int
+	// Scale multiplies the raw value, 0 is treated as 1
+	Scale float64
+	// Divisor divides the scaled value, 0 is treated as 1
+	Divisor float64
+	// Offset is added to the scaled value
+	Offset float64
+	// Decimals rounds floating point values, 0 leaves the value unrounded
+	Decimals int
+	// Mask selects the bits of boolean values, 0 is true for any non-zero value
+	Mask byte
+	Unit string
+	// Column is the CSV log file column, channels without a column are not logged
+	Column      string
+	Description string
+}
+
+// dataframeSchema defines the decoding of every channel in the 0x80 and 0x7d dataframes
+// the order of the channels defines the order of the columns in the CSV log file
+var dataframeSchema = []DataframeChannel{
+	{Name: "EngineRPM", Frame: frame80, Register: 0x01, Width: 2, Unit: "rpm", Column: "80x01-02_engine-rpm",
+		Description: "engine rpm"},
+	{Name: "CoolantTemp", Frame: frame80, Register: 0x03, Width: 1, Offset: -55, Unit: "°C", Column: "80x03_coolant_temp",
+		Description: "temperature in degrees Celsius read from the Coolant Temperature Sensor (CTS). This sensor can be found under the injector unit. An essential value in the air:fuel ratio calculation"},
+	{Name: "AmbientTemp", Frame: frame80, Register: 0x04, Width: 1, Offset: -55, Unit: "°C", Column: "80x04_ambient_temp",
+		Description: "not used by the ECU, always returns 255"},
+	{Name: "IntakeAirTemp", Frame: frame80, Register: 0x05, Width: 1, Offset: -55, Unit: "°C", Column: "80x05_intake_air_temp",
+		Description: "temperature in degrees Celsius read from the Air Intake Temperature Sensor (ATS). This sensor can be found under the air filter. An essential value in the air:fuel ratio calculation"},
+	{Name: "FuelTemp", Frame: frame80, Register: 0x06, Width: 1, Offset: -55, Unit: "°C", Column: "80x06_fuel_temp",
+		Description: "not used by the ECU, always returns 255"},
+	{Name: "ManifoldAbsolutePressure", Frame: frame80, Register: 0x07, Width: 1, Unit: "kPa", Column: "80x07_map_kpa",
+		Description: "manifold absolute pressure (MAP). Reads pressure from back of the injector unit via the vacuum pipes and fuel trap. An essential value in the air:fuel ratio calculation"},
+	{Name: "BatteryVoltage", Frame: frame80, Register: 0x08, Width: 1, Divisor: 10, Unit: "V", Column: "80x08_battery_voltage",
+		Description: "the battery voltage. A figure <12 volts will cause running issues"},
+	{Name: "ThrottlePotSensor", Frame: frame80, Register: 0x09, Width: 1, Scale: 0.02, Decimals: 2, Unit: "V", Column: "80x09_throttle_pot",
+		Description: "throttle potentiometer position. used by the ECU do determine throttle position when controlling idle speed"},
+	{Name: "IdleSwitch", Frame: frame80, Register: 0x0A, Width: 1, Mask: IdleSwitchActive, Column: "80x0A_idle_switch",
+		Description: "shows the state of the throttle switch, fitted on early vehicles. On systems without an actual throttle switch the value shown indicates whether the MEMS ECU has calculated that the throttle is closed by using the throttle position sensor. If the switch shows 'ON' when the throttle is closed, then the vehicle will not idle correctly and the closed throttle position may need to be reset. This procedure is performed by fully depressing and releasing the accelerator pedal 5 times within 10 or less seconds of turning on the ignition and then waiting 20 seconds."},
+	{Name: "AirconSwitch", Frame: frame80, Register: 0x0B, Width: 1, Column: "80x0B_uk1",
+		Description: "air conditioning switch"},
+	{Name: "ParkNeutralSwitch", Frame: frame80, Register: 0x0C, Width: 1, Column: "80x0C_park_neutral_switch",
+		Description: "used on vehicles with an automatic gearbox"},
+	{Name: "DTC0", Frame: frame80, Register: 0x0D, Width: 1, Column: "80x0D-0E_fault_codes",
+		Description: "ECU fault codes:<br>Coolant temp sensor fault (Code 1)<br>Inlet air temp sensor fault (Code 2)<br>Turbo overboost<br>Ambient temp sensor fault<br>Fuel rail temp sensor fault<br>Knock detected<br>Coolant temp gauge fault<br>Fuel pump circuit fault (Code 10)<br>Air con clutch fault<br>Purge valve fault<br>MAP sensor fault<br>Boost valve fault<br>Throttle pot circuit fault (Code 16)"},
+	{Name: "DTC1", Frame: frame80, Register: 0x0E, Width: 1,
+		Description: "ECU fault codes:<br>Fuel pump circuit fault (Code 10)<br>Air con clutch fault<br>Purge valve fault<br>MAP sensor fault<br>Boost valve fault<br>Throttle pot circuit fault (Code 16)"},
+	{Name: "IdleSetPoint", Frame: frame80, Register: 0x0F, Width: 1, Column: "80x0F_idle_set_point",
+		Description: "adjusts the idle rpm by the value shown. Adjusting idle speed will modify this value"},
+	{Name: "IdleHot", Frame: frame80, Register: 0x10, Width: 1, Unit: "steps", Column: "80x10_idle_hot",
+		Description: "the number of IACV steps from fully closed (0) which the ECU has learned as the correct position to maintain the target idle speed with a fully warmed up engine. If this value is outside the range 10 - 50 steps, then this is an indication of a possible fault condition or poor adjustment."},
+	{Name: "Uk8011", Frame: frame80, Register: 0x11, Width: 1, Column: "80x11_uk2",
+		Description: "unknown value"},
+	{Name: "IACPosition", Frame: frame80, Register: 0x12, Width: 1, Unit: "steps", Column: "80x12_iac_position",
+		Description: "Inlet Air Control valve (IACV) position (relates to expected Stepper Motor position)"},
+	{Name: "IdleSpeedDeviation", Frame: frame80, Register: 0x13, Width: 2, Unit: "rpm", Column: "80x13-14_idle_error",
+		Description: "idle speed offset (also known as idle speed deviation)"},
+	{Name: "IgnitionAdvanceOffset80", Frame: frame80, Register: 0x15, Width: 1, Column: "80x15_ignition_advance_offset",
+		Description: "adjustment to the ignition timing"},
+	{Name: "IgnitionAdvance", Frame: frame80, Register: 0x16, Width: 1, Divisor: 2, Offset: -24, Unit: "°", Column: "80x16_ignition_advance",
+		Description: "ignition advance, value of 128 = 0"},
+	{Name: "CoilTime", Frame: frame80, Register: 0x17, Width: 2, Scale: 0.002, Decimals: 2, Unit: "ms", Column: "80x17-18_coil_time",
+		Description: "coil timing in ms"},
+	{Name: "CrankshaftPositionSensor", Frame: frame80, Register: 0x19, Width: 1, Column: "80x19_crankshaft_position_sensor",
+		Description: "position of the crankshaft from the position sensor (CPS)"},
+	{Name: "Uk801a", Frame: frame80, Register: 0x1A, Width: 1, Column: "80x1A_uk4",
+		Description: "unknown value"},
+	{Name: "Uk801b", Frame: frame80, Register: 0x1B, Width: 1, Column: "80x1B_uk5",
+		Description: "unknown value"},
+	{Name: "IgnitionSwitch", Frame: frame7d, Register: 0x01, Width: 1, Column: "7dx01_ignition_switch",
+		Description: "status of the ignition switch"},
+	{Name: "ThrottleAngle", Frame: frame7d, Register: 0x02, Width: 1, Scale: 6, Divisor: 10, Unit: "°", Column: "7dx02_throttle_angle",
+		Description: "shows the position of the throttle disc obtained from the MEMS ECU using the throttle potentiometer. This value should change from a low value to a high value as the throttle pedal is depressed. A value of 14 or less indicates the throttle is closed."},
+	{Name: "Uk7d03", Frame: frame7d, Register: 0x03, Width: 1, Column: "7dx03_uk6",
+		Description: "unknown value"},
+	{Name: "AirFuelRatio", Frame: frame7d, Register: 0x04, Width: 1, Divisor: 10, Unit: "AFR", Column: "7dx04_air_fuel_ratio",
+		Description: "the current air:fuel ratio"},
+	{Name: "DTC2", Frame: frame7d, Register: 0x05, Width: 1, Column: "7dx05_dtc2",
+		Description: "diagnostic trouble code:<br>Lambda heater relay fault<br>Secondary trigger sync<br>Fan 1 control fault<br>bit 6 (fan 2 control) is not decoded, it is set at rest on some ECUs"},
+	{Name: "LambdaVoltage", Frame: frame7d, Register: 0x06, Width: 1, Scale: 5, Unit: "mV", Column: "7dx06_lambda_voltage",
+		Description: "the voltage read from the lambda sensor"},
+	{Name: "LambdaFrequency", Frame: frame7d, Register: 0x07, Width: 1, Column: "7dx07_lambda_sensor_frequency",
+		Description: "not used by the ECU, value reads 255"},
+	{Name: "LambdaDutycycle", Frame: frame7d, Register: 0x08, Width: 1, Column: "7dx08_lambda_sensor_dutycycle",
+		Description: "not used by the ECU, value reads 255"},
+	{Name: "LambdaStatus", Frame: frame7d, Register: 0x09, Width: 1, Column: "7dx09_lambda_sensor_status",
+		Description: "ECU O2 circuit status, 1 active"},
+	{Name: "ClosedLoop", Frame: frame7d, Register: 0x0A, Width: 1, Column: "7dx0A_closed_loop",
+		Description: "ECU has entered closed loop and uses the lambda sensor for determining air:fuel ratio"},
+	{Name: "LongTermFuelTrim", Frame: frame7d, Register: 0x0B, Width: 1, Offset: -128, Column: "7dx0B_long_term_fuel_trim",
+		Description: "long term fuel trim (LTFT) displays ECU value to adjust fuelling. value of 128 = 0"},
+	{Name: "ShortTermFuelTrim", Frame: frame7d, Register: 0x0C, Width: 1, Offset: -100, Column: "7dx0C_short_term_fuel_trim",
+		Description: "short term fuel trim (STFT) displays ECU value to adjust fuelling"},
+	{Name: "FuelTrimCorrection", Frame: frame7d, Register: 0x0C, Width: 1, Offset: -100,
+		Description: "fuel trim correction, derived from the short term fuel trim"},
+	{Name: "CarbonCanisterPurgeValve", Frame: frame7d, Register: 0x0D, Width: 1, Column: "7dx0D_carbon_canister_dutycycle",
+		Description: "not used by ECU, value reads 0"},
+	{Name: "DTC3", Frame: frame7d, Register: 0x0E, Width: 1, Column: "7dx0E_dtc3",
+		Description: "diagnostic trouble code:<br>Primary trigger sync, not decoded if the value reads 255"},
+	{Name: "IdleBasePosition", Frame: frame7d, Register: 0x0F, Width: 1, Column: "7dx0F_idle_base_pos",
+		Description: "the base value to offset idle position from"},
+	{Name: "Uk7d10", Frame: frame7d, Register: 0x10, Width: 1, Column: "7dx10_uk7",
+		Description: "unknown value"},
+	{Name: "DTC4", Frame: frame7d, Register: 0x11, Width: 1, Column: "7dx11_dtc4",
+		Description: "diagnostic trouble code - unknown codes"},
+	{Name: "IgnitionAdvanceOffset7d", Frame: frame7d, Register: 0x12, Width: 1, Offset: -48, Column: "7dx12_ignition_advance2",
+		Description: "ignition advance"},
+	{Name: "IdleSpeedOffset", Frame: frame7d, Register: 0x13, Width: 1, Column: "7dx13_idle_speed_offset",
+		Description: "idle speed offset used to adjust idle speed"},
+	{Name: "Uk7d14", Frame: frame7d, Register: 0x14, Width: 1, Column: "7dx14_idle_error2",
+		Description: "idle error"},
+	{Name: "Uk7d15", Frame: frame7d, Register: 0x15, Width: 1, Column: "7dx14-15_uk10",
+		Description: "unknown value"},
+	{Name: "DTC5", Frame: frame7d, Register: 0x16, Width: 1, Column: "7dx16_dtc5",
+		Description: "diagnostic trouble code - unknown codes"},
+	{Name: "Uk7d17", Frame: frame7d, Register: 0x17, Width: 1, Column: "7dx17_uk11",
+		Description: "unknown value"},
+	{Name: "Uk7d18", Frame: frame7d, Register: 0x18, Width: 1, Column: "7dx18_uk12",
+		Description: "unknown value"},
+	{Name: "Uk7d19", Frame: frame7d, Register: 0x19, Width: 1, Column: "7dx19_uk13",
+		Description: "unknown value"},
+	{Name: "Uk7d1a", Frame: frame7d, Register: 0x1A, Width: 1, Column: "7dx1A_uk14",
+		Description: "unknown value"},
+	{Name: "Uk7d1b", Frame: frame7d, Register: 0x1B, Width: 1, Column: "7dx1B_uk15",
+		Description: "unknown value"},
+	{Name: "Uk7d1c", Frame: frame7d, Register: 0x1C, Width: 1, Column: "7dx1C_uk16",
+		Description: "unknown value"},
+	{Name: "Uk7d1d", Frame: frame7d, Register: 0x1D, Width: 1, Column: "7dx1D_uk17",
+		Description: "unknown value"},
+	{Name: "Uk7d1e", Frame: frame7d, Register: 0x1E, Width: 1, Column: "7dx1E_uk18",
+		Description: "unknown value"},
+	{Name: "JackCount", Frame: frame7d, Register: 0x1F, Width: 1, Column: "7dx1F_uk19",
+		Description: "number of times the idle air control stepper motor has been re-referenced (jacked)"},
+	{Name: "Vacuum", Frame: derivedFrame, Unit: "kPa", Column: "vacuum",
+		Description: "manifold vacuum, the difference between the ambient pressure (MAP read at key-on before the engine starts) and the MAP"},
+	{Name: "EngineLoad", Frame: derivedFrame, Unit: "%", Column: "engine_load",
+		Description: "calculated engine load, the MAP as a percentage of the ambient pressure"},
+	{Name: "Lambda", Frame: derivedFrame, Unit: "λ", Column: "lambda",
+		Description: "lambda calculated from the air:fuel ratio, air:fuel ratio / 14.7"},
+	{Name: "MixtureState", Frame: derivedFrame, Column: "mixture",
+		Description: "rich, lean or stoichiometric. Determined by the lambda sensor voltage when the O2 system is active, otherwise by the calculated lambda"},
+	{Name: "FuelFlow", Frame: derivedFrame, Unit: "L/h", Column: "fuel_flow",
+		Description: "estimated fuel consumption in litres per hour calculated from the RPM, MAP, intake air temperature and air:fuel ratio"},
+}
+
+// GetDataframeSchema returns the channel definitions for the 0x80 and 0x7d dataframes
+func GetDataframeSchema() []DataframeChannel {
+	return append([]DataframeChannel{}, dataframeSchema...)
+}
+
+// GetDataframeChannel returns the channel definition for the MemsData field
+func GetDataframeChannel(name string) (DataframeChannel, bool) {
+	for _, channel := range dataframeSchema {
+		if channel.Name == name {
+			return channel, true
+		}
+	}
+
+	return DataframeChannel{}, false
+}
+
+// decodeDataframes decodes the raw 0x80 and 0x7d dataframes into the MemsData using the schema
+func decodeDataframes(d80 []byte, d7d []byte) MemsData {
+	data := MemsData{}
+	value := reflect.ValueOf(&data).Elem()
+
+	for _, channel := range dataframeSchema {
+		if channel.Frame == derivedFrame {
+			continue
+		}
+
+		frame := d80
+		if channel.Frame == frame7d {
+			frame = d7d
+		}
+
+		if raw, ok := channel.readRaw(frame); ok {
+			channel.setValue(value.FieldByName(channel.Name), raw)
+		}
+	}
+
+	return data
+}
+
+// readRaw returns the raw value of the channel from the frame, the first byte of the frame is the command echo
+func (channel DataframeChannel) readRaw(frame []byte) (uint16, bool) {
+	index := channel.Register + 1
+
+	if index+channel.Width > len(frame) {
+		return 0, false
+	}
+
+	if channel.Width == 2 {
+		return binary.BigEndian.Uint16(frame[index:]), true
+	}
+
+	return uint16(frame[index]), true
+}
+
+func (channel DataframeChannel) setValue(field reflect.Value, raw uint16) {
+	scale := channel.getScale()
+	divisor := channel.getDivisor()
+
+	switch field.Kind() {
+	case reflect.Bool:
+		if channel.Mask == 0 {
+			field.SetBool(raw != 0)
+		} else {
+			field.SetBool(byte(raw)&channel.Mask != 0)
+		}
+	case reflect.Uint8:
+		field.SetUint(uint64(raw))
+	case reflect.Int:
+		field.SetInt(int64(math.Round(float64(raw)*scale/divisor + channel.Offset)))
+	case reflect.Float32:
+		value := float32(raw)*float32(scale)/float32(divisor) + float32(channel.Offset)
+
+		if channel.Decimals > 0 {
+			factor := math.Pow(10, float64(channel.Decimals))
+			value = float32(math.Round(float64(value)*factor) / factor)
+		}
+
+		field.SetFloat(float64(value))
+	}
+}
+
+// getRange returns the lowest and highest values that can be decoded for the channel
+func (channel DataframeChannel) getRange() (float64, float64) {
+	max := float64(int(1)<<uint(8*channel.Width) - 1)
+
+	lowest := channel.Offset
+	highest := max*channel.getScale()/channel.getDivisor() + channel.Offset
+
+	return lowest, highest
+}
+
+func (channel DataframeChannel) getScale() float64 {
+	if channel.Scale == 0 {
+		return 1
+	}
+
+	return channel.Scale
+}
+
+func (channel DataframeChannel) getDivisor() float64 {
+	if channel.Divisor == 0 {
+		return 1
+	}
+
+	return channel.Divisor
+}
+
+// formatValue returns the CSV representation of the channel value in the MemsData
+func (channel DataframeChannel) formatValue(data MemsData) string {
+	field := reflect.ValueOf(data).FieldByName(channel.Name)
+
+	switch field.Kind() {
+	case reflect.Bool:
+		return fmt.Sprintf("%t", field.Bool())
+	case reflect.Float32:
+		return fmt.Sprintf("%.2f", field.Float())
+	case reflect.Uint8:
+		return fmt.Sprintf("%d", field.Uint())
+	case reflect.String:
+		return field.String()
+	default:
+		return fmt.Sprintf("%d", field.Int())
+	}
+}
+
+// getValue returns the channel value in the MemsData as a float
+func (channel DataframeChannel) getValue(data MemsData) float64 {
+	field := reflect.ValueOf(data).FieldByName(channel.Name)
+
+	switch field.Kind() {
+	case reflect.Float32:
+		return field.Float()
+	case reflect.Uint8:
+		return float64(field.Uint())
+	case reflect.Int:
+		return float64(field.Int())
+	}
+
+	return 0
+}
+
+// GetCalculation returns a description of the calculation applied to the raw value
+func (channel DataframeChannel) GetCalculation() string {
+	field, _ := reflect.TypeOf(MemsData{}).FieldByName(channel.Name)
+
+	if field.Type.Kind() == reflect.Bool {
+		return "true / false"
+	}
+
+	calculation := "value"
+
+	if channel.Scale != 0 && channel.Scale != 1 {
+		calculation = fmt.Sprintf("%s * %g", calculation, channel.Scale)
+	}
+
+	if channel.Divisor != 0 && channel.Divisor != 1 {
+		calculation = fmt.Sprintf("%s / %g", calculation, channel.Divisor)
+	}
+
+	if channel.Offset > 0 {
+		calculation = fmt.Sprintf("%s + %g", calculation, channel.Offset)
+	} else if channel.Offset < 0 {
+		calculation = fmt.Sprintf("%s - %g", calculation, -channel.Offset)
+	}
+
+	if calculation == "value" {
+		return ""
+	}
+
+	return calculation
+}
+
+// getSchemaCSVHeader returns the CSV log file columns of the logged channels
+// columns of values converted by the unit system are suffixed with the unit
+func getSchemaCSVHeader(units UnitSystem) string {
+	var columns []string
+
+	for _, channel := range dataframeSchema {
+		if channel.Column != "" {
+			column := channel.Column
+
+			if units.IsConverted(channel.Unit) {
+				column = column + unitColumnSuffix[units.getTargetUnit(channel.Unit)]
+			}
+
+			columns = append(columns, column)
+		}
+	}
+
+	return strings.Join(columns, ",")
+}
+
+// getSchemaCSVData returns the values of the logged channels in column order
+func getSchemaCSVData(data MemsData, units UnitSystem) []string {
+	var values []string
+
+	for _, channel := range dataframeSchema {
+		if channel.Column != "" {
+			if units.IsConverted(channel.Unit) {
+				value, _ := units.Convert(channel.getValue(data), channel.Unit)
+				values = append(values, fmt.Sprintf("%.2f", value))
+			} else {
+				values = append(values, channel.formatValue(data))
+			}
+		}
+	}
+
+	return values
+}
+
+// GetSchemaMarkdown returns the logged channels as rows of a markdown table
+// with the columns Column, Description and Calculation Applied
+func GetSchemaMarkdown() string {
+	var rows []string
+
+	for _, channel := range dataframeSchema {
+		if channel.Column == "" {
+			continue
+		}
+
+		calculation := channel.GetCalculation()
+		if channel.Unit != "" {
+			calculation = strings.TrimSpace(fmt.Sprintf("%s (%s)", calculation, channel.Unit))
+		}
+
+		rows = append(rows, fmt.Sprintf("| %s| %s | %s |", channel.Column, channel.Description, calculation))
+	}
+
+	return strings.Join(rows, "\n")
+}
@@ -0,0 +1,204 @@
+package rosco
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EncodeDataframes recreates the raw 0x80 and 0x7d dataframes from the MemsData by inverting
+// the calculations in the dataframe schema. Values that were rounded when decoded encode to the
+// nearest raw value, decoding the encoded dataframes returns the same MemsData channel values.
+func EncodeDataframes(data MemsData) ([]byte, []byte) {
+	d80 := make([]byte, frame80Size+1)
+	d80[0] = frame80
+	d80[1] = frame80Size
+
+	d7d := make([]byte, frame7dSize+1)
+	d7d[0] = frame7d
+	d7d[1] = frame7dSize
+
+	value := reflect.ValueOf(data)
+	encoded := make(map[int]bool)
+
+	for _, channel := range dataframeSchema {
+		if channel.Frame == derivedFrame {
+			continue
+		}
+
+		frame := d80
+		if channel.Frame == frame7d {
+			frame = d7d
+		}
+
+		// derived channels share the register of the channel they are derived from
+		register := int(channel.Frame)<<8 | channel.Register
+		if encoded[register] {
+			continue
+		}
+
+		encoded[register] = true
+		channel.writeRaw(frame, channel.getRawValue(value.FieldByName(channel.Name)))
+	}
+
+	return d80, d7d
+}
+
+// getRawValue inverts the channel calculation, raw = ((value - Offset) * Divisor) / Scale
+func (channel DataframeChannel) getRawValue(field reflect.Value) uint16 {
+	var value float64
+
+	switch field.Kind() {
+	case reflect.Bool:
+		if !field.Bool() {
+			return 0
+		}
+
+		if channel.Mask == 0 {
+			return 1
+		}
+
+		return uint16(channel.Mask)
+	case reflect.Uint8:
+		return uint16(field.Uint())
+	case reflect.Int:
+		value = float64(field.Int())
+	case reflect.Float32:
+		value = field.Float()
+	}
+
+	raw := math.Round((value - channel.Offset) * channel.getDivisor() / channel.getScale())
+
+	// limit the value to the range of the channel width
+	max := float64(int(1)<<uint(8*channel.Width) - 1)
+
+	return uint16(math.Max(0, math.Min(max, raw)))
+}
+
+// writeRaw writes the raw value into the frame, the first byte of the frame is the command echo
+func (channel DataframeChannel) writeRaw(frame []byte, raw uint16) {
+	index := channel.Register + 1
+
+	if index+channel.Width > len(frame) {
+		return
+	}
+
+	if channel.Width == 2 {
+		frame[index] = byte(raw >> 8)
+		frame[index+1] = byte(raw)
+	} else {
+		frame[index] = byte(raw)
+	}
+}
+
+// parseSchemaCSVData creates the MemsData from a CSV log file record using the header to
+// identify the schema channels, columns that are not in the schema are ignored
+func parseSchemaCSVData(header []string, record []string) MemsData {
+	var faults []FaultCode
+
+	data := MemsData{}
+	value := reflect.ValueOf(&data).Elem()
+
+	for i, column := range header {
+		if i >= len(record) {
+			break
+		}
+
+		// DTC1 has no column, the fault codes column names the faults of all the DTC bytes
+		if column == faultCodesColumn {
+			faults = faultCodesFromCSV(record[i])
+			continue
+		}
+
+		for _, channel := range dataframeSchema {
+			if unit, found := channel.getColumnUnit(column); found {
+				s := record[i]
+
+				if unit != channel.Unit {
+					s = convertToMetric(s, unit)
+				}
+
+				setFieldFromString(value.FieldByName(channel.Name), s)
+			}
+		}
+	}
+
+	for _, fault := range faults {
+		field := value.FieldByName(fault.Source)
+		field.SetUint(field.Uint() | uint64(fault.mask))
+	}
+
+	return data
+}
+
+// getColumnUnit returns the unit of the values in the column if it is the channel's column,
+// log files written in imperial or lambda units append the unit to the column name
+func (channel DataframeChannel) getColumnUnit(column string) (string, bool) {
+	if channel.Column == "" {
+		return "", false
+	}
+
+	if column == channel.Column {
+		return channel.Unit, true
+	}
+
+	for unit, suffix := range unitColumnSuffix {
+		if column == channel.Column+suffix {
+			return unit, true
+		}
+	}
+
+	return "", false
+}
+
+// convertToMetric converts the logged value from the unit back to the metric unit of the channel
+func convertToMetric(s string, unit string) string {
+	value, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+
+	if err != nil {
+		return s
+	}
+
+	switch unit {
+	case UnitFahrenheit:
+		value = (value - 32) * 5 / 9
+	case UnitPSI:
+		value = value / kPaToPSI
+	case UnitInHg:
+		value = value / kPaToInHg
+	case UnitLambda:
+		value = value * stoichiometricAFR
+	}
+
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func setFieldFromString(field reflect.Value, s string) {
+	var value float64
+	var err error
+
+	s = strings.TrimSpace(s)
+
+	if value, err = strconv.ParseFloat(s, 64); err != nil {
+		// older logs recorded some of the numeric values as true / false
+		if b, berr := strconv.ParseBool(s); berr == nil && b {
+			value = 1
+		}
+	}
+
+	switch field.Kind() {
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			field.SetBool(b)
+		} else {
+			field.SetBool(value != 0)
+		}
+	case reflect.Uint8:
+		field.SetUint(uint64(math.Max(0, math.Min(math.MaxUint8, value))))
+	case reflect.Int:
+		field.SetInt(int64(math.Round(value)))
+	case reflect.Float32:
+		field.SetFloat(value)
+	}
+}
@@ -0,0 +1,73 @@
+package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"testing"
+)
+
+func Test_schemaEncoder_EncodeDataframes(t *testing.T) {
+	d80 := createResponseMap()["80"]
+	d7d := createResponseMap()["7D"]
+
+	e80, e7d := EncodeDataframes(decodeDataframes(d80, d7d))
+	then.AssertThat(t, len(e80), is.EqualTo(len(d80)))
+	then.AssertThat(t, len(e7d), is.EqualTo(len(d7d)))
+	then.AssertThat(t, e80[:11], is.EqualTo(d80[:11]))
+
+	// switches encode as on or off, the remaining bits of the raw value are not recoverable
+	then.AssertThat(t, e7d[2], is.EqualTo(byte(1)))
+	then.AssertThat(t, e7d[3:], is.EqualTo(d7d[3:]))
+}
+
+func Test_schemaEncoder_RoundTripScenarios(t *testing.T) {
+	for _, scenario := range []string{"testdata/nofaults.csv", "testdata/full-warmup-working-lambda.fcr"} {
+		r := NewResponder()
+		err := r.LoadScenario(scenario)
+		then.AssertThat(t, err, is.Nil())
+
+		for _, response := range r.Playbook.Responses {
+			data := decodeDataframes(response.Dataframe80, response.Dataframe7d)
+			e80, e7d := EncodeDataframes(data)
+
+			then.AssertThat(t, decodeDataframes(e80, e7d), is.EqualTo(data))
+		}
+	}
+}
+
+func Test_schemaEncoder_ValuesLimitedToRange(t *testing.T) {
+	d80, _ := EncodeDataframes(MemsData{CoolantTemp: -100, BatteryVoltage: 30})
+
+	then.AssertThat(t, d80[4], is.EqualTo(byte(0)))
+	then.AssertThat(t, d80[9], is.EqualTo(byte(255)))
+}
+
+func Test_schemaEncoder_DerivedChannelNotEncoded(t *testing.T) {
+	// the fuel trim correction shares the short term fuel trim register
+	_, d7d := EncodeDataframes(MemsData{ShortTermFuelTrim: 5, FuelTrimCorrection: 0})
+
+	then.AssertThat(t, d7d[13], is.EqualTo(byte(105)))
+}
+
+func Test_schemaEncoder_parseSchemaCSVData(t *testing.T) {
+	header := []string{"#time", "80x01-02_engine-rpm", "80x08_battery_voltage", "80x0A_idle_switch", "80x19_crankshaft_position_sensor", "unknown"}
+	record := []string{"12:00:00.000", "850", "13.600000", "true", "false", "x"}
+
+	data := parseSchemaCSVData(header, record)
+	then.AssertThat(t, data.EngineRPM, is.EqualTo(850))
+	then.AssertThat(t, data.BatteryVoltage, is.EqualTo(float32(13.6)))
+	then.AssertThat(t, data.IdleSwitch, is.True())
+	then.AssertThat(t, data.CrankshaftPositionSensor, is.EqualTo(0))
+}
+
+func Test_schemaEncoder_parseSchemaCSVDataWithUnits(t *testing.T) {
+	header := []string{"#time", "80x03_coolant_temp_degf", "80x07_map_kpa_inhg", "7dx04_air_fuel_ratio_lambda", "80x0D-0E_fault_codes", "dtc_faults"}
+	record := []string{"12:00:00.000", "194.00", "29.53", "1.00", "1", "CoolantTempSensor|FuelPumpCircuit"}
+
+	data := parseSchemaCSVData(header, record)
+	then.AssertThat(t, data.CoolantTemp, is.EqualTo(90))
+	then.AssertThat(t, data.ManifoldAbsolutePressure, is.EqualTo(float32(100)))
+	then.AssertThat(t, data.AirFuelRatio, is.EqualTo(float32(14.7)))
+	then.AssertThat(t, data.DTC0, is.EqualTo(CoolantSensorFaultCode))
+	then.AssertThat(t, data.DTC1, is.EqualTo(FuelPumpFaultCode))
+}
@@ -0,0 +1,99 @@
+package rosco
+
+import (
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func Test_schema_decodeDataframes(t *testing.T) {
+	d80 := createResponseMap()["80"]
+	d7d := createResponseMap()["7D"]
+
+	data := decodeDataframes(d80, d7d)
+	then.AssertThat(t, data.EngineRPM, is.EqualTo(int(d80[2])<<8|int(d80[3])))
+	then.AssertThat(t, data.CoolantTemp, is.EqualTo(int(d80[4])-55))
+	then.AssertThat(t, data.BatteryVoltage, is.EqualTo(float32(d80[9])/10))
+	then.AssertThat(t, data.ThrottleAngle, is.EqualTo(12))
+	then.AssertThat(t, data.LongTermFuelTrim, is.EqualTo(int(d7d[12])-128))
+	then.AssertThat(t, data.JackCount, is.EqualTo(int(d7d[32])))
+}
+
+func Test_schema_decodeShortDataframe(t *testing.T) {
+	// channels beyond the end of a short frame are not decoded
+	d80 := createResponseMap()["80"][:4]
+
+	data := decodeDataframes(d80, []byte{})
+	then.AssertThat(t, data.EngineRPM, is.Not(is.EqualTo(0)))
+	then.AssertThat(t, data.CoolantTemp, is.EqualTo(0))
+	then.AssertThat(t, data.JackCount, is.EqualTo(0))
+}
+
+func Test_schema_decodeBoolean(t *testing.T) {
+	d80 := append([]byte{}, createResponseMap()["80"]...)
+
+	d80[11] = IdleSwitchActive
+	then.AssertThat(t, decodeDataframes(d80, nil).IdleSwitch, is.True())
+
+	// only the masked bit is used
+	d80[11] = ^IdleSwitchActive
+	then.AssertThat(t, decodeDataframes(d80, nil).IdleSwitch, is.False())
+}
+
+func Test_schema_GetDataframeChannel(t *testing.T) {
+	channel, found := GetDataframeChannel("CoolantTemp")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, channel.Column, is.EqualTo("80x03_coolant_temp"))
+	then.AssertThat(t, channel.Unit, is.EqualTo("°C"))
+	then.AssertThat(t, channel.GetCalculation(), is.EqualTo("value - 55"))
+
+	_, found = GetDataframeChannel("Unknown")
+	then.AssertThat(t, found, is.False())
+}
+
+func Test_schema_GetCalculation(t *testing.T) {
+	channel, _ := GetDataframeChannel("IgnitionAdvance")
+	then.AssertThat(t, channel.GetCalculation(), is.EqualTo("value / 2 - 24"))
+
+	channel, _ = GetDataframeChannel("ThrottleAngle")
+	then.AssertThat(t, channel.GetCalculation(), is.EqualTo("value * 6 / 10"))
+
+	channel, _ = GetDataframeChannel("ClosedLoop")
+	then.AssertThat(t, channel.GetCalculation(), is.EqualTo("true / false"))
+
+	channel, _ = GetDataframeChannel("EngineRPM")
+	then.AssertThat(t, channel.GetCalculation(), is.EqualTo(""))
+}
+
+func Test_schema_CSVHeaderMatchesData(t *testing.T) {
+	header := strings.Split(getSchemaCSVHeader(MetricUnits), ",")
+	data := getSchemaCSVData(decodeDataframes(createResponseMap()["80"], createResponseMap()["7D"]), MetricUnits)
+
+	then.AssertThat(t, len(data), is.EqualTo(len(header)))
+	then.AssertThat(t, header[0], is.EqualTo("80x01-02_engine-rpm"))
+	then.AssertThat(t, header[len(header)-1], is.EqualTo("fuel_flow"))
+}
+
+func Test_schema_GetSchemaMarkdown(t *testing.T) {
+	markdown := GetSchemaMarkdown()
+	then.AssertThat(t, markdown, is.ValueContaining("| 80x03_coolant_temp|"))
+	then.AssertThat(t, markdown, is.ValueContaining("| value - 55 (°C) |"))
+}
+
+func Test_schema_FaultCodesDescribed(t *testing.T) {
+	// the fault code column covers both fault code registers
+	markdown := GetSchemaMarkdown()
+	then.AssertThat(t, markdown, is.ValueContaining("Fuel pump circuit fault (Code 10)"))
+	then.AssertThat(t, markdown, is.ValueContaining("Throttle pot circuit fault (Code 16)"))
+}
+
+func Test_schema_READMEMatchesSchema(t *testing.T) {
+	readme, err := ioutil.ReadFile("README.md")
+	then.AssertThat(t, err, is.Nil())
+
+	for _, row := range strings.Split(strings.TrimSpace(GetSchemaMarkdown()), "\n") {
+		then.AssertThat(t, string(readme), is.ValueContaining(row))
+	}
+}
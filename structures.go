@@ -1,9 +1,13 @@
 package rosco
 
+import "time"
+
 // MemsData is the mems information computed from dataframes 0x80 and 0x7d
 type (
 	MemsData struct {
 		Time                     string
+		Timestamp                time.Time
+		Elapsed                  time.Duration
 		EngineRPM                int
 		CoolantTemp              int
 		AmbientTemp              int
@@ -67,6 +71,15 @@ type (
 		FuelPumpCircuitFault     bool
 		ThrottlePotCircuitFault  bool
 
+		// metrics derived from the decoded values
+		Vacuum       float32
+		EngineLoad   float32
+		Lambda       float32
+		MixtureState string
+		FuelFlow     float32
+
+		FaultCodes []FaultCode `json:"FaultCodes"`
+
 		Analytics AnalysisReport
 
 		Dataframe80 string `json:"Dataframe80"`
@@ -187,7 +200,7 @@ const (
 	SecondaryTriggerSync = byte(0b00001000)
 	// Fan 1 Control
 	Fan1Control = byte(0b00010000)
-	// Fan 2 Control
+	// Fan 2 Control, set on ECUs with no faults so it is not decoded as a fault
 	Fan2Control = byte(0b01000000)
 
 	// 7x0E DTC3 Fault Codes
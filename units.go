@@ -0,0 +1,184 @@
+package rosco
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	UnitCelsius    = "°C"
+	UnitFahrenheit = "°F"
+	UnitKPa        = "kPa"
+	UnitPSI        = "psi"
+	UnitInHg       = "inHg"
+	UnitAFR        = "AFR"
+	UnitLambda     = "λ"
+)
+
+const (
+	// stoichiometric air:fuel ratio for petrol, lambda 1.0
+	stoichiometricAFR = 14.7
+	kPaToPSI          = 0.145038
+	kPaToInHg         = 0.2953
+)
+
+// UnitSystem defines the units used to present temperatures, pressures and mixture
+// the decoded MemsData is always metric, the unit system is applied when the data is exported or reported
+type UnitSystem struct {
+	Name        string `json:"Name"`
+	Temperature string `json:"Temperature"`
+	Pressure    string `json:"Pressure"`
+	Mixture     string `json:"Mixture"`
+}
+
+var (
+	// MetricUnits presents the data as decoded from the ECU
+	MetricUnits = UnitSystem{Name: "metric", Temperature: UnitCelsius, Pressure: UnitKPa, Mixture: UnitAFR}
+	// ImperialUnits presents temperatures in Fahrenheit and manifold pressure in inches of mercury
+	ImperialUnits = UnitSystem{Name: "imperial", Temperature: UnitFahrenheit, Pressure: UnitInHg, Mixture: UnitAFR}
+	// LambdaUnits presents the mixture as lambda
+	LambdaUnits = UnitSystem{Name: "lambda", Temperature: UnitCelsius, Pressure: UnitKPa, Mixture: UnitLambda}
+	// PSIUnits presents manifold pressure in pounds per square inch
+	PSIUnits = UnitSystem{Name: "psi", Temperature: UnitCelsius, Pressure: UnitPSI, Mixture: UnitAFR}
+)
+
+// column suffixes of converted values in the CSV log file
+var unitColumnSuffix = map[string]string{
+	UnitFahrenheit: "_degf",
+	UnitPSI:        "_psi",
+	UnitInHg:       "_inhg",
+	UnitLambda:     "_lambda",
+}
+
+// ChannelValue is the value of a channel presented in a unit system
+type ChannelValue struct {
+	Name        string  `json:"Name"`
+	DisplayName string  `json:"DisplayName"`
+	Value       float64 `json:"Value"`
+	Unit        string  `json:"Unit"`
+	Warning     bool    `json:"Warning"`
+}
+
+// GetUnitSystem returns the named unit system, metric, imperial, lambda or psi
+func GetUnitSystem(name string) (UnitSystem, error) {
+	for _, units := range []UnitSystem{MetricUnits, ImperialUnits, LambdaUnits, PSIUnits} {
+		if strings.EqualFold(units.Name, name) {
+			return units, nil
+		}
+	}
+
+	return MetricUnits, fmt.Errorf("unit system %s not supported", name)
+}
+
+// getTargetUnit returns the unit the metric unit is presented in
+func (units UnitSystem) getTargetUnit(unit string) string {
+	var target string
+
+	switch unit {
+	case UnitCelsius:
+		target = units.Temperature
+	case UnitKPa:
+		target = units.Pressure
+	case UnitAFR:
+		target = units.Mixture
+	}
+
+	if target == "" {
+		return unit
+	}
+
+	return target
+}
+
+// Convert converts the value from the metric unit into the unit system
+// returns the converted value and its unit
+func (units UnitSystem) Convert(value float64, unit string) (float64, string) {
+	target := units.getTargetUnit(unit)
+
+	switch target {
+	case UnitFahrenheit:
+		value = value*9/5 + 32
+	case UnitPSI:
+		value = value * kPaToPSI
+	case UnitInHg:
+		value = value * kPaToInHg
+	case UnitLambda:
+		value = value / stoichiometricAFR
+	}
+
+	return value, target
+}
+
+// IsConverted returns true if values in the metric unit are converted by the unit system
+func (units UnitSystem) IsConverted(unit string) bool {
+	return units.getTargetUnit(unit) != unit
+}
+
+// ConvertChannelMetadata returns the channel metadata with the range and thresholds in the unit system
+func (units UnitSystem) ConvertChannelMetadata(metadata ChannelMetadata) ChannelMetadata {
+	unit := metadata.Unit
+
+	metadata.Min, metadata.Unit = units.Convert(metadata.Min, unit)
+	metadata.Max, _ = units.Convert(metadata.Max, unit)
+
+	if metadata.WarningLow != nil {
+		low, _ := units.Convert(*metadata.WarningLow, unit)
+		metadata.WarningLow = &low
+	}
+
+	if metadata.WarningHigh != nil {
+		high, _ := units.Convert(*metadata.WarningHigh, unit)
+		metadata.WarningHigh = &high
+	}
+
+	return metadata
+}
+
+// GetChannelValue returns the value of the channel in the MemsData in the unit system
+func (units UnitSystem) GetChannelValue(data MemsData, name string) (ChannelValue, bool) {
+	metadata, ok := GetChannel(name)
+	if !ok {
+		return ChannelValue{}, false
+	}
+
+	// thresholds are compared in the metric unit
+	metric := metadata.GetValue(data)
+	value, unit := units.Convert(metric, metadata.Unit)
+
+	return ChannelValue{
+		Name:        metadata.Name,
+		DisplayName: metadata.DisplayName,
+		Value:       value,
+		Unit:        unit,
+		Warning:     metadata.IsWarning(metric),
+	}, true
+}
+
+// GetChannelValues returns the value of every channel in the MemsData in the unit system
+func (units UnitSystem) GetChannelValues(data MemsData) []ChannelValue {
+	var values []ChannelValue
+
+	for _, metadata := range GetChannels() {
+		if value, ok := units.GetChannelValue(data, metadata.Name); ok {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// FormatChannelValue returns the value of the channel in the MemsData with its unit in the unit system
+func (units UnitSystem) FormatChannelValue(data MemsData, name string) string {
+	metadata, ok := GetChannel(name)
+	if !ok {
+		return ""
+	}
+
+	if !units.IsConverted(metadata.Unit) {
+		return metadata.FormatValue(data)
+	}
+
+	value, unit := units.Convert(metadata.GetValue(data), metadata.Unit)
+
+	return fmt.Sprintf("%.2f %s", value, unit)
+}
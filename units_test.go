@@ -0,0 +1,118 @@
+package rosco
+
+import (
+	"encoding/json"
+	"github.com/corbym/gocrest/is"
+	"github.com/corbym/gocrest/then"
+	"math"
+	"strings"
+	"testing"
+)
+
+func Test_units_GetUnitSystem(t *testing.T) {
+	units, err := GetUnitSystem("Imperial")
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, units, is.EqualTo(ImperialUnits))
+
+	units, err = GetUnitSystem("psi")
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, units.Pressure, is.EqualTo(UnitPSI))
+
+	units, err = GetUnitSystem("unknown")
+	then.AssertThat(t, err, is.Not(is.Nil()))
+	then.AssertThat(t, units, is.EqualTo(MetricUnits))
+}
+
+func Test_units_Convert(t *testing.T) {
+	value, unit := ImperialUnits.Convert(90, UnitCelsius)
+	then.AssertThat(t, value, is.EqualTo(float64(194)))
+	then.AssertThat(t, unit, is.EqualTo(UnitFahrenheit))
+
+	value, unit = ImperialUnits.Convert(100, UnitKPa)
+	then.AssertThat(t, math.Round(value*100)/100, is.EqualTo(29.53))
+	then.AssertThat(t, unit, is.EqualTo(UnitInHg))
+
+	value, unit = PSIUnits.Convert(100, UnitKPa)
+	then.AssertThat(t, math.Round(value*100)/100, is.EqualTo(14.5))
+	then.AssertThat(t, unit, is.EqualTo(UnitPSI))
+
+	value, unit = LambdaUnits.Convert(14.7, UnitAFR)
+	then.AssertThat(t, value, is.EqualTo(float64(1)))
+	then.AssertThat(t, unit, is.EqualTo(UnitLambda))
+
+	// units not in the system are unchanged
+	value, unit = ImperialUnits.Convert(850, "rpm")
+	then.AssertThat(t, value, is.EqualTo(float64(850)))
+	then.AssertThat(t, unit, is.EqualTo("rpm"))
+
+	value, unit = MetricUnits.Convert(90, UnitCelsius)
+	then.AssertThat(t, value, is.EqualTo(float64(90)))
+	then.AssertThat(t, unit, is.EqualTo(UnitCelsius))
+}
+
+func Test_units_ConvertChannelMetadata(t *testing.T) {
+	metadata, _ := GetChannel("CoolantTemp")
+	converted := ImperialUnits.ConvertChannelMetadata(metadata)
+
+	then.AssertThat(t, converted.Unit, is.EqualTo(UnitFahrenheit))
+	then.AssertThat(t, *converted.WarningHigh, is.EqualTo(float64(248)))
+
+	// the registry is unchanged
+	then.AssertThat(t, *metadata.WarningHigh, is.EqualTo(float64(maximumCoolantTemperature)))
+}
+
+func Test_units_GetChannelValues(t *testing.T) {
+	data := MemsData{CoolantTemp: 130, AirFuelRatio: 14.7, EngineRPM: 850}
+
+	value, found := ImperialUnits.GetChannelValue(data, "CoolantTemp")
+	then.AssertThat(t, found, is.True())
+	then.AssertThat(t, value.Value, is.EqualTo(float64(266)))
+	then.AssertThat(t, value.Warning, is.True())
+
+	then.AssertThat(t, LambdaUnits.FormatChannelValue(data, "AirFuelRatio"), is.EqualTo("1.00 λ"))
+	then.AssertThat(t, ImperialUnits.FormatChannelValue(data, "EngineRPM"), is.EqualTo("850 rpm"))
+
+	then.AssertThat(t, len(MetricUnits.GetChannelValues(data)), is.EqualTo(len(GetChannels())))
+}
+
+func Test_units_CSVExport(t *testing.T) {
+	header := strings.Split(getMemsDataHeader(ImperialUnits), ",")
+	then.AssertThat(t, header[2], is.EqualTo("80x03_coolant_temp_degf"))
+	then.AssertThat(t, header[6], is.EqualTo("80x07_map_kpa_inhg"))
+
+	data := convertMemsDataToCSVData(MemsData{CoolantTemp: 90, ManifoldAbsolutePressure: 100}, ImperialUnits)
+	then.AssertThat(t, data[2], is.EqualTo("194.00"))
+	then.AssertThat(t, data[6], is.EqualTo("29.53"))
+
+	header = strings.Split(getMemsDataHeader(PSIUnits), ",")
+	then.AssertThat(t, header[2], is.EqualTo("80x03_coolant_temp"))
+	then.AssertThat(t, header[6], is.EqualTo("80x07_map_kpa_psi"))
+
+	// the metric log file is unchanged
+	then.AssertThat(t, getMemsDataHeader(MetricUnits), is.EqualTo(MemsDataHeader))
+	then.AssertThat(t, convertMemsDataToCSVData(MemsData{CoolantTemp: 90}, MetricUnits)[2], is.EqualTo("90"))
+}
+
+func Test_units_SetUnitSystem(t *testing.T) {
+	r := NewECUReaderInstance()
+	then.AssertThat(t, r.Units, is.EqualTo(MetricUnits))
+
+	err := r.SetUnitSystem("lambda")
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, r.Units, is.EqualTo(LambdaUnits))
+
+	err = r.SetUnitSystem("unknown")
+	then.AssertThat(t, err, is.Not(is.Nil()))
+	then.AssertThat(t, r.Units, is.EqualTo(LambdaUnits))
+}
+
+func Test_units_JSON(t *testing.T) {
+	data, err := json.Marshal(PSIUnits)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, string(data), is.EqualTo(`{"Name":"psi","Temperature":"°C","Pressure":"psi","Mixture":"AFR"}`))
+
+	value, _ := MetricUnits.GetChannelValue(MemsData{EngineRPM: 850}, "EngineRPM")
+	data, err = json.Marshal(value)
+	then.AssertThat(t, err, is.Nil())
+	then.AssertThat(t, string(data), is.ValueContaining(`"DisplayName":"Engine Speed"`))
+}